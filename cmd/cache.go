@@ -0,0 +1,430 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+
+	"github.com/redhat-developer/helm-dump/pkg/cache"
+	"github.com/redhat-developer/helm-dump/pkg/fsutil"
+)
+
+// cacheDirName is the name of the directory holding a project's cache, relative to the project root.
+const cacheDirName = ".helm-dump"
+
+// projectCache returns the cache of the chart in projectRoot, kept in cacheDir if informed and in the project's
+// .helm-dump directory otherwise.
+func projectCache(projectRoot string, cacheDir string) *cache.Cache {
+	if cacheDir == "" {
+		cacheDir = filepath.Join(projectRoot, cacheDirName)
+	}
+	return &cache.Cache{RootDir: cacheDir}
+}
+
+// addCacheDirFlag adds the --cache-dir flag, stored in cacheDir, to flags.
+func addCacheDirFlag(flags *pflag.FlagSet, cacheDir *string) {
+	flags.StringVar(cacheDir, "cache-dir", "", "The directory holding the chart's cache, which must not be shared with other charts; defaults to the project's "+cacheDirName)
+}
+
+// defaultLockTimeout is how long commands wait for other commands modifying the same project to finish.
+const defaultLockTimeout = 10 * time.Second
+
+// addLockTimeoutFlag adds the --lock-timeout flag, stored in timeout, to flags.
+func addLockTimeoutFlag(flags *pflag.FlagSet, timeout *time.Duration) {
+	flags.DurationVar(timeout, "lock-timeout", defaultLockTimeout, "How long to wait for other commands modifying the project to finish; 0 fails immediately")
+}
+
+// lockProject locks the .helm-dump directory of the chart in projectRoot, along with cacheDir if informed, so
+// concurrent commands don't modify the chart and its cache at the same time; the returned function unlocks them.
+func lockProject(projectRoot string, cacheDir string, timeout time.Duration, logger *logrus.Logger) (func(), error) {
+	if err := checkCacheDir(projectRoot, cacheDir); err != nil {
+		return nil, err
+	}
+
+	dirs := []string{filepath.Join(projectRoot, cacheDirName)}
+	if cacheDir != "" {
+		sameDir, err := isSameDir(dirs[0], cacheDir)
+		if err != nil {
+			return nil, err
+		}
+		if !sameDir {
+			dirs = append(dirs, cacheDir)
+		}
+	}
+
+	var locks []*fsutil.Lock
+	unlock := func() {
+		for i := len(locks) - 1; i >= 0; i-- {
+			if err := locks[i].Unlock(); err != nil {
+				logger.Warn(err)
+			}
+		}
+	}
+	for _, dir := range dirs {
+		lock, err := fsutil.LockDir(dir, timeout)
+		if err != nil {
+			unlock()
+			return nil, err
+		}
+		locks = append(locks, lock)
+	}
+	return unlock, nil
+}
+
+// checkCacheDir returns an error if cacheDir, when informed, is the chart's directory in projectRoot or holds a chart,
+// whose files would be taken for the cache's and removed along with it.
+func checkCacheDir(projectRoot string, cacheDir string) error {
+	if cacheDir == "" {
+		return nil
+	}
+	sameDir, err := isSameDir(projectRoot, cacheDir)
+	if err != nil {
+		return err
+	}
+	if sameDir {
+		return fmt.Errorf("the cache dir %q can't be the project root; use a directory dedicated to the cache", cacheDir)
+	}
+	_, err = os.Stat(filepath.Join(cacheDir, chartutil.ChartfileName))
+	if err == nil {
+		return fmt.Errorf("the cache dir %q holds a chart; use a directory dedicated to the cache", cacheDir)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// isSameDir returns whether the paths a and b refer to the same directory.
+func isSameDir(a string, b string) (bool, error) {
+	absA, err := filepath.Abs(a)
+	if err != nil {
+		return false, err
+	}
+	absB, err := filepath.Abs(b)
+	if err != nil {
+		return false, err
+	}
+	return absA == absB, nil
+}
+
+// inPlaceChartDir returns the absolute path of projectRoot, checking it's named after its chart, so the chart can be
+// written in place by saving it to the parent directory.
+func inPlaceChartDir(projectRoot string) (string, error) {
+	projectRoot, err := filepath.Abs(projectRoot)
+	if err != nil {
+		return "", err
+	}
+	metadata, err := chartutil.LoadChartfile(filepath.Join(projectRoot, chartutil.ChartfileName))
+	if err != nil {
+		return "", fmt.Errorf("error loading chart from %q: %w", projectRoot, err)
+	}
+	if metadata.Name != filepath.Base(projectRoot) {
+		return "", fmt.Errorf("charts are modified in place, which requires %q to be named after the chart %q", projectRoot, metadata.Name)
+	}
+	return projectRoot, nil
+}
+
+// saveChartDir writes chrt to outputDir like chartutil.SaveDir, but each file is written to a temporary directory
+// first and then renamed over its destination, so readers never observe partially written files.
+func saveChartDir(chrt *chart.Chart, outputDir string) error {
+	if outputDir == "" {
+		outputDir = "."
+	}
+	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+		return err
+	}
+	tmpDir, err := ioutil.TempDir(outputDir, ".helm-dump-save-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := chartutil.SaveDir(chrt, tmpDir); err != nil {
+		return err
+	}
+	return filepath.WalkDir(tmpDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(tmpDir, p)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(outputDir, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+			return err
+		}
+		return os.Rename(p, dst)
+	})
+}
+
+// Statuses of a template with respect to its snapshot in the cache.
+const (
+	templateUnchanged = "unchanged"
+	templateModified  = "modified"
+	templateNotCached = "not cached"
+	templateStale     = "stale"
+	templateCorrupted = "corrupted"
+)
+
+type CacheCommand struct {
+	*cobra.Command
+	Logger      *logrus.Logger
+	ProjectRoot string
+	CacheDir    string
+	LockTimeout time.Duration
+}
+
+func NewCacheCmd(logger *logrus.Logger) (*CacheCommand, error) {
+	cmd := &CacheCommand{
+		Logger: logger,
+		Command: &cobra.Command{
+			Use:   "cache",
+			Short: "inspect and maintain the snapshots of templates in the .helm-dump cache",
+		},
+	}
+
+	cmd.PersistentFlags().StringVarP(&cmd.ProjectRoot, "project-root", "d", ".", "The project root directory")
+	addCacheDirFlag(cmd.PersistentFlags(), &cmd.CacheDir)
+	addLockTimeoutFlag(cmd.PersistentFlags(), &cmd.LockTimeout)
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "status",
+			Short: "show whether templates diverged from their snapshots",
+			Args:  cobra.NoArgs,
+			RunE:  cmd.statusE,
+		},
+		&cobra.Command{
+			Use:   "list",
+			Short: "list the templates with snapshots and their hashes",
+			Args:  cobra.NoArgs,
+			RunE:  cmd.listE,
+		},
+		&cobra.Command{
+			Use:   "show template",
+			Short: "print the snapshot of a template",
+			Args:  cobra.ExactArgs(1),
+			RunE:  cmd.showE,
+		},
+		&cobra.Command{
+			Use:   "reset template...",
+			Short: "replace the snapshots of templates with their current contents",
+			Args:  cobra.MinimumNArgs(1),
+			RunE:  cmd.resetE,
+		},
+		&cobra.Command{
+			Use:   "prune",
+			Short: "remove the snapshots of templates which were deleted or renamed",
+			Args:  cobra.NoArgs,
+			RunE:  cmd.pruneE,
+		},
+		&cobra.Command{
+			Use:   "clear",
+			Short: "remove all snapshots; they're taken again from the current templates when needed",
+			Args:  cobra.NoArgs,
+			RunE:  cmd.clearE,
+		},
+	)
+
+	return cmd, nil
+}
+
+// templates returns the contents of the project's YAML templates, indexed by name.
+func (c *CacheCommand) templates() (map[string][]byte, error) {
+	chrt, err := loader.LoadDir(c.ProjectRoot)
+	if err != nil {
+		return nil, fmt.Errorf("error loading chart from %q: %w", c.ProjectRoot, err)
+	}
+	templates := make(map[string][]byte)
+	for _, tmpl := range chrt.Templates {
+		if isHiddenTemplate(tmpl) || !isYAMLTemplate(tmpl) {
+			continue
+		}
+		templates[tmpl.Name] = tmpl.Data
+	}
+	return templates, nil
+}
+
+func templateNames(templates map[string][]byte) []string {
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c *CacheCommand) statusE(cmd *cobra.Command, _ []string) error {
+	templates, err := c.templates()
+	if err != nil {
+		return err
+	}
+	projectCache := projectCache(c.ProjectRoot, c.CacheDir)
+	manifest, err := projectCache.Manifest()
+	if err != nil {
+		return err
+	}
+
+	statuses := make(map[string]string)
+	for name, data := range templates {
+		hash, ok, err := projectCache.EntryHash(name)
+		if err != nil {
+			return err
+		}
+		switch {
+		case !ok:
+			statuses[name] = templateNotCached
+		case hash == cache.Hash(data):
+			statuses[name] = templateUnchanged
+		default:
+			statuses[name] = templateModified
+		}
+		if _, ok := manifest.Entries[name]; ok {
+			if _, err := projectCache.Load(name); errors.Is(err, cache.ErrCorrupted) {
+				statuses[name] = templateCorrupted
+			}
+		}
+	}
+	stale, err := projectCache.Stale(templateNames(templates))
+	if err != nil {
+		return err
+	}
+	for _, name := range stale {
+		statuses[name] = templateStale
+	}
+
+	names := make([]string, 0, len(statuses))
+	for name := range statuses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "TEMPLATE\tSTATUS")
+	for _, name := range names {
+		_, _ = fmt.Fprintf(w, "%s\t%s\n", name, statuses[name])
+	}
+	return w.Flush()
+}
+
+func (c *CacheCommand) listE(cmd *cobra.Command, _ []string) error {
+	projectCache := projectCache(c.ProjectRoot, c.CacheDir)
+	keys, err := projectCache.Keys()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "TEMPLATE\tHASH")
+	for _, k := range keys {
+		hash, _, err := projectCache.EntryHash(k)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\n", k, hash)
+	}
+	return w.Flush()
+}
+
+func (c *CacheCommand) showE(cmd *cobra.Command, args []string) error {
+	projectCache := projectCache(c.ProjectRoot, c.CacheDir)
+	exists, err := projectCache.Exists(args[0])
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("template %q has no snapshot", args[0])
+	}
+	data, err := projectCache.Load(args[0])
+	if err != nil {
+		return err
+	}
+	_, err = cmd.OutOrStdout().Write(data)
+	return err
+}
+
+func (c *CacheCommand) resetE(cmd *cobra.Command, args []string) error {
+	unlock, err := lockProject(c.ProjectRoot, c.CacheDir, c.LockTimeout, c.Logger)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	templates, err := c.templates()
+	if err != nil {
+		return err
+	}
+	projectCache := projectCache(c.ProjectRoot, c.CacheDir)
+	for _, name := range args {
+		data, ok := templates[name]
+		if !ok {
+			return fmt.Errorf("template %q not found in %q", name, c.ProjectRoot)
+		}
+		if err := projectCache.Store(name, data); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%s: snapshot replaced with the current template\n", name)
+	}
+	return nil
+}
+
+func (c *CacheCommand) pruneE(cmd *cobra.Command, _ []string) error {
+	unlock, err := lockProject(c.ProjectRoot, c.CacheDir, c.LockTimeout, c.Logger)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	templates, err := c.templates()
+	if err != nil {
+		return err
+	}
+	pruned, err := projectCache(c.ProjectRoot, c.CacheDir).Prune(templateNames(templates))
+	if err != nil {
+		return err
+	}
+	for _, name := range pruned {
+		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%s: stale snapshot removed\n", name)
+	}
+	return nil
+}
+
+func (c *CacheCommand) clearE(cmd *cobra.Command, _ []string) error {
+	unlock, err := lockProject(c.ProjectRoot, c.CacheDir, c.LockTimeout, c.Logger)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	projectCache := projectCache(c.ProjectRoot, c.CacheDir)
+	if err := projectCache.Clear(); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "snapshots in %s removed\n", projectCache.RootDir)
+	return nil
+}
+
+func init() {
+	logger := logrus.New()
+	logger.Level = logrus.DebugLevel
+
+	cmd, err := NewCacheCmd(logger)
+	if err != nil {
+		panic(err)
+	}
+	rootCmd.AddCommand(cmd.Command)
+}
@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	hdtesting "github.com/redhat-developer/helm-dump/pkg/test"
+)
+
+func TestCacheCmd(t *testing.T) {
+	logger := logrus.New()
+	logger.Level = logrus.DebugLevel
+
+	const templateName = "templates/nginx-deployment_apps_v1.yaml"
+
+	runCache := func(t *testing.T, projectRoot string, args ...string) (string, error) {
+		cmd, err := NewCacheCmd(logger)
+		require.NoError(t, err)
+		cmd.SetArgs(append(args, "-d", projectRoot))
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+		err = cmd.Execute()
+		return outBuf.String(), err
+	}
+
+	t.Run("status", func(t *testing.T) {
+		// Arrange
+		projectRoot := hdtesting.CopyDir(t, "move_to_values_test/extract-integer/input-chart")
+		templatePath := filepath.Join(projectRoot, templateName)
+		data, err := os.ReadFile(templatePath)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(templatePath, append(data, []byte("# edited\n")...), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(projectRoot, "templates", "configmap.yaml"), []byte("kind: ConfigMap\n"), 0644))
+
+		// Act
+		out, err := runCache(t, projectRoot, "status")
+
+		// Assert
+		require.NoError(t, err)
+		require.Regexp(t, `templates/configmap.yaml\s+not cached`, out)
+		require.Regexp(t, `templates/nginx-deployment_apps_v1.yaml\s+modified`, out)
+	})
+
+	t.Run("status-of-unversioned-cache", func(t *testing.T) {
+		// Arrange
+		projectRoot := hdtesting.CopyDir(t, "move_to_values_test/extract-integer/input-chart")
+		data, err := os.ReadFile(filepath.Join(projectRoot, templateName))
+		require.NoError(t, err)
+		cacheDir := filepath.Join(projectRoot, cacheDirName)
+		require.NoError(t, os.RemoveAll(cacheDir))
+		require.NoError(t, os.MkdirAll(cacheDir, os.ModePerm))
+		require.NoError(t, os.WriteFile(filepath.Join(cacheDir, "templates_nginx-deployment_apps_v1_yaml"), data, 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(cacheDir, "templates_deleted_yaml"), data, 0644))
+
+		// Act
+		out, err := runCache(t, projectRoot, "status")
+
+		// Assert
+		require.NoError(t, err)
+		require.Regexp(t, `templates/nginx-deployment_apps_v1.yaml\s+unchanged`, out)
+		require.Regexp(t, `templates_deleted_yaml\s+stale`, out)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		// Arrange
+		projectRoot := hdtesting.CopyDir(t, "move_to_values_test/extract-integer/input-chart")
+		templatePath := filepath.Join(projectRoot, templateName)
+		edited := []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: edited\n")
+		require.NoError(t, os.WriteFile(templatePath, edited, 0644))
+
+		// Act
+		_, err := runCache(t, projectRoot, "reset", templateName)
+
+		// Assert
+		require.NoError(t, err)
+		out, err := runCache(t, projectRoot, "status")
+		require.NoError(t, err)
+		require.Regexp(t, `templates/nginx-deployment_apps_v1.yaml\s+unchanged`, out)
+		out, err = runCache(t, projectRoot, "show", templateName)
+		require.NoError(t, err)
+		require.Equal(t, string(edited), out)
+	})
+
+	t.Run("prune", func(t *testing.T) {
+		// Arrange
+		projectRoot := hdtesting.CopyDir(t, "move_to_values_test/extract-integer/input-chart")
+		renamedPath := filepath.Join(projectRoot, "templates", "deployment.yaml")
+		require.NoError(t, os.Rename(filepath.Join(projectRoot, templateName), renamedPath))
+
+		// Act
+		out, err := runCache(t, projectRoot, "status")
+		require.NoError(t, err)
+		require.Regexp(t, `templates/nginx-deployment_apps_v1.yaml\s+stale`, out)
+		_, err = runCache(t, projectRoot, "prune")
+
+		// Assert
+		require.NoError(t, err)
+		out, err = runCache(t, projectRoot, "list")
+		require.NoError(t, err)
+		require.NotContains(t, out, templateName)
+		entries, err := os.ReadDir(filepath.Join(projectRoot, cacheDirName, "objects"))
+		require.NoError(t, err)
+		require.Empty(t, entries, "the stale snapshot's content should be removed")
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		// Arrange
+		projectRoot := hdtesting.CopyDir(t, "move_to_values_test/extract-integer/input-chart")
+
+		// Act
+		_, err := runCache(t, projectRoot, "clear")
+
+		// Assert
+		require.NoError(t, err)
+		_, err = os.Stat(filepath.Join(projectRoot, cacheDirName, "manifest.json"))
+		require.True(t, os.IsNotExist(err), "the manifest should be removed")
+		_, err = os.Stat(filepath.Join(projectRoot, cacheDirName, "objects"))
+		require.True(t, os.IsNotExist(err), "the snapshots should be removed")
+	})
+
+	t.Run("clear-chart-dir", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			cacheDir func(projectRoot string) string
+		}{
+			{name: "project-root", cacheDir: func(projectRoot string) string { return projectRoot }},
+			{name: "other-chart", cacheDir: func(string) string {
+				return hdtesting.CopyDir(t, "move_to_values_test/extract-string/input-chart")
+			}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				// Arrange
+				projectRoot := hdtesting.CopyDir(t, "move_to_values_test/extract-integer/input-chart")
+				cacheDir := tt.cacheDir(projectRoot)
+
+				// Act
+				_, err := runCache(t, projectRoot, "clear", "--cache-dir", cacheDir)
+
+				// Assert
+				require.Error(t, err)
+				_, err = os.Stat(filepath.Join(cacheDir, "Chart.yaml"))
+				require.NoError(t, err, "the chart shouldn't be removed")
+			})
+		}
+	})
+
+	t.Run("show-unknown-template", func(t *testing.T) {
+		// Arrange
+		projectRoot := hdtesting.CopyDir(t, "move_to_values_test/extract-integer/input-chart")
+
+		// Act
+		_, err := runCache(t, projectRoot, "show", "templates/unknown.yaml")
+
+		// Assert
+		require.Error(t, err)
+	})
+
+	t.Run("corrupted", func(t *testing.T) {
+		// Arrange
+		projectRoot := hdtesting.CopyDir(t, "move_to_values_test/extract-integer/input-chart")
+		objects, err := os.ReadDir(filepath.Join(projectRoot, cacheDirName, "objects"))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(projectRoot, cacheDirName, "objects", objects[0].Name()), []byte("tampered"), 0644))
+
+		// Act
+		out, err := runCache(t, projectRoot, "status")
+
+		// Assert
+		require.NoError(t, err)
+		require.Regexp(t, `templates/nginx-deployment_apps_v1.yaml\s+corrupted`, out)
+		_, err = runCache(t, projectRoot, "show", templateName)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"helm.sh/helm/v3/pkg/chart"
+
+	"github.com/redhat-developer/helm-dump/pkg/apiversions"
+)
+
+const (
+	defaultChartVersion = "0.1.0"
+	defaultChartType    = "application"
+)
+
+// addChartMetadataFlags adds the flags informing the fields of the generated Chart.yaml.
+func (c *InitCommand) addChartMetadataFlags() {
+	flags := c.PersistentFlags()
+	flags.StringVar(&c.ChartVersion, "chart-version", defaultChartVersion, "The version of the generated chart")
+	flags.StringVar(&c.AppVersion, "app-version", "", "The version of the application packaged by the chart")
+	flags.StringVar(&c.Description, "description", "", "A one-sentence description of the chart")
+	flags.StringVar(&c.ChartType, "chart-type", defaultChartType, "The type of the chart, application or library")
+	flags.StringSliceVar(&c.Keywords, "keywords", nil, "A comma-separated list of keywords about the chart")
+	flags.StringArrayVar(&c.Maintainers, "maintainer", nil, `A maintainer of the chart as "Name <email>"; can be repeated`)
+	flags.StringVar(&c.Home, "home", "", "The URL of the chart's home page")
+	flags.StringSliceVar(&c.Sources, "sources", nil, "A comma-separated list of URLs to the source code of the chart")
+	flags.StringVar(&c.Icon, "icon", "", "A URL to an SVG or PNG image to be used as an icon")
+	flags.StringVar(&c.KubeVersion, "kube-version", "", "A SemVer range of compatible Kubernetes versions; derived from --target-kube-version or the cluster's version when empty")
+	flags.BoolVar(&c.AutoKubeVersion, "auto-kube-version", true, "Derive the kube-version from --target-kube-version or the cluster's version when it isn't informed")
+}
+
+// getChartMetadata returns the metadata of the chart being generated, built from the config file's chart map and
+// the chart metadata flags, which take precedence; kubeVersion defaults to target, if informed, or to the cluster's
+// version.
+func (c *InitCommand) getChartMetadata(cmd *cobra.Command, name string, target *apiversions.KubeVersion) (*chart.Metadata, error) {
+	metadata := &chart.Metadata{
+		Version: defaultChartVersion,
+		Type:    defaultChartType,
+	}
+	err := viper.UnmarshalKey("chart", metadata)
+	if err != nil {
+		return nil, fmt.Errorf("error reading chart metadata from config: %w", err)
+	}
+	metadata.APIVersion = chart.APIVersionV2
+	metadata.Name = name
+
+	flags := cmd.Flags()
+	if flags.Changed("chart-version") {
+		metadata.Version = c.ChartVersion
+	}
+	if flags.Changed("app-version") {
+		metadata.AppVersion = c.AppVersion
+	}
+	if flags.Changed("description") {
+		metadata.Description = c.Description
+	}
+	if flags.Changed("chart-type") {
+		metadata.Type = c.ChartType
+	}
+	if flags.Changed("keywords") {
+		metadata.Keywords = c.Keywords
+	}
+	if flags.Changed("maintainer") {
+		metadata.Maintainers = nil
+		for _, m := range c.Maintainers {
+			maintainer, err := parseMaintainer(m)
+			if err != nil {
+				return nil, err
+			}
+			metadata.Maintainers = append(metadata.Maintainers, maintainer)
+		}
+	}
+	if flags.Changed("home") {
+		metadata.Home = c.Home
+	}
+	if flags.Changed("sources") {
+		metadata.Sources = c.Sources
+	}
+	if flags.Changed("icon") {
+		metadata.Icon = c.Icon
+	}
+	if flags.Changed("kube-version") {
+		metadata.KubeVersion = c.KubeVersion
+	}
+
+	if metadata.KubeVersion == "" && c.AutoKubeVersion {
+		if target != nil {
+			metadata.KubeVersion = fmt.Sprintf(">=%d.%d.0-0", target.Major, target.Minor)
+		} else {
+			metadata.KubeVersion = c.getServerKubeVersion()
+		}
+	}
+
+	err = metadata.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid chart metadata: %w", err)
+	}
+
+	return metadata, nil
+}
+
+var maintainerRegexp = regexp.MustCompile(`^([^<>]*?)\s*(?:<([^<>]*)>)?$`)
+
+// parseMaintainer parses a maintainer informed as "Name <email>", where the email is optional.
+func parseMaintainer(s string) (*chart.Maintainer, error) {
+	groups := maintainerRegexp.FindStringSubmatch(strings.TrimSpace(s))
+	if groups == nil || groups[1] == "" {
+		return nil, fmt.Errorf("invalid maintainer %q; expected \"Name <email>\"", s)
+	}
+	return &chart.Maintainer{Name: groups[1], Email: groups[2]}, nil
+}
+
+var nonDigitRegexp = regexp.MustCompile(`\D`)
+
+// getServerKubeVersion returns a SemVer range matching the cluster's Kubernetes minor version and later ones, or an
+// empty string if the cluster's version isn't available.
+func (c *InitCommand) getServerKubeVersion() string {
+	info, err := c.DiscoveryClient.ServerVersion()
+	if err != nil {
+		c.Logger.WithError(err).Warnf("error obtaining the cluster's version, kubeVersion won't be set")
+		return ""
+	}
+	// managed clusters might report versions such as "23+".
+	major := nonDigitRegexp.ReplaceAllString(info.Major, "")
+	minor := nonDigitRegexp.ReplaceAllString(info.Minor, "")
+	if major == "" || minor == "" {
+		return ""
+	}
+	return fmt.Sprintf(">=%s.%s.0-0", major, minor)
+}
@@ -2,10 +2,12 @@ package cmd
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"github.com/goccy/go-yaml/ast"
 	"github.com/goccy/go-yaml/parser"
 	"github.com/redhat-developer/helm-dump/pkg/cache"
+	chartutil2 "github.com/redhat-developer/helm-dump/pkg/helm/chartutil"
 	"github.com/redhat-developer/helm-dump/pkg/visitor"
 	"github.com/sirupsen/logrus"
 	"path/filepath"
@@ -35,7 +37,7 @@ type ChartBuilder struct {
 	Logger      *logrus.Logger
 	ProjectRoot string
 	OutputDir   string
-	Cache       *cache.Cache
+	Cache       cache.Store
 	Actions     []*Action
 }
 
@@ -44,9 +46,7 @@ func NewChartBuilder(projectRoot string, outputDir string, logger *logrus.Logger
 		Logger:      logger,
 		ProjectRoot: projectRoot,
 		OutputDir:   outputDir,
-		Cache: &cache.Cache{
-			RootDir: filepath.Join(projectRoot, ".helm-dump"),
-		},
+		Cache:       projectCache(projectRoot, ""),
 	}, nil
 }
 
@@ -68,7 +68,7 @@ func decodeTemplate(file *chart.File) (*unstructured.Unstructured, *schema.Group
 
 	// decode the YAML template into unstructured
 	obj := &unstructured.Unstructured{}
-	_, gvk, decErr := dec.Decode(file.Data, nil, obj)
+	_, gvk, decErr := dec.Decode(chartutil2.MaskStandaloneActions(file.Data), nil, obj)
 
 	return obj, gvk, decErr
 }
@@ -121,50 +121,100 @@ func renderActionTemplate(obj *unstructured.Unstructured, tmpl string) (string,
 	return key, nil
 }
 
-func addToValuesYaml(
-	obj *unstructured.Unstructured,
-	valuesYaml map[string]interface{},
-	path string,
-	tmpl string,
-) (string, error) {
+// actionValue returns the value of the field at path in obj, and the values.yaml key computed by tmpl it's moved to.
+func actionValue(obj *unstructured.Unstructured, path string, tmpl string) (string, string, error) {
 	value, err := getJSONPathValue(obj.UnstructuredContent(), path)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	key, renderErr := renderActionTemplate(obj, tmpl)
 	if renderErr != nil {
-		return "", err
+		return "", "", renderErr
 	}
 
-	setFieldErr := unstructured.SetNestedField(valuesYaml, value, strings.Split(key, ".")...)
-	if setFieldErr != nil {
-		return "", setFieldErr
-	}
-
-	return key, nil
+	return key, value, nil
 }
 
-func collectPatches(path string, node *ast.DocumentNode) []visitor.Patch {
+func collectPatches(path string, data []byte, node *ast.DocumentNode) ([]visitor.Patch, error) {
 	collector := visitor.NewCollector()
-	v := visitor.NewMappingNodeVisitor(path, collector)
+	v := visitor.NewMappingNodeVisitor(path, data, collector)
 	ast.Walk(v, node)
-	return collector.Patches
+	if len(collector.Errors) > 0 {
+		return nil, collector.Errors[0]
+	}
+	return collector.Patches, nil
 }
 
-func updateTemplate(valuesKey string, path string, tmpl *chart.File) error {
-	tmplAst, parseErr := parser.ParseBytes(tmpl.Data, 0)
+// updateTemplate applies the actions matching gvk to tmpl, whose resource is obj, adding the moved fields to
+// valuesYaml. The patches of all actions are collected first and applied in a single pass; patches targeting a node
+// already patched by a previous action are reported and ignored.
+func (b *ChartBuilder) updateTemplate(tmpl *chart.File, obj *unstructured.Unstructured, gvk *schema.GroupVersionKind, valuesYaml map[string]interface{}) error {
+	var actions []*Action
+	for _, action := range b.Actions {
+		// only process apiVersion and kind specified in the command.
+		if actionMatchesGVK(action, gvk) {
+			actions = append(actions, action)
+		}
+	}
+	if len(actions) == 0 {
+		return nil
+	}
+
+	masked := chartutil2.MaskActions(tmpl.Data)
+	tmplAst, parseErr := parser.ParseBytes(masked, 0)
 	if parseErr != nil {
 		return fmt.Errorf("error parsing template data: %w", parseErr)
 	}
 
-	patches := collectPatches(path, tmplAst.Docs[0])
+	values := make(map[string]string)
+	collected := make(map[string]int)
+	var patches []visitor.Patch
+	for _, action := range actions {
+		valuesKey, value, err := actionValue(obj, action.path, action.template)
+		if err != nil {
+			b.Logger.WithError(err).Errorf("error appending values.yaml")
+			continue
+		}
+
+		actionPatches, err := collectPatches(action.path, masked, tmplAst.Docs[0])
+		if err != nil {
+			b.Logger.WithError(err).Errorf("error updating template resource")
+			continue
+		}
+		for i := range actionPatches {
+			actionPatches[i].ValuesKey = valuesKey
+		}
+		patches = append(patches, actionPatches...)
+		values[valuesKey] = value
+		collected[valuesKey] += len(actionPatches)
+	}
 
-	// Patches order must be descendent based on beginOffset.
+	patches, conflicts := visitor.SortPatches(patches)
+	for _, conflict := range conflicts {
+		b.Logger.WithError(conflict).Errorf("conflicting patches in %s; the latter is ignored", tmpl.Name)
+	}
+	applied := make(map[string]int)
 	for _, p := range patches {
-		tmpl.Data = p.Apply(valuesKey, tmpl.Data)
+		applied[p.ValuesKey]++
 	}
 
+	for valuesKey, value := range values {
+		// the value isn't moved if all patches referencing it were ignored.
+		if collected[valuesKey] > 0 && applied[valuesKey] == 0 {
+			continue
+		}
+		setFieldErr := unstructured.SetNestedField(valuesYaml, value, strings.Split(valuesKey, ".")...)
+		if setFieldErr != nil {
+			return setFieldErr
+		}
+	}
+
+	data, err := visitor.ApplyPatches(tmpl.Data, patches)
+	if err != nil {
+		return err
+	}
+	tmpl.Data = data
 	return nil
 }
 
@@ -178,9 +228,15 @@ func appendValuesYaml(
 		return fmt.Errorf("error marshalling values: %w", marshalErr)
 	}
 
-	// include values.yaml in the chart.
+	// include values.yaml in the chart, replacing the one loaded with it.
+	raw := make([]*chart.File, 0, len(chrt.Raw)+1)
+	for _, f := range chrt.Raw {
+		if f.Name != chartutil.ValuesfileName {
+			raw = append(raw, f)
+		}
+	}
 	chrt.Raw = append(
-		chrt.Raw,
+		raw,
 		&chart.File{
 			Name: chartutil.ValuesfileName,
 			Data: valuesBytes,
@@ -190,13 +246,13 @@ func appendValuesYaml(
 	return nil
 }
 
+// GetCachedResource returns the snapshot of tmpl, caching tmpl first if it has no snapshot yet; tmpl isn't modified.
 func (b *ChartBuilder) GetCachedResource(tmpl *chart.File) (*chart.File, error) {
-	cachedBytes, err := b.Cache.GetCachedResource(tmpl.Name, tmpl.Data)
+	cachedBytes, err := cache.GetCachedResource(b.Cache, tmpl.Name, tmpl.Data)
 	if err != nil {
 		return nil, err
 	}
-	tmpl.Data = cachedBytes
-	return tmpl, nil
+	return &chart.File{Name: tmpl.Name, Data: cachedBytes}, nil
 }
 
 func (b *ChartBuilder) Build() (*chart.Chart, error) {
@@ -209,9 +265,14 @@ func (b *ChartBuilder) Build() (*chart.Chart, error) {
 		return nil, fmt.Errorf("error loading chart from %q: %w", projectRoot, loadErr)
 	}
 
-	valuesYaml := make(map[string]interface{})
+	// keep the values the chart already has, such as the ones generated by init.
+	valuesYaml := chrt.Values
+	if valuesYaml == nil {
+		valuesYaml = make(map[string]interface{})
+	}
 
 	// 2. process template resources that match apiVersion and kind.
+	var templateNames []string
 TEMPLATE:
 	for _, tmpl := range chrt.Templates {
 		if isHiddenTemplate(tmpl) {
@@ -221,42 +282,40 @@ TEMPLATE:
 		if !isYAMLTemplate(tmpl) {
 			continue TEMPLATE
 		}
+		templateNames = append(templateNames, tmpl.Name)
 
-		tmpl, err := b.GetCachedResource(tmpl)
+		// the moved values are read from the snapshot, as the template may already reference values in their place,
+		// while the patches are applied to the template so the ones of previous operations are kept.
+		snapshot, err := b.GetCachedResource(tmpl)
+		if errors.Is(err, cache.ErrCorrupted) || errors.Is(err, cache.ErrUnsupportedVersion) {
+			return nil, err
+		}
 		if err != nil {
 			b.Logger.WithError(err).Errorf("error obtaining cached resource")
 			continue TEMPLATE
 		}
 
-		obj, gvk, decErr := decodeTemplate(tmpl)
+		obj, gvk, decErr := decodeTemplate(snapshot)
 		if decErr != nil {
 			b.Logger.WithError(decErr).Errorf("error decoding template")
 			continue TEMPLATE
 		}
 
-	ACTION:
-		for _, action := range b.Actions {
-
-			// only process apiVersion and kind specified in the command.
-			if !actionMatchesGVK(action, gvk) {
-				continue ACTION
-			}
-
-			valuesKey, err := addToValuesYaml(obj, valuesYaml, action.path, action.template)
-			if err != nil {
-				b.Logger.WithError(err).Errorf("error appending values.yaml")
-				continue ACTION
-			}
-
-			// update the template object
-			updateTemplateErr := updateTemplate(valuesKey, action.path, tmpl)
-			if updateTemplateErr != nil {
-				b.Logger.WithError(err).Errorf("error updating template resource")
-				continue ACTION
-			}
+		updateTemplateErr := b.updateTemplate(tmpl, obj, gvk, valuesYaml)
+		if updateTemplateErr != nil {
+			b.Logger.WithError(updateTemplateErr).Errorf("error updating template resource")
+			continue TEMPLATE
 		}
 	}
 
+	stale, staleErr := cache.Stale(b.Cache, templateNames)
+	if staleErr != nil {
+		return nil, staleErr
+	}
+	if len(stale) > 0 {
+		b.Logger.Warnf("cache has entries for templates which were deleted or renamed: %v", stale)
+	}
+
 	appendValuesYamlErr := appendValuesYaml(chrt, valuesYaml)
 	if appendValuesYamlErr != nil {
 		return nil, appendValuesYamlErr
@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/konveyor/crane-lib/apply"
+	"github.com/konveyor/crane-lib/transform"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/pager"
+
+	"github.com/redhat-developer/helm-dump/pkg/crane/plugin"
+)
+
+const (
+	defaultConcurrency = 4
+	defaultQPS         = 50
+	defaultBurst       = 100
+)
+
+// listTask is a resource type listed by collectObjects.
+type listTask struct {
+	gvr schema.GroupVersionResource
+}
+
+// listTasks returns the namespaced resource types available in the cluster allowed by filter, in discovery order.
+func (c *InitCommand) listTasks(filter *kindFilter) []listTask {
+	var tasks []listTask
+
+	apiResourceLists := c.DiscoveryHelper.Resources()
+	for _, resourceList := range apiResourceLists {
+		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			continue
+		}
+		c.Logger.Debugf("Collecting definitions for %s", gv.String())
+		for _, resource := range resourceList.APIResources {
+			if !resource.Namespaced || !isListable(resource) {
+				continue
+			}
+			if !filter.allows(gv.Group, resource) {
+				c.Logger.Debugf("\tskipping %s.%s", gv.String(), resource.Kind)
+				continue
+			}
+			c.Logger.Debugf("\t%s.%s", gv.String(), resource.Kind)
+			tasks = append(tasks, listTask{gvr: gv.WithResource(resource.Name)})
+		}
+	}
+
+	for _, kind := range filter.unmatched() {
+		c.Logger.Warnf("kind %q didn't match any namespaced resource available in the cluster", kind)
+	}
+
+	return tasks
+}
+
+// collectObjects lists the namespaced resources available in the cluster allowed by filter and transforms them
+// using plugins; resources plugins requested to be discarded aren't returned. Resource types are listed by up to
+// c.Concurrency workers, and the result is sorted so it doesn't depend on the order listings complete.
+func (c *InitCommand) collectObjects(
+	ctx context.Context,
+	filter *kindFilter,
+	runner transform.Runner,
+	plugins []transform.Plugin,
+) ([]*collectedObject, error) {
+	tasks := c.listTasks(filter)
+	c.Logger.Debugf("Namespace: %q", *c.ConfigFlags.Namespace)
+
+	concurrency := c.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	// each worker stores its results at the task's index, so no further synchronization is required.
+	results := make([][]*collectedObject, len(tasks))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < len(tasks); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = c.collectResource(ctx, tasks[i], runner, plugins)
+			}
+		}()
+	}
+
+SEND:
+	for i := range tasks {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break SEND
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("error collecting resources: %w", err)
+	}
+
+	var objs []*collectedObject
+	for _, result := range results {
+		objs = append(objs, result...)
+	}
+
+	sortCollectedObjects(objs)
+
+	return objs, nil
+}
+
+// collectResource lists and transforms the resources of a single type; errors are logged, so a failing resource
+// type doesn't prevent others from being collected.
+func (c *InitCommand) collectResource(
+	ctx context.Context,
+	task listTask,
+	runner transform.Runner,
+	plugins []transform.Plugin,
+) []*collectedObject {
+	var objs []*collectedObject
+
+	if c.ListTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.ListTimeout)
+		defer cancel()
+	}
+
+	resourceInterface := c.DynamicClient.Resource(task.gvr).Namespace(*c.ConfigFlags.Namespace)
+
+	p := pager.New(func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return resourceInterface.List(ctx, opts)
+	})
+
+	list, _, err := p.List(ctx, metav1.ListOptions{
+		LabelSelector: c.LabelSelector,
+		FieldSelector: c.FieldSelector,
+	})
+	if err != nil {
+		c.Logger.Errorf("error listing %s: %s", task.gvr.String(), err)
+		return nil
+	}
+
+	err = meta.EachListItem(list, func(object runtime.Object) error {
+		u, ok := object.(*unstructured.Unstructured)
+		if !ok {
+			return fmt.Errorf("expected *unstructured.Unstructured but got %T", object)
+		}
+
+		resp, err := runner.Run(*u, plugins)
+		if err != nil {
+			return err
+		}
+
+		// don't ever bother applying the patches as a plugin has requested for this resource to be discarded
+		if resp.HaveWhiteOut {
+			return nil
+		}
+
+		transformFile, err := plugin.SortTransformFile(resp.TransformFile)
+		if err != nil {
+			return err
+		}
+
+		applier := apply.Applier{}
+		bytes, err := applier.Apply(*u, transformFile)
+		if err != nil {
+			return err
+		}
+
+		transformed := &unstructured.Unstructured{}
+		err = transformed.UnmarshalJSON(bytes)
+		if err != nil {
+			return err
+		}
+
+		objs = append(objs, &collectedObject{original: u, transformed: transformed})
+
+		return nil
+	})
+	if err != nil {
+		c.Logger.Errorf("%s", err)
+	}
+
+	return objs
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kversion "k8s.io/apimachinery/pkg/version"
+)
+
+// versionPreference decides which API version to keep for resources served under more than one group version.
+type versionPreference struct {
+	// rank of the API versions informed by the user; lower is preferred.
+	rank map[string]int
+	// preferred is the version the cluster prefers for each group.
+	preferred map[string]string
+}
+
+// newVersionPreference returns the preference honoring the API versions informed by the user, in order, and then
+// the versions preferred by the cluster for each group.
+func newVersionPreference(apiVersions []string, groups []metav1.APIGroup) (*versionPreference, error) {
+	p := &versionPreference{
+		rank:      make(map[string]int),
+		preferred: make(map[string]string),
+	}
+	for i, apiVersion := range apiVersions {
+		gv, err := schema.ParseGroupVersion(strings.TrimSpace(apiVersion))
+		if err != nil || gv.Version == "" {
+			return nil, fmt.Errorf("invalid API version preference %q; expected group/version, or version for the core group", apiVersion)
+		}
+		p.rank[gv.String()] = i
+	}
+	for _, group := range groups {
+		p.preferred[group.Name] = group.PreferredVersion.Version
+	}
+	return p, nil
+}
+
+// tier returns 0 for API versions informed by the user, 1 for versions preferred by the cluster and 2 otherwise.
+func (p *versionPreference) tier(gv schema.GroupVersion) int {
+	if _, ok := p.rank[gv.String()]; ok {
+		return 0
+	}
+	if p.preferred[gv.Group] == gv.Version {
+		return 1
+	}
+	return 2
+}
+
+// prefers returns whether API version a is preferred over b.
+func (p *versionPreference) prefers(a string, b string) bool {
+	gvA, _ := schema.ParseGroupVersion(a)
+	gvB, _ := schema.ParseGroupVersion(b)
+
+	tierA, tierB := p.tier(gvA), p.tier(gvB)
+	if tierA != tierB {
+		return tierA < tierB
+	}
+	if tierA == 0 {
+		return p.rank[gvA.String()] < p.rank[gvB.String()]
+	}
+	// stable versions are preferred over beta and alpha ones, and later versions over earlier ones.
+	if c := kversion.CompareKubeAwareVersionStrings(gvA.Version, gvB.Version); c != 0 {
+		return c > 0
+	}
+	return gvA.Group < gvB.Group
+}
+
+// dedupeByUID keeps a single version of resources collected under more than one group version, such as an
+// HorizontalPodAutoscaler listed through autoscaling/v1 and autoscaling/v2; the order of objs is kept.
+func dedupeByUID(objs []*collectedObject, preference *versionPreference, logger logrus.FieldLogger) []*collectedObject {
+	chosen := make(map[string]*collectedObject)
+	for _, obj := range objs {
+		uid := string(obj.original.GetUID())
+		if uid == "" {
+			continue
+		}
+		current, ok := chosen[uid]
+		if !ok {
+			chosen[uid] = obj
+			continue
+		}
+		kept, dropped := current, obj
+		if preference.prefers(obj.original.GetAPIVersion(), current.original.GetAPIVersion()) {
+			kept, dropped = obj, current
+		}
+		chosen[uid] = kept
+		logger.Debugf("%s %q is served as %s and %s; keeping %s",
+			obj.original.GetKind(), obj.original.GetName(),
+			kept.original.GetAPIVersion(), dropped.original.GetAPIVersion(), kept.original.GetAPIVersion())
+	}
+
+	deduped := make([]*collectedObject, 0, len(objs))
+	for _, obj := range objs {
+		uid := string(obj.original.GetUID())
+		if uid != "" && chosen[uid] != obj {
+			continue
+		}
+		deduped = append(deduped, obj)
+	}
+	return deduped
+}
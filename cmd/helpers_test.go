@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	hdtesting "github.com/redhat-developer/helm-dump/pkg/test"
+)
+
+// movedTemplateName is the template of the extract-integer chart whose fields are moved to values.yaml.
+const movedTemplateName = "templates/nginx-deployment_apps_v1.yaml"
+
+// runCmd runs the command informed by args, such as move-to-template or values mv, with its flags and arguments,
+// returning what it printed; the commands are built for each run, so flags informed earlier aren't kept.
+func runCmd(t *testing.T, logger *logrus.Logger, args ...string) (string, error) {
+	moveToValues, err := NewMoveToValuesCmd(logger)
+	require.NoError(t, err)
+	moveToTemplate, err := NewMoveToTemplateCmd(logger)
+	require.NoError(t, err)
+	values, err := NewValuesCmd(logger)
+	require.NoError(t, err)
+
+	root := &cobra.Command{Use: "helm-dump"}
+	root.AddCommand(moveToValues.Command, moveToTemplate.Command, values.Command)
+	out := bytes.NewBufferString("")
+	root.SetArgs(args)
+	root.SetOut(out)
+	err = root.Execute()
+	return out.String(), err
+}
+
+// inPlaceChart returns a copy of the extract-integer chart in a directory named after it, so it can be modified in
+// place.
+func inPlaceChart(t *testing.T) string {
+	projectRoot := filepath.Join(hdtesting.TempDir(t), "my-chart")
+	require.NoError(t, os.Rename(hdtesting.CopyDir(t, "move_to_values_test/extract-integer/input-chart"), projectRoot))
+	return projectRoot
+}
+
+// movedChart returns a copy of the extract-integer chart whose replicas were moved to values.yaml, with values.
+func movedChart(t *testing.T, logger *logrus.Logger, values string) string {
+	projectRoot := inPlaceChart(t)
+	_, err := runCmd(t, logger, "move-to-values", "-d", projectRoot, "-o", filepath.Dir(projectRoot),
+		"apps/v1", "Deployment", `.spec.replicas`, `{{ resourceName . }}.replicas`)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(projectRoot, "values.yaml"), []byte(values), 0644))
+	return projectRoot
+}
+
+func readFile(t *testing.T, name string) string {
+	data, err := os.ReadFile(name)
+	require.NoError(t, err)
+	return string(data)
+}
@@ -1,48 +1,98 @@
 package cmd
 
 import (
-	"context"
 	"fmt"
-	"github.com/konveyor/crane-lib/apply"
 	"github.com/konveyor/crane-lib/transform"
+	"github.com/redhat-developer/helm-dump/pkg/apiversions"
+	"github.com/redhat-developer/helm-dump/pkg/cache"
 	"github.com/redhat-developer/helm-dump/pkg/crane/plugin"
+	"github.com/redhat-developer/helm-dump/pkg/crane/plugin/helmdumpinit"
 	chartutil2 "github.com/redhat-developer/helm-dump/pkg/helm/chartutil"
+	"github.com/redhat-developer/helm-dump/pkg/journal"
+	"github.com/redhat-developer/helm-dump/pkg/references"
+	"github.com/redhat-developer/helm-dump/pkg/sanitize"
+	"github.com/redhat-developer/helm-dump/pkg/secrets"
 	"github.com/vmware-tanzu/velero/pkg/discovery"
 	"helm.sh/helm/v3/pkg/chartutil"
+	"io"
+	"io/fs"
 	"os"
 	"path"
 	"path/filepath"
 	"sigs.k8s.io/yaml"
 	"strings"
+	"time"
 
-	"k8s.io/apimachinery/pkg/api/meta"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	kdiscovery "k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/tools/pager"
 	"k8s.io/utils/pointer"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
 	"github.com/vmware-tanzu/velero/pkg/features"
 	"helm.sh/helm/v3/pkg/chart"
 )
 
+const (
+	outputFormatDir  = "dir"
+	outputFormatTgz  = "tgz"
+	outputFormatBoth = "both"
+)
+
+var outputFormats = []string{outputFormatDir, outputFormatTgz, outputFormatBoth}
+
+func isOutputFormat(format string) bool {
+	for _, f := range outputFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
 type InitCommand struct {
 	*cobra.Command
-	PluginDir       string
-	SkipPlugins     []string
-	LabelSelector   string
-	Logger          *logrus.Logger
-	DynamicClient   dynamic.Interface
-	ConfigFlags     *genericclioptions.ConfigFlags
-	DiscoveryClient kdiscovery.CachedDiscoveryInterface
-	DiscoveryHelper discovery.Helper
+	PluginDir         string
+	BuiltinPlugins    []string
+	SkipPlugins       []string
+	PluginFlags       []string
+	RewriteReferences bool
+	SecretsPolicy     string
+	NodePortsPolicy   string
+	OutputFormat      string
+	TemplateLayout    string
+	LabelSelector     string
+	FieldSelector     string
+	IncludeKinds      []string
+	ExcludeKinds      []string
+	APIVersions       []string
+	TargetKubeVersion string
+	Concurrency       int
+	ListTimeout       time.Duration
+	CacheDir          string
+	LockTimeout       time.Duration
+	QPS               float32
+	Burst             int
+	ChartVersion      string
+	AppVersion        string
+	Description       string
+	ChartType         string
+	Keywords          []string
+	Maintainers       []string
+	Home              string
+	Sources           []string
+	Icon              string
+	KubeVersion       string
+	AutoKubeVersion   bool
+	Logger            *logrus.Logger
+	DynamicClient     dynamic.Interface
+	ConfigFlags       *genericclioptions.ConfigFlags
+	DiscoveryClient   kdiscovery.CachedDiscoveryInterface
+	DiscoveryHelper   discovery.Helper
 }
 
 func NewInitCmd(
@@ -65,19 +115,33 @@ func NewInitCmd(
 	configFlags.Namespace = pointer.String("default")
 	configFlags.AddFlags(initCmd.Flags())
 
-	ex, err := os.Executable()
+	pluginDir, err := defaultPluginDir()
 	if err != nil {
 		return nil, err
 	}
 
-	// Assume crane plugins will be available in the same directory as helm-dump is stored; this plays
-	// nicely in the current scenario where a release produces a bundle with binaries for all available
-	// targets or in a different one where one bundle per target.
-	pluginDir := path.Join(filepath.Dir(ex), "crane-plugins")
-
 	initCmd.PersistentFlags().StringVarP(&initCmd.PluginDir, "plugin-dir", "P", pluginDir, "The path where binary plugins are located")
+	initCmd.PersistentFlags().StringSliceVar(&initCmd.BuiltinPlugins, "builtin-plugins", plugin.BuiltinPluginNames(), "A comma-separated list of built-in plugins to run")
 	initCmd.PersistentFlags().StringSliceVarP(&initCmd.SkipPlugins, "skip-plugins", "S", nil, "A comma-separated list of plugins to skip")
+	initCmd.PersistentFlags().StringArrayVar(&initCmd.PluginFlags, "plugin-flag", nil, "An optional field passed to plugins as key=value; can be repeated (see 'plugin info')")
+	initCmd.PersistentFlags().BoolVar(&initCmd.RewriteReferences, "rewrite-references", true, "Rewrite references between collected resources according to their templated names")
+	initCmd.PersistentFlags().StringVar(&initCmd.SecretsPolicy, "secrets", string(secrets.DefaultPolicy), fmt.Sprintf("How secrets are included in the chart, one of %v", secrets.Policies))
+	initCmd.PersistentFlags().StringVar(&initCmd.NodePortsPolicy, "node-ports", string(sanitize.DefaultNodePortPolicy), fmt.Sprintf("How the node ports of services are included in the chart, one of %v", sanitize.NodePortPolicies))
+	initCmd.PersistentFlags().StringVar(&initCmd.OutputFormat, "output-format", outputFormatTgz, fmt.Sprintf("How the chart is written to output-dir, one of %v", outputFormats))
+	addCacheDirFlag(initCmd.PersistentFlags(), &initCmd.CacheDir)
+	addLockTimeoutFlag(initCmd.PersistentFlags(), &initCmd.LockTimeout)
+	initCmd.PersistentFlags().StringVar(&initCmd.TemplateLayout, "template-layout", defaultTemplateLayout, "A Go template computing the file names of templates from a resource's .Kind, .Name, .Namespace, .Group, .Version and .APIVersion")
 	initCmd.PersistentFlags().StringVarP(&initCmd.LabelSelector, "selector", "l", "", "A comma separated list of labels to filter resources")
+	initCmd.PersistentFlags().StringVar(&initCmd.FieldSelector, "field-selector", "", "A comma separated list of field selectors to filter resources, for example metadata.name=nginx")
+	initCmd.PersistentFlags().StringSliceVar(&initCmd.IncludeKinds, "include-kinds", nil, "A comma-separated list of kinds to collect, as kind, short name, group/kind or resource.group; when informed, only these kinds are collected")
+	initCmd.PersistentFlags().StringSliceVar(&initCmd.ExcludeKinds, "exclude-kinds", defaultExcludedKinds, "A comma-separated list of kinds not to collect when --include-kinds isn't informed")
+	initCmd.PersistentFlags().StringSliceVar(&initCmd.APIVersions, "api-version-preference", nil, "A comma-separated list of group/versions, in order of preference, used for resources served under more than one version; the versions preferred by the cluster are used otherwise")
+	initCmd.PersistentFlags().StringVar(&initCmd.TargetKubeVersion, "target-kube-version", "", "The Kubernetes version the chart is meant for, for example 1.25; resources using API versions removed by it are converted")
+	initCmd.PersistentFlags().IntVar(&initCmd.Concurrency, "concurrency", defaultConcurrency, "The number of resource types listed concurrently")
+	initCmd.PersistentFlags().DurationVar(&initCmd.ListTimeout, "list-timeout", 0, "The maximum time listing a resource type may take; 0 means no timeout")
+	initCmd.PersistentFlags().Float32Var(&initCmd.QPS, "qps", defaultQPS, "The maximum queries per second to the cluster")
+	initCmd.PersistentFlags().IntVar(&initCmd.Burst, "burst", defaultBurst, "The maximum burst of queries to the cluster")
+	initCmd.addChartMetadataFlags()
 
 	return initCmd, nil
 }
@@ -92,12 +156,15 @@ func (c *InitCommand) preRunE(_ *cobra.Command, _ []string) error {
 		if err != nil {
 			return err
 		}
+		restConfig.QPS = c.QPS
+		restConfig.Burst = c.Burst
 		c.DynamicClient = dynamic.NewForConfigOrDie(restConfig)
 	}
 
 	if c.DiscoveryClient == nil {
 		var err error
-		c.DiscoveryClient, err = c.ConfigFlags.ToDiscoveryClient()
+		// discovery is limited like collection, since both query the same cluster.
+		c.DiscoveryClient, err = c.ConfigFlags.WithDiscoveryQPS(c.QPS).WithDiscoveryBurst(c.Burst).ToDiscoveryClient()
 		if err != nil {
 			return err
 		}
@@ -114,123 +181,407 @@ func (c *InitCommand) preRunE(_ *cobra.Command, _ []string) error {
 	return nil
 }
 
+// collectedObject is a resource collected from the cluster along with its transformed version.
+type collectedObject struct {
+	original    *unstructured.Unstructured
+	transformed *unstructured.Unstructured
+	// conversion is the API version conversion applied for --target-kube-version, if any.
+	conversion *apiversions.Decision
+}
+
 func (c *InitCommand) runE(cmd *cobra.Command, args []string) error {
 	name := args[0]
 
 	chartFiles := make([]*chart.File, 0)
 
-	runner := transform.Runner{Log: c.Logger, OptionalFlags: map[string]string{
-		"chart-name": name,
-	}}
-	plugins, err := plugin.GetFilteredPlugins(c.PluginDir, nil, c.Logger)
+	if !isOutputFormat(c.OutputFormat) {
+		return fmt.Errorf("unknown output format %q; available formats are %v", c.OutputFormat, outputFormats)
+	}
 
-	apiResourceLists := c.DiscoveryHelper.Resources()
-	for _, resourceList := range apiResourceLists {
-		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+	layout, err := newTemplateLayout(c.TemplateLayout)
+	if err != nil {
+		return err
+	}
+
+	filter, err := newKindFilter(c.IncludeKinds, c.ExcludeKinds)
+	if err != nil {
+		return err
+	}
+
+	preference, err := newVersionPreference(c.APIVersions, c.DiscoveryHelper.APIGroups())
+	if err != nil {
+		return err
+	}
+
+	var target *apiversions.KubeVersion
+	if c.TargetKubeVersion != "" {
+		v, err := apiversions.ParseKubeVersion(c.TargetKubeVersion)
 		if err != nil {
-			continue
+			return err
 		}
-		c.Logger.Debugf("Collecting definitions for %s", gv.String())
-		for _, resource := range resourceList.APIResources {
-			if !resource.Namespaced {
-				continue
-			}
-			c.Logger.Debugf("\t%s.%s", gv.String(), resource.Kind)
-			gvr := &schema.GroupVersionResource{
-				Group:    gv.Group,
-				Version:  gv.Version,
-				Resource: resource.Name,
-			}
-			resourceInterface := c.DynamicClient.Resource(*gvr).Namespace(*c.ConfigFlags.Namespace)
+		target = &v
+	}
 
-			c.Logger.Debugf("Namespace: %q", *c.ConfigFlags.Namespace)
+	metadata, err := c.getChartMetadata(cmd, name, target)
+	if err != nil {
+		return err
+	}
 
-			p := pager.New(func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
-				return resourceInterface.List(ctx, opts)
-			})
+	plugins, err := plugin.GetLayeredPlugins(c.BuiltinPlugins, c.PluginDir, c.SkipPlugins, c.Logger)
+	if err != nil {
+		return fmt.Errorf("error loading plugins: %w", err)
+	}
 
-			list, _, err := p.List(cmd.Context(), metav1.ListOptions{LabelSelector: c.LabelSelector})
-			if err != nil {
-				c.Logger.Errorf("%s", err)
-				continue
-			}
+	optionalFlags, err := c.getOptionalFlags(name, plugins)
+	if err != nil {
+		return err
+	}
 
-			err = meta.EachListItem(list, func(object runtime.Object) error {
-				u, ok := object.(*unstructured.Unstructured)
-				if !ok {
-					return fmt.Errorf("expected *unstructured.Unstructured but got #{u}")
-				}
-
-				resp, err := runner.Run(*u, plugins)
-				if err != nil {
-					return err
-				}
-
-				// don't ever bother applying the patches as a plugin has requested for this resource to be discarded
-				if resp.HaveWhiteOut {
-					return nil
-				}
-
-				applier := apply.Applier{}
-				bytes, err := applier.Apply(*u, resp.TransformFile)
-				if err != nil {
-					return err
-				}
-
-				bytes, err = yaml.JSONToYAML(bytes)
-				if err != nil {
-					return err
-				}
-
-				name := nameFromUnstructured(u)
-				name = path.Join("templates", name)
-				file := &chart.File{
-					Name: name,
-					Data: bytes,
-				}
-
-				chartFiles = append(chartFiles, file)
-
-				return nil
-			})
-			if err != nil {
-				c.Logger.Errorf("%s", err)
-			}
+	runner := transform.Runner{Log: c.Logger, OptionalFlags: optionalFlags}
+
+	objs, err := c.collectObjects(cmd.Context(), filter, runner, plugins)
+	if err != nil {
+		return err
+	}
+	objs = dedupeByUID(objs, preference, c.Logger)
+	if target != nil {
+		convertAPIVersions(objs, *target)
+		printConversionSummary(cmd.ErrOrStderr(), objs)
+	}
+
+	nodePortsPolicy, err := sanitize.ParseNodePortPolicy(c.NodePortsPolicy)
+	if err != nil {
+		return err
+	}
+	sanitizer := sanitize.NewSanitizer(nodePortsPolicy)
+	for _, obj := range objs {
+		sanitizer.Sanitize(obj.transformed, obj.original.GetName())
+	}
+	printSanitizeSummary(cmd.ErrOrStderr(), sanitizer)
+
+	secretsPolicy, err := secrets.ParsePolicy(c.SecretsPolicy)
+	if err != nil {
+		return err
+	}
+	secretsHandler := secrets.NewHandler(secretsPolicy)
+	objs, err = handleSecrets(secretsHandler, objs)
+	if err != nil {
+		return err
+	}
+	printSecretsSummary(cmd.ErrOrStderr(), secretsHandler)
+
+	rewriter, err := c.getReferenceRewriter(name, optionalFlags, plugins)
+	if err != nil {
+		return err
+	}
+	if rewriter != nil {
+		for _, obj := range objs {
+			rewriter.AddMember(obj.original.GetKind(), obj.original.GetName())
 		}
 	}
 
-	chartFiles = append(chartFiles, chartutil2.DefaultHelpers(name))
+	fileNames, collisions, err := layout.fileNames(objs)
+	if err != nil {
+		return err
+	}
+	for fileName, renamed := range collisions {
+		c.Logger.Warnf("template layout produced %q for more than one resource; also using %v", fileName, renamed)
+	}
+
+	for i, obj := range objs {
+		if rewriter != nil {
+			rewriter.Rewrite(obj.transformed, obj.original.GetNamespace())
+		}
+
+		bytes, err := obj.transformed.MarshalJSON()
+		if err != nil {
+			return err
+		}
+
+		bytes, err = yaml.JSONToYAML(bytes)
+		if err != nil {
+			return err
+		}
+		bytes = helmdumpinit.RenderIncludes(bytes, name)
+		bytes = sanitize.RenderValues(bytes)
+		if obj.conversion != nil {
+			bytes = append([]byte(obj.conversion.Comment()), bytes...)
+		}
+
+		file := &chart.File{
+			Name: path.Join(chartutil.TemplatesDir, fileNames[i]),
+			Data: bytes,
+		}
+
+		chartFiles = append(chartFiles, file)
+	}
+
+	chartFiles = append(chartFiles, chartutil2.DefaultHelpers(name), chartutil2.DefaultHelmignore())
 
 	for _, chartFile := range chartFiles {
 		c.Logger.Debugf("name: %s\ndata:\n%s", chartFile.Name, string(chartFile.Data))
 	}
 
 	chrt := &chart.Chart{
-		Metadata: &chart.Metadata{
-			APIVersion: "v2",
-			Name:       name,
-			Version:    "0.1.0",
-		},
-		Files: chartFiles,
+		Metadata: metadata,
+		Files:    chartFiles,
 	}
 
-	outDir := args[1]
-	save, err := chartutil.Save(chrt, outDir)
+	values := chartutil2.DefaultValues()
+	for k, v := range sanitizer.Values {
+		values[k] = v
+	}
+	for k, v := range secretsHandler.Values {
+		values[k] = v
+	}
+	err = appendValuesYaml(chrt, values)
 	if err != nil {
 		return err
 	}
 
-	c.Logger.Debugf("chart stored in %s", save)
+	outDir := args[1]
+
+	if c.OutputFormat == outputFormatTgz || c.OutputFormat == outputFormatBoth {
+		save, err := chartutil.Save(chrt, outDir)
+		if err != nil {
+			return err
+		}
+		c.Logger.Debugf("chart stored in %s", save)
+	}
+
+	if c.OutputFormat == outputFormatDir || c.OutputFormat == outputFormatBoth {
+		chartDir := filepath.Join(outDir, name)
+		unlock, err := lockProject(chartDir, c.CacheDir, c.LockTimeout, c.Logger)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		before, err := snapshotChartDir(chartDir, c.CacheDir)
+		if err != nil {
+			return err
+		}
+		err = saveChartDir(chrt, outDir)
+		if err != nil {
+			return err
+		}
+		err = removeStaleTemplates(chartDir, chartFiles)
+		if err != nil {
+			return err
+		}
+		err = seedCache(projectCache(chartDir, c.CacheDir), chartFiles)
+		if err != nil {
+			return err
+		}
+		err = recordOperation(chartDir, c.CacheDir, journal.InitCommand, commandArgs(cmd, args), before)
+		if err != nil {
+			return err
+		}
+		c.Logger.Debugf("chart stored in %s", chartDir)
+	}
+
+	return nil
 
+}
+
+// removeStaleTemplates removes the files of the templates directory in chartDir which aren't among files, such as
+// the ones of resources gone since an earlier init into the same directory, or written with another layout, along
+// with the directories left empty, so the templates are exactly the ones generated.
+func removeStaleTemplates(chartDir string, files []*chart.File) error {
+	generated := make(map[string]bool, len(files))
+	for _, file := range files {
+		generated[file.Name] = true
+	}
+
+	var dirs []string
+	templatesDir := filepath.Join(chartDir, chartutil.TemplatesDir)
+	err := filepath.WalkDir(templatesDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != templatesDir {
+				dirs = append(dirs, p)
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(chartDir, p)
+		if err != nil {
+			return err
+		}
+		if generated[filepath.ToSlash(rel)] {
+			return nil
+		}
+		return os.Remove(p)
+	})
+	if err != nil {
+		return fmt.Errorf("error removing stale templates: %w", err)
+	}
+
+	// directories are walked before their contents, so the deepest ones come last.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		entries, err := os.ReadDir(dirs[i])
+		if err != nil {
+			return fmt.Errorf("error removing stale templates: %w", err)
+		}
+		if len(entries) == 0 {
+			if err := os.Remove(dirs[i]); err != nil {
+				return fmt.Errorf("error removing stale templates: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// seedCache stores the templates generated by init in the chart's cache c, so they're available to move-to-values
+// even after being modified.
+func seedCache(c *cache.Cache, files []*chart.File) error {
+	var names []string
+	for _, file := range files {
+		if isHiddenTemplate(file) || !isYAMLTemplate(file) {
+			continue
+		}
+		err := c.Store(file.Name, file.Data)
+		if err != nil {
+			return fmt.Errorf("error seeding cache: %w", err)
+		}
+		names = append(names, file.Name)
+	}
+
+	// entries left by an earlier init into the same directory are stale.
+	_, err := c.Prune(names)
+	if err != nil {
+		return fmt.Errorf("error seeding cache: %w", err)
+	}
 	return nil
+}
+
+// handleSecrets applies the secrets policy to the collected Secrets, dropping the ones the policy discards.
+func handleSecrets(handler *secrets.Handler, objs []*collectedObject) ([]*collectedObject, error) {
+	handled := make([]*collectedObject, 0, len(objs))
+	for _, obj := range objs {
+		if !secrets.IsSecret(obj.original) {
+			handled = append(handled, obj)
+			continue
+		}
+		transformed, err := handler.Handle(obj.transformed, obj.original.GetName())
+		if err != nil {
+			return nil, fmt.Errorf("error handling secret %q: %w", obj.original.GetName(), err)
+		}
+		if transformed == nil {
+			continue
+		}
+		obj.transformed = transformed
+		handled = append(handled, obj)
+	}
+	return handled, nil
+}
 
+// convertAPIVersions converts the collected resources to API versions served by target.
+func convertAPIVersions(objs []*collectedObject, target apiversions.KubeVersion) {
+	for _, obj := range objs {
+		obj.conversion = apiversions.Convert(obj.transformed, target)
+	}
+}
+
+// printConversionSummary reports the resources converted for --target-kube-version, and warns about the ones which
+// couldn't be converted.
+func printConversionSummary(w io.Writer, objs []*collectedObject) {
+	for _, obj := range objs {
+		d := obj.conversion
+		if d == nil {
+			continue
+		}
+		if d.Converted() {
+			_, _ = fmt.Fprintf(w, "%s %q: converted from %s to %s for Kubernetes %s\n", d.Kind, obj.original.GetName(), d.From, d.To, d.Target)
+		} else {
+			_, _ = fmt.Fprintf(w, "WARNING: %s %q: %s %s isn't served by Kubernetes %s: %s\n", d.Kind, obj.original.GetName(), d.From, d.Kind, d.Target, d.Err)
+		}
+	}
 }
 
-var replacer = strings.NewReplacer("/", "_", ".", "_")
+// printSanitizeSummary reports the fields assigned by the cluster which were removed or templated.
+func printSanitizeSummary(w io.Writer, sanitizer *sanitize.Sanitizer) {
+	for _, report := range sanitizer.Reports {
+		_, _ = fmt.Fprintf(w, "%s %q: %s\n", report.Kind, report.Name, strings.Join(report.Fields, ", "))
+	}
+}
+
+// printSecretsSummary warns about every Secret found and how it was included in the chart.
+func printSecretsSummary(w io.Writer, handler *secrets.Handler) {
+	if len(handler.Reports) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintf(w, "WARNING: found %d secret(s), handled with the %q policy:\n", len(handler.Reports), handler.Policy)
+	for _, report := range handler.Reports {
+		_, _ = fmt.Fprintf(w, "  - %s (type %s, keys %v): %s\n", report.Name, report.Type, report.Keys, report.Action)
+	}
+}
+
+// getReferenceRewriter returns the rewriter for references between the chart's resources, consistent with how the
+// HelmDumpInit plugin renames them; nil is returned if the plugin isn't used or rewriting is disabled.
+func (c *InitCommand) getReferenceRewriter(
+	chartName string,
+	optionalFlags map[string]string,
+	plugins []transform.Plugin,
+) (*references.Rewriter, error) {
+	if !c.RewriteReferences {
+		return nil, nil
+	}
+
+	found := false
+	for _, p := range plugins {
+		if p.Metadata().Name == helmdumpinit.Name {
+			found = true
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+
+	namingStrategy, err := helmdumpinit.ParseNamingStrategy(optionalFlags[helmdumpinit.NamingStrategyField])
+	if err != nil {
+		return nil, err
+	}
+
+	templateNamespace, err := helmdumpinit.ParseTemplateNamespace(optionalFlags[helmdumpinit.TemplateNamespaceField])
+	if err != nil {
+		return nil, err
+	}
+
+	rewriter := references.NewRewriter(func(kind string, name string) string {
+		return namingStrategy.TemplateName(chartName, kind, name)
+	})
+	if templateNamespace {
+		rewriter.NamespaceTemplate = helmdumpinit.NamespaceTemplate
+	}
+	return rewriter, nil
+}
+
+// getOptionalFlags merges the plugin flags informed in the config file's plugin-flags map with the ones informed in
+// the command line, which take precedence, and validates them against the optional fields declared by plugins.
+func (c *InitCommand) getOptionalFlags(chartName string, plugins []transform.Plugin) (map[string]string, error) {
+	optionalFlags := viper.GetStringMapString("plugin-flags")
+
+	cliFlags, err := plugin.ParseOptionalFlags(c.PluginFlags)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range cliFlags {
+		optionalFlags[k] = v
+	}
+
+	if _, ok := optionalFlags[helmdumpinit.ChartNameField]; ok {
+		return nil, fmt.Errorf("plugin flag %q is informed by the chart-name argument", helmdumpinit.ChartNameField)
+	}
+
+	err = plugin.ValidateOptionalFlags(plugins, optionalFlags)
+	if err != nil {
+		return nil, err
+	}
+
+	optionalFlags[helmdumpinit.ChartNameField] = chartName
 
-func nameFromUnstructured(obj *unstructured.Unstructured) string {
-	apiVersion := replacer.Replace(obj.GetAPIVersion())
-	return fmt.Sprintf("%s_%s.yaml", obj.GetName(), apiVersion)
+	return optionalFlags, nil
 }
 
 var defaultNamespace = "default"
@@ -1,26 +1,36 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io/fs"
 	"os"
 	"path"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chart/loader"
 	"helm.sh/helm/v3/pkg/chartutil"
 	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	kversion "k8s.io/apimachinery/pkg/version"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	kdiscovery "k8s.io/client-go/discovery"
 	fakediscovery "k8s.io/client-go/discovery/fake"
 	fakedynamic "k8s.io/client-go/dynamic/fake"
 	ktesting "k8s.io/client-go/testing"
 
+	"github.com/redhat-developer/helm-dump/pkg/cache"
+	"github.com/redhat-developer/helm-dump/pkg/fsutil"
 	hdtesting "github.com/redhat-developer/helm-dump/pkg/test"
 )
 
@@ -36,11 +46,13 @@ func newFakeCachedDiscovery(dynamicClient *fakedynamic.FakeDynamicClient) *FakeC
 			GroupVersion: appsv1.SchemeGroupVersion.String(),
 			APIResources: []metav1.APIResource{
 				{
-					Name:       "deployments",
-					Namespaced: true,
-					Kind:       "Deployment",
-					Group:      "apps",
-					Version:    "v1",
+					Name:         "deployments",
+					SingularName: "deployment",
+					ShortNames:   []string{"deploy"},
+					Namespaced:   true,
+					Kind:         "Deployment",
+					Group:        "apps",
+					Version:      "v1",
 					Verbs: []string{
 						"list",
 						"create",
@@ -64,7 +76,8 @@ func makeInitCommandWorld(
 	dynamicClient := fakedynamic.NewSimpleDynamicClient(scheme, objects...)
 	discoveryClient := newFakeCachedDiscovery(dynamicClient)
 	cmd, _ := NewInitCmd(configFlags, logger)
-	cmd.PluginDir = "../plugins/helm_dump_init/dist/"
+	// binary plugins aren't required since helm-dump's plugins are built-in.
+	cmd.PluginDir = ""
 	cmd.DiscoveryClient = discoveryClient
 	cmd.DynamicClient = dynamicClient
 	return cmd, discoveryClient, dynamicClient
@@ -125,9 +138,9 @@ func TestNewInitCmd(t *testing.T) {
 		maybeDeployment := chrt.Templates[0]
 		actual := hdtesting.LoadBytesFixture(t, maybeDeployment.Data)
 		require.Equal(t,
-			"nginx-deployment-{{ .Release.Name }}",
+			`nginx-deployment-{{ .Release.Name | trunc 46 | trimSuffix "-" }}`,
 			actual.GetName(),
-			"name should match; did you build helm_dump_init crane plugin?")
+			"name should match")
 
 		maybeHelpers := chrt.Templates[1]
 		require.Equal(t, chartutil.HelpersName, maybeHelpers.Name)
@@ -180,11 +193,769 @@ func TestNewInitCmd(t *testing.T) {
 
 		maybeDeployment := chrt.Templates[0]
 		actual := hdtesting.LoadBytesFixture(t, maybeDeployment.Data)
-		require.Equal(t, "nginx-deployment1-{{ .Release.Name }}", actual.GetName(), "name should match; did you build helm_dump_init crane plugin?")
+		require.Equal(t, `nginx-deployment1-{{ .Release.Name | trunc 45 | trimSuffix "-" }}`, actual.GetName(), "name should match")
 
 		maybeHelpers := chrt.Templates[1]
 		require.Equal(t, chartutil.HelpersName, maybeHelpers.Name)
 	})
+
+	t.Run("using-plugin-flag", func(t *testing.T) {
+		// Arrange
+		chartName := "my-chart"
+		chartVersion := "0.1.0"
+
+		tempDir := hdtesting.TempDir(t)
+
+		cmd, _, _ := makeInitCommandWorld(
+			genericclioptions.NewConfigFlags(true),
+			logger,
+			hdtesting.LoadYamlFixture(t, "init_test/minimum-required-arguments/nginx-deployment.yaml"))
+
+		cmd.SetArgs([]string{
+			"--namespace", "default",
+			"--plugin-flag", "add-annotations=team=web,tier=frontend",
+			chartName, tempDir})
+
+		// Act
+		require.NoError(t, cmd.Execute(), "Cmd must not return an error")
+
+		// Assert
+		chrt := hdtesting.RequireChart(t, tempDir, chartName, chartVersion)
+		actual := hdtesting.LoadBytesFixture(t, chrt.Templates[0].Data)
+		require.Equal(t, "web", actual.GetAnnotations()["team"])
+		require.Equal(t, "frontend", actual.GetAnnotations()["tier"])
+	})
+
+	t.Run("using-standard-labels", func(t *testing.T) {
+		// Arrange
+		chartName := "my-chart"
+		chartVersion := "0.1.0"
+
+		tempDir := hdtesting.TempDir(t)
+
+		cmd, _, _ := makeInitCommandWorld(
+			genericclioptions.NewConfigFlags(true),
+			logger,
+			hdtesting.LoadYamlFixture(t, "init_test/minimum-required-arguments/nginx-deployment.yaml"))
+
+		cmd.SetArgs([]string{
+			"--namespace", "default",
+			"--plugin-flag", "standard-labels=true",
+			chartName, tempDir})
+
+		// Act
+		require.NoError(t, cmd.Execute(), "Cmd must not return an error")
+
+		// Assert
+		chrt := hdtesting.RequireChart(t, tempDir, chartName, chartVersion)
+		require.Contains(t, string(chrt.Templates[0].Data), `{{- include "my-chart.labels" . | nindent 4 }}`)
+		require.Contains(t, string(chrt.Templates[0].Data), `{{- include "my-chart.selectorLabels" . | nindent 6 }}`)
+
+		rendered := hdtesting.RequireRenderedTemplates(t, chrt, "my-app")
+		actual := hdtesting.LoadBytesFixture(t, []byte(rendered[path.Join(chartName, chrt.Templates[0].Name)]))
+		require.Equal(t, "my-app", actual.GetLabels()["app.kubernetes.io/instance"])
+		require.Equal(t, "nginx", actual.GetLabels()["app"])
+		matchLabels, _, err := unstructured.NestedStringMap(actual.Object, "spec", "selector", "matchLabels")
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{
+			"app":                        "nginx",
+			"app.kubernetes.io/name":     chartName,
+			"app.kubernetes.io/instance": "my-app",
+		}, matchLabels)
+	})
+
+	t.Run("using-secrets-values", func(t *testing.T) {
+		// Arrange
+		chartName := "my-chart"
+		chartVersion := "0.1.0"
+
+		tempDir := hdtesting.TempDir(t)
+
+		cmd, discoveryClient, _ := makeInitCommandWorld(
+			genericclioptions.NewConfigFlags(true),
+			logger,
+			hdtesting.LoadYamlFixture(t, "init_test/using-secrets/app-secret.yaml"))
+		discoveryClient.Resources = []*metav1.APIResourceList{coreV1SecretsResourceList}
+
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+		cmd.SetArgs([]string{
+			"--namespace", "default",
+			"--secrets", "values",
+			chartName, tempDir})
+
+		// Act
+		require.NoError(t, cmd.Execute(), "Cmd must not return an error")
+
+		// Assert
+		require.Contains(t, errBuf.String(), "app-secret")
+
+		chrt := hdtesting.RequireChart(t, tempDir, chartName, chartVersion)
+		require.NotContains(t, string(chrt.Templates[0].Data), "c2VjcmV0", "secret data must not be in the chart")
+		require.Equal(t, map[string]interface{}{
+			"nameOverride":     "",
+			"fullnameOverride": "",
+			"secrets": map[string]interface{}{
+				"app-secret": map[string]interface{}{"password": ""},
+			},
+		}, chrt.Values)
+
+		chrt.Values["secrets"].(map[string]interface{})["app-secret"].(map[string]interface{})["password"] = "secret"
+		rendered := hdtesting.RequireRenderedTemplates(t, chrt, "my-app")
+		actual := hdtesting.LoadBytesFixture(t, []byte(rendered[path.Join(chartName, chrt.Templates[0].Name)]))
+		password, _, _ := unstructured.NestedString(actual.Object, "data", "password")
+		require.Equal(t, "c2VjcmV0", password)
+	})
+
+	t.Run("using-output-format-both", func(t *testing.T) {
+		// Arrange
+		chartName := "my-chart"
+		chartVersion := "0.1.0"
+
+		tempDir := hdtesting.TempDir(t)
+
+		cmd, _, _ := makeInitCommandWorld(
+			genericclioptions.NewConfigFlags(true),
+			logger,
+			hdtesting.LoadYamlFixture(t, "init_test/minimum-required-arguments/nginx-deployment.yaml"))
+
+		cmd.SetArgs([]string{
+			"--namespace", "default",
+			"--output-format", "both",
+			chartName, tempDir})
+
+		// Act
+		require.NoError(t, cmd.Execute(), "Cmd must not return an error")
+
+		// Assert
+		hdtesting.RequireChartFileExists(t, tempDir, chartName, chartVersion)
+
+		chartDir := path.Join(tempDir, chartName)
+		chrt, err := loader.LoadDir(chartDir)
+		require.NoError(t, err, "%q should be a chart", chartDir)
+		require.Len(t, chrt.Templates, 2)
+
+		templateCache := &cache.Cache{RootDir: path.Join(chartDir, ".helm-dump")}
+		cached, err := templateCache.Load("templates/deployment/nginx-deployment.yaml")
+		require.NoError(t, err, "the template should be cached")
+		require.Equal(t, string(chrt.Templates[1].Data), string(cached))
+
+		// the chart directory can be used by move-to-values right away.
+		moveToValuesCmd, err := NewMoveToValuesCmd(logger)
+		require.NoError(t, err)
+		moveToValuesCmd.SetArgs([]string{
+			"-d", chartDir,
+			"-o", tempDir,
+			"apps/v1",
+			"Deployment",
+			`.spec.replicas`,
+			`{{ resourceName . }}.replicas`,
+		})
+		require.NoError(t, moveToValuesCmd.Execute())
+
+		movedChart, err := loader.LoadDir(path.Join(tempDir, chartName))
+		require.NoError(t, err)
+		require.Contains(t, movedChart.Values, "nameOverride", "values generated by init should be kept")
+		require.Contains(t, movedChart.Values, "nginx-deployment")
+	})
+
+	t.Run("using-chart-metadata", func(t *testing.T) {
+		// Arrange
+		chartName := "my-chart"
+		chartVersion := "1.2.3"
+
+		tempDir := hdtesting.TempDir(t)
+
+		cmd, discoveryClient, _ := makeInitCommandWorld(
+			genericclioptions.NewConfigFlags(true),
+			logger,
+			hdtesting.LoadYamlFixture(t, "init_test/minimum-required-arguments/nginx-deployment.yaml"))
+		discoveryClient.FakedServerVersion = &kversion.Info{Major: "1", Minor: "23+"}
+
+		cmd.SetArgs([]string{
+			"--namespace", "default",
+			"--chart-version", chartVersion,
+			"--app-version", "1.21.6",
+			"--description", "nginx web server",
+			"--keywords", "nginx,web",
+			"--maintainer", "Jane Doe <jane@example.com>",
+			"--maintainer", "John Doe",
+			"--home", "https://example.com",
+			chartName, tempDir})
+
+		// Act
+		require.NoError(t, cmd.Execute(), "Cmd must not return an error")
+
+		// Assert
+		chrt := hdtesting.RequireChart(t, tempDir, chartName, chartVersion)
+		require.Equal(t, &chart.Metadata{
+			APIVersion:  chart.APIVersionV2,
+			Name:        chartName,
+			Version:     chartVersion,
+			AppVersion:  "1.21.6",
+			Description: "nginx web server",
+			Type:        "application",
+			Keywords:    []string{"nginx", "web"},
+			Maintainers: []*chart.Maintainer{
+				{Name: "Jane Doe", Email: "jane@example.com"},
+				{Name: "John Doe"},
+			},
+			Home:        "https://example.com",
+			KubeVersion: ">=1.23.0-0",
+		}, chrt.Metadata)
+
+		var helmignore *chart.File
+		for _, f := range chrt.Files {
+			if f.Name == chartutil.IgnorefileName {
+				helmignore = f
+			}
+		}
+		require.NotNil(t, helmignore, ".helmignore should be in the chart")
+		require.Contains(t, string(helmignore.Data), ".helm-dump/")
+
+		require.Equal(t, map[string]interface{}{
+			"nameOverride":     "",
+			"fullnameOverride": "",
+		}, chrt.Values)
+	})
+
+	t.Run("using-invalid-chart-metadata", func(t *testing.T) {
+		// Arrange
+		cmd, _, _ := makeInitCommandWorld(
+			genericclioptions.NewConfigFlags(true),
+			logger,
+			hdtesting.LoadYamlFixture(t, "init_test/minimum-required-arguments/nginx-deployment.yaml"))
+
+		cmd.SetArgs([]string{
+			"--namespace", "default",
+			"--chart-type", "plugin",
+			"my-chart", hdtesting.TempDir(t)})
+
+		// Act & Assert
+		require.Error(t, cmd.Execute(), "Cmd must reject invalid chart metadata")
+	})
+
+	t.Run("using-template-layout", func(t *testing.T) {
+		// Arrange
+		chartName := "my-chart"
+
+		runInit := func(outDir string, args ...string) {
+			cmd, discoveryClient, _ := makeInitCommandWorld(
+				genericclioptions.NewConfigFlags(true),
+				logger,
+				hdtesting.LoadYamlFixture(t, "init_test/using-template-layout/nginx-deployment.yaml"),
+				hdtesting.LoadYamlFixture(t, "init_test/using-template-layout/nginx-service.yaml"),
+				hdtesting.LoadYamlFixture(t, "init_test/using-template-layout/nginx-configmap.yaml"))
+			discoveryClient.Resources = append(discoveryClient.Resources, coreV1ConfigMapsAndServicesResourceList)
+			cmd.SetArgs(append(append([]string{"--namespace", "default", "--output-format", "dir"}, args...), chartName, outDir))
+			require.NoError(t, cmd.Execute(), "Cmd must not return an error")
+		}
+
+		firstDir, secondDir, flatDir := hdtesting.TempDir(t), hdtesting.TempDir(t), hdtesting.TempDir(t)
+
+		// Act
+		runInit(firstDir)
+		runInit(secondDir)
+		runInit(flatDir, "--template-layout", "{{ .Name }}.yaml")
+
+		// Assert
+		first := readChartDir(t, path.Join(firstDir, chartName))
+		require.Equal(t, first, readChartDir(t, path.Join(secondDir, chartName)), "charts should be byte-identical across runs")
+		for _, name := range []string{"templates/configmap/nginx.yaml", "templates/service/nginx.yaml", "templates/deployment/nginx.yaml"} {
+			require.Contains(t, first, name)
+		}
+
+		flat := readChartDir(t, path.Join(flatDir, chartName))
+		require.Contains(t, flat["templates/nginx.yaml"], "kind: ConfigMap")
+		require.Contains(t, flat["templates/nginx-2.yaml"], "kind: Service")
+		require.Contains(t, flat["templates/nginx-3.yaml"], "kind: Deployment")
+	})
+
+	t.Run("rerun-after-resource-removed", func(t *testing.T) {
+		// Arrange
+		chartName := "my-chart"
+
+		// the core resources are only served along with the objects using them.
+		runInit := func(outDir string, objects []runtime.Object, args ...string) {
+			cmd, discoveryClient, _ := makeInitCommandWorld(genericclioptions.NewConfigFlags(true), logger, objects...)
+			if len(objects) > 1 {
+				discoveryClient.Resources = append(discoveryClient.Resources, coreV1ConfigMapsAndServicesResourceList)
+			}
+			cmd.SetArgs(append(append([]string{"--namespace", "default", "--output-format", "dir"}, args...), chartName, outDir))
+			require.NoError(t, cmd.Execute(), "Cmd must not return an error")
+		}
+		deployment := hdtesting.LoadYamlFixture(t, "init_test/using-template-layout/nginx-deployment.yaml")
+		all := []runtime.Object{
+			deployment,
+			hdtesting.LoadYamlFixture(t, "init_test/using-template-layout/nginx-service.yaml"),
+			hdtesting.LoadYamlFixture(t, "init_test/using-template-layout/nginx-configmap.yaml"),
+		}
+		rerunDir, freshDir := hdtesting.TempDir(t), hdtesting.TempDir(t)
+		runInit(rerunDir, all, "--template-layout", "{{ .Name }}.yaml")
+
+		// Act
+		runInit(rerunDir, []runtime.Object{deployment})
+		runInit(freshDir, []runtime.Object{deployment})
+
+		// Assert
+		templates := func(dir string) map[string]string {
+			files := make(map[string]string)
+			for name, data := range readChartDir(t, path.Join(dir, chartName)) {
+				if strings.HasPrefix(name, chartutil.TemplatesDir+"/") {
+					files[name] = data
+				}
+			}
+			return files
+		}
+		require.Equal(t, templates(freshDir), templates(rerunDir), "the templates of removed resources and older layouts should be deleted")
+		entries, err := projectJournal(path.Join(rerunDir, chartName)).Entries()
+		require.NoError(t, err)
+		require.Len(t, entries, 2, "the cache should be kept")
+	})
+
+	t.Run("using-concurrency", func(t *testing.T) {
+		// Arrange
+		chartName := "my-chart"
+
+		runInit := func(ctx context.Context, outDir string, concurrency string) error {
+			cmd, discoveryClient, _ := makeInitCommandWorld(
+				genericclioptions.NewConfigFlags(true),
+				logger,
+				hdtesting.LoadYamlFixture(t, "init_test/using-template-layout/nginx-deployment.yaml"),
+				hdtesting.LoadYamlFixture(t, "init_test/using-template-layout/nginx-service.yaml"),
+				hdtesting.LoadYamlFixture(t, "init_test/using-template-layout/nginx-configmap.yaml"))
+			discoveryClient.Resources = append(discoveryClient.Resources, coreV1ConfigMapsAndServicesResourceList)
+			cmd.SetArgs([]string{
+				"--namespace", "default",
+				"--output-format", "dir",
+				"--concurrency", concurrency,
+				"--list-timeout", "10s",
+				chartName, outDir})
+			return cmd.ExecuteContext(ctx)
+		}
+
+		sequentialDir, concurrentDir := hdtesting.TempDir(t), hdtesting.TempDir(t)
+		cancelled, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		// Act
+		require.NoError(t, runInit(context.Background(), sequentialDir, "1"))
+		require.NoError(t, runInit(context.Background(), concurrentDir, "3"))
+		cancelledErr := runInit(cancelled, hdtesting.TempDir(t), "3")
+
+		// Assert
+		require.Equal(t,
+			readChartDir(t, path.Join(sequentialDir, chartName)),
+			readChartDir(t, path.Join(concurrentDir, chartName)),
+			"charts should not depend on the concurrency")
+		require.ErrorIs(t, cancelledErr, context.Canceled)
+	})
+
+	t.Run("using-invalid-template-layout", func(t *testing.T) {
+		// Arrange
+		cmd, _, _ := makeInitCommandWorld(
+			genericclioptions.NewConfigFlags(true),
+			logger,
+			hdtesting.LoadYamlFixture(t, "init_test/minimum-required-arguments/nginx-deployment.yaml"))
+
+		cmd.SetArgs([]string{
+			"--namespace", "default",
+			"--template-layout", "../{{ .Name }}.yaml",
+			"my-chart", hdtesting.TempDir(t)})
+
+		// Act & Assert
+		require.Error(t, cmd.Execute(), "Cmd must reject templates outside the templates directory")
+	})
+
+	t.Run("using-kind-filters", func(t *testing.T) {
+		deploymentsListAction := ktesting.NewListAction(
+			schema.GroupVersionResource{Resource: "deployments", Group: "apps", Version: "v1"},
+			schema.GroupVersionKind{Kind: "Deployment", Group: "apps", Version: "v1"},
+			"default",
+			metav1.ListOptions{},
+		)
+		servicesListAction := ktesting.NewListAction(
+			schema.GroupVersionResource{Resource: "services", Version: "v1"},
+			schema.GroupVersionKind{Kind: "Service", Version: "v1"},
+			"default",
+			metav1.ListOptions{},
+		)
+
+		for _, tc := range []struct {
+			name            string
+			args            []string
+			expectedActions []ktesting.Action
+		}{
+			{
+				name:            "include-short-name",
+				args:            []string{"--include-kinds", "deploy"},
+				expectedActions: []ktesting.Action{deploymentsListAction},
+			},
+			{
+				name:            "include-group-kind",
+				args:            []string{"--include-kinds", "apps/Deployment,core/services"},
+				expectedActions: []ktesting.Action{deploymentsListAction, servicesListAction},
+			},
+			{
+				name:            "exclude-resource-group",
+				args:            []string{"--exclude-kinds", "configmaps,deployments.apps"},
+				expectedActions: []ktesting.Action{servicesListAction},
+			},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				// Arrange
+				cmd, discoveryClient, _ := makeInitCommandWorld(
+					genericclioptions.NewConfigFlags(true),
+					logger,
+					hdtesting.LoadYamlFixture(t, "init_test/using-template-layout/nginx-deployment.yaml"),
+					hdtesting.LoadYamlFixture(t, "init_test/using-template-layout/nginx-service.yaml"),
+					hdtesting.LoadYamlFixture(t, "init_test/using-template-layout/nginx-configmap.yaml"))
+				discoveryClient.Resources = append(discoveryClient.Resources, coreV1ConfigMapsAndServicesResourceList)
+
+				cmd.SetArgs(append(append([]string{"--namespace", "default"}, tc.args...), "my-chart", hdtesting.TempDir(t)))
+
+				// Act
+				require.NoError(t, cmd.Execute(), "Cmd must not return an error")
+
+				// Assert
+				actualActions := FilterActions(discoveryClient.Actions())
+				require.Len(t, actualActions, len(tc.expectedActions))
+				CheckActions(t, tc.expectedActions, actualActions)
+			})
+		}
+	})
+
+	t.Run("using-field-selector", func(t *testing.T) {
+		// Arrange
+		cmd, discoveryClient, _ := makeInitCommandWorld(
+			genericclioptions.NewConfigFlags(true),
+			logger,
+			hdtesting.LoadYamlFixture(t, "init_test/minimum-required-arguments/nginx-deployment.yaml"))
+
+		cmd.SetArgs([]string{
+			"--namespace", "default",
+			"--field-selector", "metadata.name=nginx-deployment",
+			"my-chart", hdtesting.TempDir(t)})
+
+		// Act
+		require.NoError(t, cmd.Execute(), "Cmd must not return an error")
+
+		// Assert
+		expectedActions := []ktesting.Action{
+			ktesting.NewListAction(
+				schema.GroupVersionResource{Resource: "deployments", Group: "apps", Version: "v1"},
+				schema.GroupVersionKind{Kind: "Deployment", Group: "apps", Version: "v1"},
+				"default",
+				metav1.ListOptions{FieldSelector: "metadata.name=nginx-deployment"},
+			),
+		}
+		actualActions := FilterActions(discoveryClient.Actions())
+		require.Len(t, actualActions, 1)
+		CheckActions(t, expectedActions, actualActions)
+	})
+
+	t.Run("using-api-version-preference", func(t *testing.T) {
+		for _, tc := range []struct {
+			name               string
+			args               []string
+			expectedAPIVersion string
+		}{
+			{name: "cluster-preferred", expectedAPIVersion: "autoscaling/v2"},
+			{name: "user-preferred", args: []string{"--api-version-preference", "autoscaling/v1"}, expectedAPIVersion: "autoscaling/v1"},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				// Arrange
+				chartName := "my-chart"
+				chartVersion := "0.1.0"
+
+				tempDir := hdtesting.TempDir(t)
+
+				cmd, discoveryClient, _ := makeInitCommandWorld(
+					genericclioptions.NewConfigFlags(true),
+					logger,
+					hdtesting.LoadYamlFixture(t, "init_test/using-api-version-preference/nginx-hpa-v1.yaml"),
+					hdtesting.LoadYamlFixture(t, "init_test/using-api-version-preference/nginx-hpa-v2.yaml"))
+				discoveryClient.Resources = []*metav1.APIResourceList{
+					autoscalingResourceList("v2"),
+					autoscalingResourceList("v1"),
+				}
+
+				cmd.SetArgs(append(append([]string{"--namespace", "default"}, tc.args...), chartName, tempDir))
+
+				// Act
+				require.NoError(t, cmd.Execute(), "Cmd must not return an error")
+
+				// Assert
+				chrt := hdtesting.RequireChart(t, tempDir, chartName, chartVersion)
+				require.Len(t, chrt.Templates, 2, "the HorizontalPodAutoscaler should be included once")
+				actual := hdtesting.LoadBytesFixture(t, chrt.Templates[0].Data)
+				require.Equal(t, tc.expectedAPIVersion, actual.GetAPIVersion())
+			})
+		}
+	})
+
+	t.Run("using-target-kube-version", func(t *testing.T) {
+		// Arrange
+		chartName := "my-chart"
+		chartVersion := "0.1.0"
+
+		tempDir := hdtesting.TempDir(t)
+
+		cmd, discoveryClient, _ := makeInitCommandWorld(
+			genericclioptions.NewConfigFlags(true),
+			logger,
+			hdtesting.LoadYamlFixture(t, "init_test/using-target-kube-version/nginx-ingress.yaml"))
+		discoveryClient.Resources = []*metav1.APIResourceList{
+			{
+				GroupVersion: "networking.k8s.io/v1beta1",
+				APIResources: []metav1.APIResource{
+					{
+						Name:       "ingresses",
+						Namespaced: true,
+						Kind:       "Ingress",
+						Group:      "networking.k8s.io",
+						Version:    "v1beta1",
+						Verbs:      []string{"list", "create", "get", "delete"},
+					},
+				},
+			},
+		}
+
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+		cmd.SetArgs([]string{
+			"--namespace", "default",
+			"--target-kube-version", "1.25",
+			chartName, tempDir})
+
+		// Act
+		require.NoError(t, cmd.Execute(), "Cmd must not return an error")
+
+		// Assert
+		require.Contains(t, errBuf.String(), `Ingress "nginx": converted from networking.k8s.io/v1beta1 to networking.k8s.io/v1`)
+
+		chrt := hdtesting.RequireChart(t, tempDir, chartName, chartVersion)
+		require.Equal(t, ">=1.25.0-0", chrt.Metadata.KubeVersion)
+		require.Len(t, chrt.Templates, 2)
+		require.True(t,
+			strings.HasPrefix(string(chrt.Templates[0].Data), "# helm-dump: converted from networking.k8s.io/v1beta1 to networking.k8s.io/v1 for Kubernetes 1.25\n"),
+			"the conversion should be recorded in the template")
+		actual := hdtesting.LoadBytesFixture(t, chrt.Templates[0].Data)
+		require.Equal(t, "networking.k8s.io/v1", actual.GetAPIVersion())
+	})
+
+	t.Run("using-release-namespace", func(t *testing.T) {
+		// Arrange
+		chartName := "my-chart"
+		chartVersion := "0.1.0"
+
+		tempDir := hdtesting.TempDir(t)
+
+		cmd, _, _ := makeInitCommandWorld(
+			genericclioptions.NewConfigFlags(true),
+			logger,
+			hdtesting.LoadYamlFixture(t, "init_test/minimum-required-arguments/nginx-deployment.yaml"))
+
+		cmd.SetArgs([]string{
+			"--namespace", "default",
+			chartName, tempDir})
+
+		// Act
+		require.NoError(t, cmd.Execute(), "Cmd must not return an error")
+
+		// Assert
+		chrt := hdtesting.RequireChart(t, tempDir, chartName, chartVersion)
+		rendered := hdtesting.RequireRenderedTemplatesInNamespace(t, chrt, "my-app", "other")
+		actual := hdtesting.LoadBytesFixture(t, []byte(rendered[path.Join(chartName, chrt.Templates[0].Name)]))
+		require.Equal(t, "other", actual.GetNamespace(), "resources should be installed in the release's namespace")
+	})
+
+	t.Run("using-sanitizer", func(t *testing.T) {
+		// Arrange
+		chartName := "my-chart"
+		chartVersion := "0.1.0"
+
+		tempDir := hdtesting.TempDir(t)
+
+		cmd, discoveryClient, _ := makeInitCommandWorld(
+			genericclioptions.NewConfigFlags(true),
+			logger,
+			hdtesting.LoadYamlFixture(t, "init_test/using-sanitizer/nginx-service.yaml"),
+			hdtesting.LoadYamlFixture(t, "init_test/using-sanitizer/migrate-job.yaml"))
+		discoveryClient.Resources = append(discoveryClient.Resources, coreV1ConfigMapsAndServicesResourceList, batchV1JobsResourceList)
+
+		cmd.SetArgs([]string{
+			"--namespace", "default",
+			"--include-kinds", "services,jobs",
+			"--node-ports", "values",
+			chartName, tempDir})
+
+		// Act
+		require.NoError(t, cmd.Execute(), "Cmd must not return an error")
+
+		// Assert
+		chrt := hdtesting.RequireChart(t, tempDir, chartName, chartVersion)
+		rendered := hdtesting.RequireRenderedTemplates(t, chrt, "my-app")
+
+		service := hdtesting.LoadBytesFixture(t, []byte(rendered[path.Join(chartName, "templates/service/nginx.yaml")]))
+		_, found, _ := unstructured.NestedString(service.Object, "spec", "clusterIP")
+		require.False(t, found, "clusterIP should be assigned by the cluster")
+		ports, _, _ := unstructured.NestedSlice(service.Object, "spec", "ports")
+		require.Equal(t, int64(30080), ports[0].(map[string]interface{})["nodePort"], "nodePort should default to the collected port")
+		require.Equal(t, map[string]interface{}{"nginx": map[string]interface{}{"http": float64(30080)}}, chrt.Values["nodePorts"])
+
+		job := hdtesting.LoadBytesFixture(t, []byte(rendered[path.Join(chartName, "templates/job/migrate.yaml")]))
+		_, found, _ = unstructured.NestedMap(job.Object, "spec", "selector")
+		require.False(t, found, "the generated selector should be removed")
+		labels, _, _ := unstructured.NestedStringMap(job.Object, "spec", "template", "metadata", "labels")
+		require.NotContains(t, labels, "controller-uid")
+		require.NotContains(t, labels, "batch.kubernetes.io/job-name")
+	})
+
+	t.Run("using-unknown-node-ports-policy", func(t *testing.T) {
+		// Arrange
+		cmd, _, _ := makeInitCommandWorld(
+			genericclioptions.NewConfigFlags(true),
+			logger,
+			hdtesting.LoadYamlFixture(t, "init_test/minimum-required-arguments/nginx-deployment.yaml"))
+
+		cmd.SetArgs([]string{
+			"--namespace", "default",
+			"--node-ports", "random",
+			"my-chart", hdtesting.TempDir(t)})
+
+		// Act & Assert
+		require.Error(t, cmd.Execute())
+	})
+
+	t.Run("using-unknown-output-format", func(t *testing.T) {
+		// Arrange
+		cmd, _, _ := makeInitCommandWorld(
+			genericclioptions.NewConfigFlags(true),
+			logger,
+			hdtesting.LoadYamlFixture(t, "init_test/minimum-required-arguments/nginx-deployment.yaml"))
+
+		cmd.SetArgs([]string{
+			"--namespace", "default",
+			"--output-format", "zip",
+			"my-chart", hdtesting.TempDir(t)})
+
+		// Act & Assert
+		require.Error(t, cmd.Execute())
+	})
+
+	t.Run("using-unknown-plugin-flag", func(t *testing.T) {
+		// Arrange
+		cmd, _, _ := makeInitCommandWorld(
+			genericclioptions.NewConfigFlags(true),
+			logger,
+			hdtesting.LoadYamlFixture(t, "init_test/minimum-required-arguments/nginx-deployment.yaml"))
+
+		cmd.SetArgs([]string{
+			"--namespace", "default",
+			"--plugin-flag", "unknown=value",
+			"my-chart", hdtesting.TempDir(t)})
+
+		// Act & Assert
+		require.Error(t, cmd.Execute(), "Cmd must reject flags not declared by plugins")
+	})
+}
+
+var coreV1SecretsResourceList = &metav1.APIResourceList{
+	GroupVersion: "v1",
+	APIResources: []metav1.APIResource{
+		{
+			Name:       "secrets",
+			Namespaced: true,
+			Kind:       "Secret",
+			Version:    "v1",
+			Verbs:      []string{"list", "create", "get", "delete"},
+		},
+	},
+}
+
+var coreV1ConfigMapsAndServicesResourceList = &metav1.APIResourceList{
+	GroupVersion: "v1",
+	APIResources: []metav1.APIResource{
+		{
+			Name:       "configmaps",
+			Namespaced: true,
+			Kind:       "ConfigMap",
+			Version:    "v1",
+			Verbs:      []string{"list", "create", "get", "delete"},
+		},
+		{
+			Name:       "services",
+			Namespaced: true,
+			Kind:       "Service",
+			Version:    "v1",
+			Verbs:      []string{"list", "create", "get", "delete"},
+		},
+	},
+}
+
+var batchV1JobsResourceList = &metav1.APIResourceList{
+	GroupVersion: "batch/v1",
+	APIResources: []metav1.APIResource{
+		{
+			Name:       "jobs",
+			Namespaced: true,
+			Kind:       "Job",
+			Group:      "batch",
+			Version:    "v1",
+			Verbs:      []string{"list", "create", "get", "delete"},
+		},
+	},
+}
+
+func autoscalingResourceList(version string) *metav1.APIResourceList {
+	return &metav1.APIResourceList{
+		GroupVersion: "autoscaling/" + version,
+		APIResources: []metav1.APIResource{
+			{
+				Name:       "horizontalpodautoscalers",
+				Namespaced: true,
+				Kind:       "HorizontalPodAutoscaler",
+				Group:      "autoscaling",
+				Version:    version,
+				Verbs:      []string{"list", "create", "get", "delete"},
+			},
+		},
+	}
+}
+
+// readChartDir returns the contents of the files in a chart directory indexed by their slash-separated path
+// relative to the directory.
+func readChartDir(t *testing.T, dir string) map[string]string {
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// the journal records when and where the chart was generated.
+		if d.IsDir() && d.Name() == "journal" && filepath.Base(filepath.Dir(p)) == cacheDirName {
+			return filepath.SkipDir
+		}
+		if d.IsDir() {
+			return nil
+		}
+		// the lock file is kept once the project is unlocked.
+		if d.Name() == fsutil.LockFileName && filepath.Base(filepath.Dir(p)) == cacheDirName {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	require.NoError(t, err)
+	return files
 }
 
 type FakeCachedDiscovery struct {
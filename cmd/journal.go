@@ -0,0 +1,320 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/redhat-developer/helm-dump/pkg/fsutil"
+	"github.com/redhat-developer/helm-dump/pkg/journal"
+)
+
+// journalDirName is the name of the directory holding a project's journal, relative to its .helm-dump directory.
+const journalDirName = "journal"
+
+// projectJournal returns the journal of the operations applied to the chart in projectRoot.
+func projectJournal(projectRoot string) *journal.Journal {
+	return &journal.Journal{
+		ChartDir: projectRoot,
+		RootDir:  filepath.Join(projectRoot, cacheDirName, journalDirName),
+	}
+}
+
+// snapshotChartDir returns the contents of the chart's files, including its cache so undoing an operation also
+// restores the snapshots it changed; the cache is kept in cacheDir if informed, and its files outside of chartDir are
+// indexed by their absolute paths. The journal and the locks aren't included.
+func snapshotChartDir(chartDir string, cacheDir string) (map[string][]byte, error) {
+	skip := []string{path.Join(cacheDirName, journalDirName), path.Join(cacheDirName, fsutil.LockFileName)}
+	if cacheDir == "" {
+		return journal.Snapshot(chartDir, skip...)
+	}
+
+	absChartDir, err := filepath.Abs(chartDir)
+	if err != nil {
+		return nil, err
+	}
+	absCacheDir, err := filepath.Abs(cacheDir)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(absChartDir, absCacheDir)
+	if err != nil {
+		return nil, err
+	}
+	if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return journal.Snapshot(chartDir, append(skip, path.Join(filepath.ToSlash(rel), fsutil.LockFileName))...)
+	}
+
+	files, err := journal.Snapshot(chartDir, skip...)
+	if err != nil {
+		return nil, err
+	}
+	cacheFiles, err := journal.Snapshot(absCacheDir, fsutil.LockFileName)
+	if err != nil {
+		return nil, err
+	}
+	for name, data := range cacheFiles {
+		files[path.Join(filepath.ToSlash(absCacheDir), name)] = data
+	}
+	return files, nil
+}
+
+// recordOperation records command, invoked with args, in the journal of the chart in chartDir, whose files, along
+// with the ones of its cache in cacheDir if informed, had the before contents.
+func recordOperation(chartDir string, cacheDir string, command string, args []string, before map[string][]byte) error {
+	after, err := snapshotChartDir(chartDir, cacheDir)
+	if err != nil {
+		return err
+	}
+	_, err = projectJournal(chartDir).Record(command, args, before, after)
+	if err != nil {
+		return fmt.Errorf("error recording %s in the journal: %w", command, err)
+	}
+	return nil
+}
+
+// commandArgs returns the flags informed to cmd followed by args, as recorded in the journal.
+func commandArgs(cmd *cobra.Command, args []string) []string {
+	var out []string
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		out = append(out, fmt.Sprintf("--%s=%s", flag.Name, flag.Value.String()))
+	})
+	return append(out, args...)
+}
+
+type HistoryCommand struct {
+	*cobra.Command
+	Logger      *logrus.Logger
+	ProjectRoot string
+}
+
+func NewHistoryCmd(logger *logrus.Logger) (*HistoryCommand, error) {
+	cmd := &HistoryCommand{
+		Logger: logger,
+		Command: &cobra.Command{
+			Use:   "history",
+			Short: "list the operations applied to the chart",
+			Args:  cobra.NoArgs,
+		},
+	}
+
+	cmd.PersistentFlags().StringVarP(&cmd.ProjectRoot, "project-root", "d", ".", "The project root directory")
+
+	cmd.Command.RunE = cmd.runE
+
+	return cmd, nil
+}
+
+func (c *HistoryCommand) runE(cmd *cobra.Command, _ []string) error {
+	entries, err := projectJournal(c.ProjectRoot).Entries()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tTIME\tCOMMAND\tFILES\tARGS")
+	for _, entry := range entries {
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\n",
+			entry.ID, entry.Time.Format("2006-01-02T15:04:05Z"), entry.Command, len(entry.Changes), strings.Join(entry.Args, " "))
+	}
+	return w.Flush()
+}
+
+type UndoCommand struct {
+	*cobra.Command
+	Logger      *logrus.Logger
+	ProjectRoot string
+	Force       bool
+	CacheDir    string
+	LockTimeout time.Duration
+}
+
+func NewUndoCmd(logger *logrus.Logger) (*UndoCommand, error) {
+	cmd := &UndoCommand{
+		Logger: logger,
+		Command: &cobra.Command{
+			Use:   "undo",
+			Short: "revert the last operation applied to the chart",
+			Args:  cobra.NoArgs,
+		},
+	}
+
+	cmd.PersistentFlags().StringVarP(&cmd.ProjectRoot, "project-root", "d", ".", "The project root directory")
+	cmd.PersistentFlags().BoolVar(&cmd.Force, "force", false, "Revert the operation even if its files were modified since")
+	addCacheDirFlag(cmd.PersistentFlags(), &cmd.CacheDir)
+	addLockTimeoutFlag(cmd.PersistentFlags(), &cmd.LockTimeout)
+
+	cmd.Command.RunE = cmd.runE
+
+	return cmd, nil
+}
+
+func (c *UndoCommand) runE(cmd *cobra.Command, _ []string) error {
+	unlock, err := lockProject(c.ProjectRoot, c.CacheDir, c.LockTimeout, c.Logger)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	j := projectJournal(c.ProjectRoot)
+	if err := checkUndoCacheDir(j, c.CacheDir); err != nil {
+		return err
+	}
+	entry, err := j.Undo(c.Force)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%s #%d undone, %d file(s) restored\n", entry.Command, entry.ID, len(entry.Changes))
+	return nil
+}
+
+// checkUndoCacheDir returns an error if the last operation recorded in j changed files outside the chart which
+// aren't in cacheDir, such as the ones of a cache kept elsewhere, since they'd be restored without holding its lock.
+func checkUndoCacheDir(j *journal.Journal, cacheDir string) error {
+	entries, err := j.Entries()
+	if err != nil || len(entries) == 0 {
+		return err
+	}
+	entry := entries[len(entries)-1]
+
+	absCacheDir := ""
+	if cacheDir != "" {
+		if absCacheDir, err = filepath.Abs(cacheDir); err != nil {
+			return err
+		}
+	}
+	for _, change := range entry.Changes {
+		name := filepath.FromSlash(change.Name)
+		if !filepath.IsAbs(name) {
+			continue
+		}
+		if absCacheDir != "" {
+			rel, err := filepath.Rel(absCacheDir, name)
+			if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				continue
+			}
+		}
+		return fmt.Errorf("%s #%d changed %s, outside the chart; use --cache-dir to inform the cache holding it", entry.Command, entry.ID, name)
+	}
+	return nil
+}
+
+type ReplayCommand struct {
+	*cobra.Command
+	Logger      *logrus.Logger
+	ProjectRoot string
+	From        string
+	CacheDir    string
+	LockTimeout time.Duration
+}
+
+func NewReplayCmd(logger *logrus.Logger) (*ReplayCommand, error) {
+	cmd := &ReplayCommand{
+		Logger: logger,
+		Command: &cobra.Command{
+			Use:   "replay",
+			Short: "re-apply the operations recorded for a chart onto a regenerated chart",
+			Long: "Re-applies the move-to-values, move-to-template, values mv and values dedupe operations applied to the " +
+				"chart generated by the init before the last one, or, with --from, the ones applied to another chart since " +
+				"it was generated.",
+			Args: cobra.NoArgs,
+		},
+	}
+
+	cmd.PersistentFlags().StringVarP(&cmd.ProjectRoot, "project-root", "d", ".", "The project root directory")
+	cmd.PersistentFlags().StringVar(&cmd.From, "from", "", "The root directory of the chart whose operations are replayed")
+	addCacheDirFlag(cmd.PersistentFlags(), &cmd.CacheDir)
+	addLockTimeoutFlag(cmd.PersistentFlags(), &cmd.LockTimeout)
+
+	cmd.Command.RunE = cmd.runE
+
+	return cmd, nil
+}
+
+func (c *ReplayCommand) runE(cmd *cobra.Command, _ []string) error {
+	var entries []*journal.Entry
+	if c.From == "" {
+		all, err := projectJournal(c.ProjectRoot).Entries()
+		if err != nil {
+			return err
+		}
+		entries = journal.PreviousGeneration(all)
+	} else {
+		all, err := projectJournal(c.From).Entries()
+		if err != nil {
+			return err
+		}
+		entries = journal.LastGeneration(all)
+	}
+
+	projectRoot, err := inPlaceChartDir(c.ProjectRoot)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := lockProject(projectRoot, c.CacheDir, c.LockTimeout, c.Logger)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	replayed := 0
+	for _, entry := range entries {
+		var err error
+		switch {
+		case entry.Command == moveToValuesCommand && len(entry.Args) == 4:
+			// the chart is written in place.
+			err = moveToValues(projectRoot, filepath.Dir(projectRoot), c.CacheDir, entry.Args, c.LockTimeout, c.Logger)
+		case entry.Command == moveToTemplateCommand && len(entry.Args) == 1:
+			_, err = moveToTemplate(projectRoot, c.CacheDir, entry.Args, c.Logger)
+		case entry.Command == valuesMvCommand && len(entry.Args) == 2:
+			_, err = moveValue(projectRoot, entry.Args, c.Logger)
+		case entry.Command == valuesDedupeCommand && len(entry.Args) > 0:
+			var dups []DuplicateValue
+			if dups, err = parseDuplicateValues(entry.Args); err == nil {
+				_, err = consolidateValues(projectRoot, dups, c.Logger)
+			}
+		case entry.Command == moveToValuesCommand || entry.Command == moveToTemplateCommand || entry.Command == valuesMvCommand ||
+			entry.Command == valuesDedupeCommand:
+			return fmt.Errorf("%s #%d has invalid arguments %v", entry.Command, entry.ID, entry.Args)
+		default:
+			c.Logger.Warnf("%s #%d can't be replayed", entry.Command, entry.ID)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error replaying %s #%d: %w", entry.Command, entry.ID, err)
+		}
+		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%s #%d replayed: %s\n", entry.Command, entry.ID, strings.Join(entry.Args, " "))
+		replayed++
+	}
+	if replayed == 0 {
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), "no operations to replay")
+	}
+	return nil
+}
+
+func init() {
+	logger := logrus.New()
+	logger.Level = logrus.DebugLevel
+
+	historyCmd, err := NewHistoryCmd(logger)
+	if err != nil {
+		panic(err)
+	}
+	undoCmd, err := NewUndoCmd(logger)
+	if err != nil {
+		panic(err)
+	}
+	replayCmd, err := NewReplayCmd(logger)
+	if err != nil {
+		panic(err)
+	}
+	rootCmd.AddCommand(historyCmd.Command, undoCmd.Command, replayCmd.Command)
+}
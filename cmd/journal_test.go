@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	hdtesting "github.com/redhat-developer/helm-dump/pkg/test"
+)
+
+func TestJournalCmds(t *testing.T) {
+	logger := logrus.New()
+	logger.Level = logrus.DebugLevel
+
+	moveField := func(t *testing.T, projectRoot string, path string, key string) {
+		cmd, err := NewMoveToValuesCmd(logger)
+		require.NoError(t, err)
+		cmd.SetArgs([]string{
+			"-d", projectRoot,
+			"-o", filepath.Dir(projectRoot),
+			"apps/v1",
+			"Deployment",
+			path,
+			key,
+		})
+		require.NoError(t, cmd.Execute())
+	}
+
+	moveReplicas := func(t *testing.T, projectRoot string) {
+		moveField(t, projectRoot, `.spec.replicas`, `{{ resourceName . }}.replicas`)
+	}
+
+	history := func(t *testing.T, projectRoot string) string {
+		cmd, err := NewHistoryCmd(logger)
+		require.NoError(t, err)
+		outBuf := bytes.NewBufferString("")
+		cmd.SetArgs([]string{"-d", projectRoot})
+		cmd.SetOut(outBuf)
+		require.NoError(t, cmd.Execute())
+		return outBuf.String()
+	}
+
+	t.Run("undo", func(t *testing.T) {
+		// Arrange
+		projectRoot := inPlaceChart(t)
+		before := readChartDir(t, projectRoot)
+		moveReplicas(t, projectRoot)
+		require.Regexp(t, `1\s+\S+\s+move-to-values\s+2\s+apps/v1 Deployment .spec.replicas`, history(t, projectRoot))
+
+		cmd, err := NewUndoCmd(logger)
+		require.NoError(t, err)
+		cmd.SetArgs([]string{"-d", projectRoot})
+
+		// Act
+		require.NoError(t, cmd.Execute())
+
+		// Assert
+		require.Equal(t, before, readChartDir(t, projectRoot), "the chart should be restored")
+		require.NotContains(t, history(t, projectRoot), "move-to-values")
+	})
+
+	t.Run("undo-cache-changes", func(t *testing.T) {
+		// Arrange
+		projectRoot := inPlaceChart(t)
+		moveReplicas(t, projectRoot)
+		// the inlined value differs from the one in the snapshot, which is updated.
+		require.NoError(t, os.WriteFile(filepath.Join(projectRoot, "values.yaml"), []byte("nginx:\n  replicas: 5\n"), 0644))
+		before := readChartDir(t, projectRoot)
+		moveToTemplate, err := NewMoveToTemplateCmd(logger)
+		require.NoError(t, err)
+		moveToTemplate.SetArgs([]string{"-d", projectRoot, "nginx.replicas"})
+		moveToTemplate.SetOut(bytes.NewBufferString(""))
+		require.NoError(t, moveToTemplate.Execute())
+		require.NotEqual(t, before[".helm-dump/manifest.json"], readChartDir(t, projectRoot)[".helm-dump/manifest.json"])
+
+		cmd, err := NewUndoCmd(logger)
+		require.NoError(t, err)
+		cmd.SetArgs([]string{"-d", projectRoot})
+
+		// Act
+		require.NoError(t, cmd.Execute())
+
+		// Assert
+		require.Equal(t, before, readChartDir(t, projectRoot), "the chart and its cache should be restored")
+	})
+
+	t.Run("undo-external-cache-changes", func(t *testing.T) {
+		// Arrange
+		projectRoot := inPlaceChart(t)
+		require.NoError(t, os.RemoveAll(filepath.Join(projectRoot, cacheDirName)))
+		cacheDir := filepath.Join(hdtesting.TempDir(t), "cache")
+		moveToValues, err := NewMoveToValuesCmd(logger)
+		require.NoError(t, err)
+		moveToValues.SetArgs([]string{"-d", projectRoot, "-o", filepath.Dir(projectRoot), "--cache-dir", cacheDir,
+			"apps/v1", "Deployment", `.spec.replicas`, `{{ resourceName . }}.replicas`})
+		require.NoError(t, moveToValues.Execute())
+		require.NoError(t, os.WriteFile(filepath.Join(projectRoot, "values.yaml"), []byte("nginx:\n  replicas: 5\n"), 0644))
+		before := readChartDir(t, cacheDir)
+		moveToTemplate, err := NewMoveToTemplateCmd(logger)
+		require.NoError(t, err)
+		moveToTemplate.SetArgs([]string{"-d", projectRoot, "--cache-dir", cacheDir, "nginx.replicas"})
+		moveToTemplate.SetOut(bytes.NewBufferString(""))
+		require.NoError(t, moveToTemplate.Execute())
+
+		undo := func(args ...string) error {
+			cmd, err := NewUndoCmd(logger)
+			require.NoError(t, err)
+			cmd.SetArgs(append([]string{"-d", projectRoot}, args...))
+			cmd.SetOut(bytes.NewBufferString(""))
+			return cmd.Execute()
+		}
+
+		// Act
+		withoutCacheDirErr := undo()
+		err = undo("--cache-dir", cacheDir)
+
+		// Assert
+		require.Error(t, withoutCacheDirErr, "the cache shouldn't be restored without holding its lock")
+		require.Contains(t, withoutCacheDirErr.Error(), "use --cache-dir")
+		require.NoError(t, err)
+		require.Equal(t, before, readChartDir(t, cacheDir), "the cache should be restored")
+	})
+
+	t.Run("undo-modified-chart", func(t *testing.T) {
+		// Arrange
+		projectRoot := inPlaceChart(t)
+		moveReplicas(t, projectRoot)
+		require.NoError(t, os.WriteFile(filepath.Join(projectRoot, "values.yaml"), []byte("edited: true\n"), 0644))
+
+		cmd, err := NewUndoCmd(logger)
+		require.NoError(t, err)
+		cmd.SetArgs([]string{"-d", projectRoot})
+
+		// Act & Assert
+		require.Error(t, cmd.Execute(), "files modified since the operation shouldn't be overwritten")
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		// Arrange
+		outDir := hdtesting.TempDir(t)
+		projectRoot := filepath.Join(outDir, "my-chart")
+		runInit := func() {
+			cmd, _, _ := makeInitCommandWorld(
+				genericclioptions.NewConfigFlags(true),
+				logger,
+				hdtesting.LoadYamlFixture(t, "init_test/minimum-required-arguments/nginx-deployment.yaml"))
+			cmd.SetArgs([]string{"--namespace", "default", "--output-format", "dir", "my-chart", outDir})
+			require.NoError(t, cmd.Execute())
+		}
+		runInit()
+		moveReplicas(t, projectRoot)
+		runInit()
+
+		cmd, err := NewReplayCmd(logger)
+		require.NoError(t, err)
+		outBuf := bytes.NewBufferString("")
+		cmd.SetArgs([]string{"-d", projectRoot})
+		cmd.SetOut(outBuf)
+
+		// Act
+		require.NoError(t, cmd.Execute())
+
+		// Assert
+		require.Contains(t, outBuf.String(), "move-to-values #2 replayed")
+		chrt, err := loader.LoadDir(projectRoot)
+		require.NoError(t, err)
+		require.Contains(t, chrt.Values, "nginx-deployment", "the moved value should be in values.yaml again")
+		require.Regexp(t, `(?s)init.*move-to-values.*init.*move-to-values`, history(t, projectRoot))
+	})
+
+	t.Run("replay-several-operations", func(t *testing.T) {
+		// Arrange
+		outDir := hdtesting.TempDir(t)
+		projectRoot := filepath.Join(outDir, "my-chart")
+		runInit := func() {
+			cmd, _, _ := makeInitCommandWorld(
+				genericclioptions.NewConfigFlags(true),
+				logger,
+				hdtesting.LoadYamlFixture(t, "init_test/minimum-required-arguments/nginx-deployment.yaml"))
+			cmd.SetArgs([]string{"--namespace", "default", "--output-format", "dir", "my-chart", outDir})
+			require.NoError(t, cmd.Execute())
+		}
+		runInit()
+		moveReplicas(t, projectRoot)
+		moveField(t, projectRoot, `.spec.template.spec.containers[0].image`, `{{ resourceName . }}.image`)
+		runInit()
+
+		cmd, err := NewReplayCmd(logger)
+		require.NoError(t, err)
+		cmd.SetArgs([]string{"-d", projectRoot})
+		cmd.SetOut(bytes.NewBufferString(""))
+
+		// Act
+		require.NoError(t, cmd.Execute())
+
+		// Assert
+		chrt, err := loader.LoadDir(projectRoot)
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{"replicas": "3", "image": "nginx:1.14.2"}, chrt.Values["nginx-deployment"])
+		var data string
+		for _, tmpl := range chrt.Templates {
+			data += string(tmpl.Data)
+		}
+		require.Contains(t, data, "replicas: {{ .Values.nginx-deployment.replicas }}", "every replayed operation should be kept")
+		require.Contains(t, data, "image: {{ .Values.nginx-deployment.image }}", "every replayed operation should be kept")
+	})
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultExcludedKinds are resources created and maintained by the cluster, which aren't meant to be part of a
+// chart.
+var defaultExcludedKinds = []string{
+	"events",
+	"endpoints",
+	"discovery.k8s.io/endpointslices",
+	"coordination.k8s.io/leases",
+	"metrics.k8s.io/pods",
+	"apps/controllerrevisions",
+}
+
+// kindSpec identifies API resources by kind, resource name, singular name or short name, optionally qualified by
+// group.
+type kindSpec struct {
+	raw      string
+	name     string
+	group    string
+	hasGroup bool
+}
+
+// parseKindSpec parses kinds informed as "kind", "group/kind" or "resource.group", as accepted by kubectl; the
+// core group can be informed as "core/kind".
+func parseKindSpec(s string) (kindSpec, error) {
+	spec := kindSpec{raw: s}
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch {
+	case strings.Contains(s, "/"):
+		parts := strings.Split(s, "/")
+		if len(parts) != 2 {
+			return kindSpec{}, fmt.Errorf("invalid kind %q; expected kind, group/kind or resource.group", spec.raw)
+		}
+		spec.group, spec.name, spec.hasGroup = parts[0], parts[1], true
+		if spec.group == "core" {
+			spec.group = ""
+		}
+	case strings.Contains(s, "."):
+		parts := strings.SplitN(s, ".", 2)
+		spec.name, spec.group, spec.hasGroup = parts[0], parts[1], true
+	default:
+		spec.name = s
+	}
+	if spec.name == "" {
+		return kindSpec{}, fmt.Errorf("invalid kind %q; expected kind, group/kind or resource.group", spec.raw)
+	}
+	return spec, nil
+}
+
+func (s kindSpec) matches(group string, resource metav1.APIResource) bool {
+	if s.hasGroup && s.group != group {
+		return false
+	}
+	if s.name == resource.Name || s.name == resource.SingularName || s.name == strings.ToLower(resource.Kind) {
+		return true
+	}
+	for _, shortName := range resource.ShortNames {
+		if s.name == shortName {
+			return true
+		}
+	}
+	return false
+}
+
+// kindFilter selects the API resources init lists.
+type kindFilter struct {
+	include []kindSpec
+	exclude []kindSpec
+	// matched records the include specs matching at least one resource.
+	matched map[string]bool
+}
+
+func newKindFilter(include []string, exclude []string) (*kindFilter, error) {
+	f := &kindFilter{matched: make(map[string]bool)}
+	for _, s := range include {
+		spec, err := parseKindSpec(s)
+		if err != nil {
+			return nil, err
+		}
+		f.include = append(f.include, spec)
+	}
+	for _, s := range exclude {
+		spec, err := parseKindSpec(s)
+		if err != nil {
+			return nil, err
+		}
+		f.exclude = append(f.exclude, spec)
+	}
+	return f, nil
+}
+
+// allows returns whether the resource should be listed: resources explicitly included are always listed, otherwise
+// resources are listed when no kinds are included and they aren't excluded.
+func (f *kindFilter) allows(group string, resource metav1.APIResource) bool {
+	included := false
+	for _, spec := range f.include {
+		if spec.matches(group, resource) {
+			f.matched[spec.raw] = true
+			included = true
+		}
+	}
+	if included {
+		return true
+	}
+	if len(f.include) > 0 {
+		return false
+	}
+	for _, spec := range f.exclude {
+		if spec.matches(group, resource) {
+			return false
+		}
+	}
+	return true
+}
+
+// unmatched returns the included kinds which didn't match any resource.
+func (f *kindFilter) unmatched() []string {
+	var unmatched []string
+	for _, spec := range f.include {
+		if !f.matched[spec.raw] {
+			unmatched = append(unmatched, spec.raw)
+		}
+	}
+	return unmatched
+}
+
+// isListable returns whether the resource supports the list verb.
+func isListable(resource metav1.APIResource) bool {
+	for _, verb := range resource.Verbs {
+		if verb == "list" {
+			return true
+		}
+	}
+	return false
+}
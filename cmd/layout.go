@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+)
+
+// defaultTemplateLayout places templates in a directory per kind, so resources of different kinds sharing a name
+// don't collide.
+const defaultTemplateLayout = `{{ .Kind | lower }}/{{ .Name }}.yaml`
+
+// layoutData is the data available to template layouts.
+type layoutData struct {
+	Kind       string
+	Name       string
+	Namespace  string
+	Group      string
+	Version    string
+	APIVersion string
+}
+
+// templateLayout computes the file names of the templates generated by init, relative to the chart's templates
+// directory.
+type templateLayout struct {
+	tmpl *template.Template
+}
+
+func newTemplateLayout(layout string) (*templateLayout, error) {
+	tmpl, err := template.New("layout").Funcs(sprig.TxtFuncMap()).Option("missingkey=error").Parse(layout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template layout %q: %w", layout, err)
+	}
+	return &templateLayout{tmpl: tmpl}, nil
+}
+
+// layoutDataFor returns the layout data for obj; the original name is used, while the kind is the transformed
+// object's, as it might have been replaced, for example by an ExternalSecret.
+func layoutDataFor(obj *collectedObject) layoutData {
+	gvk := obj.transformed.GroupVersionKind()
+	return layoutData{
+		Kind:       gvk.Kind,
+		Name:       obj.original.GetName(),
+		Namespace:  obj.original.GetNamespace(),
+		Group:      gvk.Group,
+		Version:    gvk.Version,
+		APIVersion: obj.transformed.GetAPIVersion(),
+	}
+}
+
+// fileName returns the file name of the template for the resource described by data.
+func (l *templateLayout) fileName(data layoutData) (string, error) {
+	var buf bytes.Buffer
+	err := l.tmpl.Execute(&buf, data)
+	if err != nil {
+		return "", fmt.Errorf("error executing template layout: %w", err)
+	}
+
+	name := path.Clean(strings.TrimSpace(buf.String()))
+	if path.IsAbs(name) || name == "." || strings.HasPrefix(name, "../") {
+		return "", fmt.Errorf("template layout produced %q for %s %q; a relative path is required", name, data.Kind, data.Name)
+	}
+	if ext := path.Ext(name); ext != ".yaml" && ext != ".yml" {
+		return "", fmt.Errorf("template layout produced %q for %s %q; a .yaml or .yml file is required", name, data.Kind, data.Name)
+	}
+	if strings.HasPrefix(path.Base(name), "_") {
+		return "", fmt.Errorf("template layout produced %q for %s %q; files starting with _ aren't rendered by Helm", name, data.Kind, data.Name)
+	}
+
+	return name, nil
+}
+
+// fileNames returns the file names of the templates for objs, which should be sorted so names are stable across
+// runs; names produced by the layout for more than one resource are disambiguated with a numeric suffix.
+func (l *templateLayout) fileNames(objs []*collectedObject) ([]string, map[string][]string, error) {
+	names := make([]string, 0, len(objs))
+	collisions := make(map[string][]string)
+	// names are compared case insensitively, as charts might be written to case insensitive filesystems.
+	used := make(map[string]bool)
+	for _, obj := range objs {
+		name, err := l.fileName(layoutDataFor(obj))
+		if err != nil {
+			return nil, nil, err
+		}
+		if used[strings.ToLower(name)] {
+			ext := path.Ext(name)
+			base := strings.TrimSuffix(name, ext)
+			candidate := name
+			for i := 2; used[strings.ToLower(candidate)]; i++ {
+				candidate = fmt.Sprintf("%s-%d%s", base, i, ext)
+			}
+			collisions[name] = append(collisions[name], candidate)
+			name = candidate
+		}
+		used[strings.ToLower(name)] = true
+		names = append(names, name)
+	}
+	return names, collisions, nil
+}
+
+// sortCollectedObjects sorts objs by group, kind, namespace, name and version, so charts are generated the same
+// way regardless of the order resources are listed in.
+func sortCollectedObjects(objs []*collectedObject) {
+	sort.SliceStable(objs, func(i, j int) bool {
+		a, b := objs[i].original, objs[j].original
+		ga, gb := a.GroupVersionKind(), b.GroupVersionKind()
+		if ga.Group != gb.Group {
+			return ga.Group < gb.Group
+		}
+		if ga.Kind != gb.Kind {
+			return ga.Kind < gb.Kind
+		}
+		if a.GetNamespace() != b.GetNamespace() {
+			return a.GetNamespace() < b.GetNamespace()
+		}
+		if a.GetName() != b.GetName() {
+			return a.GetName() < b.GetName()
+		}
+		return ga.Version < gb.Version
+	})
+}
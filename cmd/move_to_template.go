@@ -0,0 +1,508 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/redhat-developer/helm-dump/pkg/cache"
+	chartutil2 "github.com/redhat-developer/helm-dump/pkg/helm/chartutil"
+	"github.com/redhat-developer/helm-dump/pkg/valuesref"
+	"github.com/redhat-developer/helm-dump/pkg/visitor"
+)
+
+// moveToTemplateCommand is the name move-to-template operations are recorded with in the journal.
+const moveToTemplateCommand = "move-to-template"
+
+type MoveToTemplateCommand struct {
+	*cobra.Command
+	Logger      *logrus.Logger
+	ProjectRoot string
+	CacheDir    string
+	LockTimeout time.Duration
+}
+
+func NewMoveToTemplateCmd(logger *logrus.Logger) (*MoveToTemplateCommand, error) {
+	cmd := &MoveToTemplateCommand{
+		Logger: logger,
+		Command: &cobra.Command{
+			Use:   moveToTemplateCommand + " key",
+			Short: "Inline a value from values.yaml back into the templates referencing it",
+			Long: "Replaces every {{ .Values.key }} in the chart's templates with the key's current value, and removes the key " +
+				"from values.yaml and values.schema.json. The chart is modified in place.",
+			Args: cobra.ExactArgs(1),
+		},
+	}
+
+	cmd.PersistentFlags().StringVarP(&cmd.ProjectRoot, "project-root", "d", ".", "The project root directory")
+	addCacheDirFlag(cmd.PersistentFlags(), &cmd.CacheDir)
+	addLockTimeoutFlag(cmd.PersistentFlags(), &cmd.LockTimeout)
+
+	cmd.Command.RunE = cmd.runE
+
+	return cmd, nil
+}
+
+func (c *MoveToTemplateCommand) runE(cmd *cobra.Command, args []string) error {
+	projectRoot, err := inPlaceChartDir(c.ProjectRoot)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := lockProject(projectRoot, c.CacheDir, c.LockTimeout, c.Logger)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	inlined, err := moveToTemplate(projectRoot, c.CacheDir, args, c.Logger)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%s inlined in %d reference(s)\n", args[0], inlined)
+	return nil
+}
+
+// inlinedReference is a reference to the moved key in a template, along with the text replacing it.
+type inlinedReference struct {
+	valuesref.Reference
+	text string
+	// wholeValue is whether the reference is the whole value of a YAML node, whose key is indented by indent spaces.
+	wholeValue bool
+	indent     int
+}
+
+// moveToTemplate inlines the value of the key informed by args in the templates of the chart in projectRoot, whose
+// cache is in cacheDir if informed, and removes the key from the chart's values; the chart is written in place and
+// the operation recorded in its journal. The number of inlined references is returned.
+func moveToTemplate(projectRoot string, cacheDir string, args []string, logger *logrus.Logger) (int, error) {
+	key := strings.Split(args[0], ".")
+	chrt, err := loader.LoadDir(projectRoot)
+	if err != nil {
+		return 0, fmt.Errorf("error loading chart from %q: %w", projectRoot, err)
+	}
+
+	value, found, err := unstructured.NestedFieldNoCopy(chrt.Values, key...)
+	if err != nil || !found {
+		return 0, fmt.Errorf("key %s not found in %s", args[0], filepath.Join(projectRoot, "values.yaml"))
+	}
+
+	refs, err := findInlinedReferences(chrt, key, value)
+	if err != nil {
+		return 0, err
+	}
+
+	before, err := snapshotChartDir(projectRoot, cacheDir)
+	if err != nil {
+		return 0, err
+	}
+
+	projectCache := projectCache(projectRoot, cacheDir)
+	snapshots := make(map[string][]byte)
+	inlined := 0
+	for _, tmpl := range chrt.Templates {
+		tmplRefs := refs[tmpl.Name]
+		if len(tmplRefs) == 0 {
+			continue
+		}
+		snapshot, err := inlineSnapshot(projectCache, tmpl, tmplRefs, value, logger)
+		if err != nil {
+			return 0, err
+		}
+		if snapshot != nil {
+			snapshots[tmpl.Name] = snapshot
+		}
+		tmpl.Data = inlineReferences(tmpl.Data, tmplRefs)
+		inlined += len(tmplRefs)
+	}
+	if inlined == 0 {
+		logger.Warnf("%s isn't referenced by any template", args[0])
+	}
+
+	removeValue(chrt.Values, key)
+	if err := appendValuesYaml(chrt, chrt.Values); err != nil {
+		return 0, err
+	}
+	if chrt.Schema != nil {
+		schema, err := removeSchemaProperty(chrt.Schema, key)
+		if err != nil {
+			return 0, err
+		}
+		chrt.Schema = schema
+	}
+
+	if err := saveChartDir(chrt, filepath.Dir(projectRoot)); err != nil {
+		return 0, fmt.Errorf("error saving chart: %w", err)
+	}
+	// the snapshots are stored once the chart is saved, so a failure leaves both untouched.
+	names := make([]string, 0, len(snapshots))
+	for name := range snapshots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := projectCache.Store(name, snapshots[name]); err != nil {
+			return 0, err
+		}
+	}
+	return inlined, recordOperation(projectRoot, cacheDir, moveToTemplateCommand, args, before)
+}
+
+// findInlinedReferences returns the references to key in the chart's templates, indexed by template name, along
+// with the text replacing them; it fails if key, the values holding it or the ones below it are referenced in other
+// ways, such as in pipelines or conditions, which can't be inlined.
+func findInlinedReferences(chrt *chart.Chart, key []string, value interface{}) (map[string][]inlinedReference, error) {
+	refs := make(map[string][]inlinedReference)
+	var unsupported []string
+	for _, tmpl := range chrt.Templates {
+		found, err := valuesref.Find(tmpl.Name, tmpl.Data)
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range found {
+			if !ref.Overlaps(key) {
+				continue
+			}
+			if !ref.Standalone || len(ref.Key) != len(key) {
+				unsupported = append(unsupported, fmt.Sprintf("%s:%d: %s", tmpl.Name, ref.Line, tmpl.Data[ref.Begin:ref.End]))
+				continue
+			}
+			inlined, err := inlineReference(tmpl, ref, value)
+			if err != nil {
+				return nil, err
+			}
+			refs[tmpl.Name] = append(refs[tmpl.Name], inlined)
+		}
+	}
+	if len(unsupported) > 0 {
+		return nil, fmt.Errorf("%s is used in ways which can't be inlined:\n  %s", strings.Join(key, "."), strings.Join(unsupported, "\n  "))
+	}
+	return refs, nil
+}
+
+// wholeValuePrefixRegexp matches the text preceding a YAML node's value in its line, such as "  replicas: " or "- ".
+var wholeValuePrefixRegexp = regexp.MustCompile(`^(\s*(?:- +)*)(?:[^\s#'"{][^#]*:|-)\s+$`)
+
+// inlineReference returns ref with the text replacing it in tmpl: value as a YAML literal if ref is the whole value
+// of a YAML node, and as rendered by Helm otherwise. The literal's type may be corrected by inlineSnapshot, which
+// knows the node the value was moved from; references piped to keep the style of a string are inlined as strings.
+func inlineReference(tmpl *chart.File, ref valuesref.Reference, value interface{}) (inlinedReference, error) {
+	lineStart := strings.LastIndexByte(string(tmpl.Data[:ref.ActionBegin]), '\n') + 1
+	lineStop := len(tmpl.Data)
+	if i := strings.IndexByte(string(tmpl.Data[ref.ActionEnd:]), '\n'); i >= 0 {
+		lineStop = ref.ActionEnd + i
+	}
+	prefix := string(tmpl.Data[lineStart:ref.ActionBegin])
+	suffix := strings.TrimSpace(string(tmpl.Data[ref.ActionEnd:lineStop]))
+
+	match := wholeValuePrefixRegexp.FindStringSubmatch(prefix)
+	if !isYAMLTemplate(tmpl) || match == nil || (suffix != "" && !strings.HasPrefix(suffix, "#")) {
+		text, err := renderPipe(value, ref.Pipe)
+		if err != nil {
+			return inlinedReference{}, err
+		}
+		return inlinedReference{Reference: ref, text: text}, nil
+	}
+
+	// the value's continuation lines are indented more than its key.
+	indent := len(match[1])
+	text, err := inlinedLiteral(value, ref.Pipe != "", indent)
+	if err != nil {
+		return inlinedReference{}, err
+	}
+	return inlinedReference{Reference: ref, text: text, wholeValue: true, indent: indent}, nil
+}
+
+// nindentPipeRegexp matches the pipeline keeping the style of a block scalar, capturing its indentation.
+var nindentPipeRegexp = regexp.MustCompile(`^toYaml \| nindent (\d+)$`)
+
+// renderPipe returns value as rendered by Helm when piped to pipe, one of the pipelines of valuesref.Reference.
+func renderPipe(value interface{}, pipe string) (string, error) {
+	if pipe == "" {
+		return fmt.Sprint(value), nil
+	}
+	if pipe == "quote" {
+		return strconv.Quote(fmt.Sprint(value)), nil
+	}
+	match := nindentPipeRegexp.FindStringSubmatch(pipe)
+	if match == nil {
+		return "", fmt.Errorf("unsupported pipeline %q", pipe)
+	}
+	indent, _ := strconv.Atoi(match[1])
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling %v: %w", value, err)
+	}
+	padding := strings.Repeat(" ", indent)
+	return "\n" + padding + strings.ReplaceAll(strings.TrimSuffix(string(data), "\n"), "\n", "\n"+padding), nil
+}
+
+// inlinedLiteral returns value as the YAML value of a key indented by indent spaces, with the type of the node it
+// was moved from: move-to-values extracts scalars as strings, so numbers and booleans are inlined unquoted unless
+// the node was quoted, or a block scalar, in which case the value is inlined as a string whatever its type in
+// values.yaml.
+func inlinedLiteral(value interface{}, quoted bool, indent int) (string, error) {
+	if quoted {
+		switch v := value.(type) {
+		case float64:
+			value = strconv.FormatFloat(v, 'f', -1, 64)
+		case int, int64, bool:
+			value = fmt.Sprint(v)
+		}
+	} else if s, ok := value.(string); ok && isUnquotedScalar(s) {
+		return s, nil
+	}
+	return yamlLiteral(value, indent)
+}
+
+// isUnquotedScalar returns whether s, written unquoted, is read as a number or a boolean.
+func isUnquotedScalar(s string) bool {
+	var parsed interface{}
+	if strings.ContainsAny(s, "\n#") || yaml.Unmarshal([]byte(s), &parsed) != nil {
+		return false
+	}
+	switch parsed.(type) {
+	case float64:
+		return true
+	case bool:
+		return s == "true" || s == "false"
+	}
+	return false
+}
+
+// yamlLiteral returns value as the YAML value of a key indented by indent spaces.
+func yamlLiteral(value interface{}, indent int) (string, error) {
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling %v: %w", value, err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) == 1 {
+		return lines[0], nil
+	}
+
+	padding := strings.Repeat(" ", indent)
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		// collections start in the line following their key.
+		return "\n" + padding + "  " + strings.Join(lines, "\n"+padding+"  "), nil
+	default:
+		// block scalars are indented by the marshaller relatively to their header.
+		return strings.Join(lines, "\n"+padding), nil
+	}
+}
+
+// inlineReferences returns data with refs replaced by their text, removing the spaces trimmed by their actions.
+func inlineReferences(data []byte, refs []inlinedReference) []byte {
+	var out strings.Builder
+	offset := 0
+	for _, ref := range refs {
+		begin, end := ref.ActionBegin, ref.ActionEnd
+		if ref.TrimLeft {
+			for begin > offset && isTrimmedSpace(data[begin-1]) {
+				begin--
+			}
+		}
+		if ref.TrimRight {
+			for end < len(data) && isTrimmedSpace(data[end]) {
+				end++
+			}
+		}
+		out.Write(data[offset:begin])
+		out.WriteString(ref.text)
+		offset = end
+	}
+	out.Write(data[offset:])
+	return []byte(out.String())
+}
+
+func isTrimmedSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// referencePaths returns the YAML paths, such as .spec.replicas, of the nodes whose whole value is one of refs,
+// indexed by the reference's index in refs.
+func referencePaths(tmpl *chart.File, refs []inlinedReference) (map[int]string, error) {
+	var probe strings.Builder
+	offset := 0
+	for i, ref := range refs {
+		if !ref.wholeValue {
+			continue
+		}
+		probe.Write(tmpl.Data[offset:ref.ActionBegin])
+		probe.WriteString(probePrefix + strconv.Itoa(i))
+		offset = ref.ActionEnd
+	}
+	probe.Write(tmpl.Data[offset:])
+
+	// other actions are masked, so the template can be parsed as YAML.
+	masked := chartutil2.MaskActions([]byte(probe.String()))
+	file, err := parser.ParseBytes(masked, 0)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing template %s: %w", tmpl.Name, err)
+	}
+	finder := &probeFinder{paths: make(map[int]string)}
+	for _, doc := range file.Docs {
+		ast.Walk(finder, doc)
+	}
+	return finder.paths, nil
+}
+
+// probePrefix prefixes the index of the reference replaced by a probe in referencePaths.
+const probePrefix = "helm-dump-inlined-"
+
+type probeFinder struct {
+	paths map[int]string
+}
+
+func (f *probeFinder) Visit(node ast.Node) ast.Visitor {
+	if n, ok := node.(*ast.StringNode); ok && strings.HasPrefix(n.Value, probePrefix) {
+		if i, err := strconv.Atoi(strings.TrimPrefix(n.Value, probePrefix)); err == nil {
+			f.paths[i] = strings.TrimPrefix(n.GetPath(), "$")
+		}
+	}
+	return f
+}
+
+// inlineSnapshot returns the cached snapshot of tmpl with value replacing the nodes whose whole value is one of
+// refs, so the inlined value is kept by later move-to-values operations, or nil if tmpl has no snapshot. The text of
+// refs is updated to keep the type of the snapshot's nodes.
+func inlineSnapshot(projectCache cache.Store, tmpl *chart.File, refs []inlinedReference, value interface{}, logger *logrus.Logger) ([]byte, error) {
+	exists, err := projectCache.Exists(tmpl.Name)
+	if err != nil || !exists {
+		return nil, err
+	}
+	paths, err := referencePaths(tmpl, refs)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	snapshot, err := projectCache.Load(tmpl.Name)
+	if err != nil {
+		return nil, err
+	}
+	masked := chartutil2.MaskStandaloneActions(snapshot)
+	file, err := parser.ParseBytes(masked, 0)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the snapshot of %s: %w", tmpl.Name, err)
+	}
+
+	// quoted tells, for each path, whether its node is a quoted or block string in the snapshot.
+	quoted := make(map[string]bool)
+	var patches []visitor.Patch
+	for _, i := range sortedIndexes(paths) {
+		path := paths[i]
+		if _, collected := quoted[path]; !collected {
+			pathPatches, err := collectPatches(path, masked, file.Docs[0])
+			if err != nil {
+				return nil, err
+			}
+			if len(pathPatches) == 0 {
+				logger.Warnf("%s not found in the snapshot of %s", path, tmpl.Name)
+			}
+			for j := range pathPatches {
+				pathPatches[j].ValuesKey = strings.Join(refs[i].Key, ".")
+				quoted[path] = quoted[path] || pathPatches[j].Style != visitor.PlainStyle
+			}
+			patches = append(patches, pathPatches...)
+		}
+		if refs[i].text, err = inlinedLiteral(value, quoted[path] || refs[i].Pipe != "", refs[i].indent); err != nil {
+			return nil, err
+		}
+	}
+
+	patches, conflicts := visitor.SortPatches(patches)
+	for _, conflict := range conflicts {
+		logger.WithError(conflict).Errorf("conflicting patches in the snapshot of %s; the latter is ignored", tmpl.Name)
+	}
+	for i := len(patches) - 1; i >= 0; i-- {
+		p := patches[i]
+		lineStart := strings.LastIndexByte(string(snapshot[:p.BeginOffset]), '\n') + 1
+		match := wholeValuePrefixRegexp.FindStringSubmatch(string(snapshot[lineStart:p.BeginOffset]))
+		indent := 0
+		if match != nil {
+			indent = len(match[1])
+		}
+		text, err := inlinedLiteral(value, quoted[p.Path], indent)
+		if err != nil {
+			return nil, err
+		}
+		if p.Comment != "" {
+			// the comment follows the header of a block scalar.
+			if i := strings.IndexByte(text, '\n'); i >= 0 {
+				text = text[:i] + " " + p.Comment + text[i:]
+			} else {
+				text += " " + p.Comment
+			}
+		}
+		snapshot = bytes.Join([][]byte{snapshot[:p.BeginOffset], []byte(text), snapshot[p.EndOffset:]}, nil)
+	}
+	return snapshot, nil
+}
+
+func sortedIndexes(m map[int]string) []int {
+	indexes := make([]int, 0, len(m))
+	for i := range m {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// removeValue removes key from values, along with the maps left empty.
+func removeValue(values map[string]interface{}, key []string) {
+	if len(key) == 1 {
+		delete(values, key[0])
+		return
+	}
+	child, ok := values[key[0]].(map[string]interface{})
+	if !ok {
+		return
+	}
+	removeValue(child, key[1:])
+	if len(child) == 0 {
+		delete(values, key[0])
+	}
+}
+
+// removeSchemaProperty returns the JSON schema of values without the property describing key; schema is returned
+// as is if it doesn't describe key.
+func removeSchemaProperty(schema []byte, key []string) ([]byte, error) {
+	root := make(map[string]interface{})
+	if err := json.Unmarshal(schema, &root); err != nil {
+		return nil, fmt.Errorf("error parsing values.schema.json: %w", err)
+	}
+	if _, _, found := takeSchemaProperty(root, key); !found {
+		return schema, nil
+	}
+	return marshalSchema(root)
+}
+
+func init() {
+	logger := logrus.New()
+	logger.Level = logrus.DebugLevel
+
+	cmd, err := NewMoveToTemplateCmd(logger)
+	if err != nil {
+		panic(err)
+	}
+	rootCmd.AddCommand(cmd.Command)
+}
@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoveToTemplateCmd(t *testing.T) {
+	logger := logrus.New()
+	logger.Level = logrus.DebugLevel
+
+	t.Run("inline-value", func(t *testing.T) {
+		// Arrange
+		projectRoot := movedChart(t, logger, "nginx:\n  replicas: 5\nother: true\n")
+
+		// Act
+		_, err := runCmd(t, logger, "move-to-template", "-d", projectRoot, "nginx.replicas")
+
+		// Assert
+		require.NoError(t, err)
+		require.Contains(t, readFile(t, filepath.Join(projectRoot, movedTemplateName)), "\n  replicas: 5\n")
+		require.Equal(t, "other: true\n", readFile(t, filepath.Join(projectRoot, "values.yaml")), "the key and the maps left empty should be removed")
+		snapshot, err := projectCache(projectRoot, "").Load(movedTemplateName)
+		require.NoError(t, err)
+		require.Contains(t, string(snapshot), "\n  replicas: 5\n", "the snapshot should hold the inlined value")
+	})
+
+	t.Run("inline-extracted-value-and-schema", func(t *testing.T) {
+		// Arrange
+		projectRoot := movedChart(t, logger, "nginx:\n  replicas: \"3\"\n")
+		schema := `{"properties": {"nginx": {"properties": {"replicas": {"type": "string"}}, "required": ["replicas"]}}}`
+		require.NoError(t, os.WriteFile(filepath.Join(projectRoot, "values.schema.json"), []byte(schema), 0644))
+
+		// Act
+		_, err := runCmd(t, logger, "move-to-template", "-d", projectRoot, "nginx.replicas")
+
+		// Assert
+		require.NoError(t, err)
+		require.Contains(t, readFile(t, filepath.Join(projectRoot, movedTemplateName)), "\n  replicas: 3\n", "the node's type should be kept")
+		snapshot, err := projectCache(projectRoot, "").Load(movedTemplateName)
+		require.NoError(t, err)
+		require.Contains(t, string(snapshot), "\n  replicas: 3\n", "the node's type should be kept in the snapshot")
+		require.JSONEq(t, `{"properties": {"nginx": {"properties": {}, "required": []}}}`, readFile(t, filepath.Join(projectRoot, "values.schema.json")))
+	})
+
+	t.Run("inline-into-quoted-node", func(t *testing.T) {
+		// Arrange
+		projectRoot := movedChart(t, logger, "nginx:\n  replicas: 5\n")
+		store := projectCache(projectRoot, "")
+		snapshot, err := store.Load(movedTemplateName)
+		require.NoError(t, err)
+		require.NoError(t, store.Store(movedTemplateName, bytes.Replace(snapshot, []byte("replicas: 3"), []byte(`replicas: "3"`), 1)))
+
+		// Act
+		_, err = runCmd(t, logger, "move-to-template", "-d", projectRoot, "nginx.replicas")
+
+		// Assert
+		require.NoError(t, err)
+		require.Contains(t, readFile(t, filepath.Join(projectRoot, movedTemplateName)), "\n  replicas: \"5\"\n", "the node's type should be kept")
+	})
+
+	t.Run("snapshot-kept-on-failure", func(t *testing.T) {
+		// Arrange
+		projectRoot := movedChart(t, logger, "nginx:\n  replicas: 5\n")
+		require.NoError(t, os.WriteFile(filepath.Join(projectRoot, "values.schema.json"), []byte("{"), 0644))
+		snapshot, err := projectCache(projectRoot, "").Load(movedTemplateName)
+		require.NoError(t, err)
+
+		// Act
+		_, err = runCmd(t, logger, "move-to-template", "-d", projectRoot, "nginx.replicas")
+
+		// Assert
+		require.Error(t, err)
+		actual, err := projectCache(projectRoot, "").Load(movedTemplateName)
+		require.NoError(t, err)
+		require.Equal(t, string(snapshot), string(actual), "the snapshot shouldn't be modified")
+	})
+
+	t.Run("inline-quoted-and-block-scalars", func(t *testing.T) {
+		// Arrange
+		projectRoot := inPlaceChart(t)
+		cmPath := filepath.Join(projectRoot, "templates", "cm.yaml")
+		require.NoError(t, os.WriteFile(cmPath, []byte(scalarsConfigMap), 0644))
+		for _, field := range []string{"port", "script"} {
+			_, err := runCmd(t, logger, "move-to-values", "-d", projectRoot, "-o", filepath.Dir(projectRoot),
+				"v1", "ConfigMap", ".data."+field, "{{ resourceName . }}."+field)
+			require.NoError(t, err)
+		}
+		require.Contains(t, readFile(t, cmPath), "{{ .Values.cm.port | quote }}")
+
+		// Act
+		_, portErr := runCmd(t, logger, "move-to-template", "-d", projectRoot, "cm.port")
+		_, scriptErr := runCmd(t, logger, "move-to-template", "-d", projectRoot, "cm.script")
+
+		// Assert
+		require.NoError(t, portErr)
+		require.NoError(t, scriptErr)
+		require.Equal(t, scalarsConfigMap, readFile(t, cmPath), "the scalars should be inlined in their style")
+	})
+
+	t.Run("unsupported-reference", func(t *testing.T) {
+		// Arrange
+		projectRoot := movedChart(t, logger, "nginx:\n  replicas: 5\n")
+		templatePath := filepath.Join(projectRoot, movedTemplateName)
+		data := readFile(t, templatePath) + "---\n{{ if .Values.nginx }}# scaled{{ end }}\n"
+		require.NoError(t, os.WriteFile(templatePath, []byte(data), 0644))
+
+		// Act
+		_, err := runCmd(t, logger, "move-to-template", "-d", projectRoot, "nginx.replicas")
+
+		// Assert
+		require.Error(t, err)
+		require.Contains(t, err.Error(), movedTemplateName+":28: .Values.nginx")
+		require.Equal(t, data, readFile(t, templatePath), "the chart shouldn't be modified")
+	})
+
+	t.Run("unknown-key", func(t *testing.T) {
+		// Arrange
+		projectRoot := movedChart(t, logger, "nginx:\n  replicas: 5\n")
+
+		// Act
+		_, err := runCmd(t, logger, "move-to-template", "-d", projectRoot, "nginx.image")
+
+		// Assert
+		require.Error(t, err)
+	})
+}
+
+func TestYamlLiteral(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		indent   int
+		expected string
+	}{
+		{name: "integer", value: float64(3), expected: "3"},
+		{name: "numeric-string", value: "3", expected: `"3"`},
+		{name: "boolean", value: true, expected: "true"},
+		{name: "string", value: "nginx:1.21", expected: "nginx:1.21"},
+		{name: "multi-line-string", value: "a\nb", indent: 2, expected: "|-\n    a\n    b"},
+		{name: "map", value: map[string]interface{}{"a": float64(1), "b": "x"}, indent: 2, expected: "\n    a: 1\n    b: x"},
+		{name: "list", value: []interface{}{"a", "b"}, indent: 0, expected: "\n  - a\n  - b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			actual, err := yamlLiteral(tt.value, tt.indent)
+
+			// Assert
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestInlinedLiteral(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		quoted   bool
+		expected string
+	}{
+		{name: "numeric-string", value: "3", expected: "3"},
+		{name: "float-string", value: "1.10", expected: "1.10"},
+		{name: "boolean-string", value: "true", expected: "true"},
+		{name: "yes-string", value: "yes", expected: `"yes"`},
+		{name: "string", value: "nginx:1.21", expected: "nginx:1.21"},
+		{name: "numeric-string-of-quoted-node", value: "3", quoted: true, expected: `"3"`},
+		{name: "integer-of-quoted-node", value: float64(3), quoted: true, expected: `"3"`},
+		{name: "boolean-of-quoted-node", value: false, quoted: true, expected: `"false"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			actual, err := inlinedLiteral(tt.value, tt.quoted, 0)
+
+			// Assert
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
@@ -2,25 +2,30 @@ package cmd
 
 import (
 	"fmt"
+	"path/filepath"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
-
-	"helm.sh/helm/v3/pkg/chartutil"
 )
 
+// moveToValuesCommand is the name move-to-values operations are recorded with in the journal.
+const moveToValuesCommand = "move-to-values"
+
 type MoveToValuesCommand struct {
 	*cobra.Command
 	Logger      *logrus.Logger
 	ProjectRoot string
 	OutputDir   string
+	CacheDir    string
+	LockTimeout time.Duration
 }
 
 func NewMoveToValuesCmd(logger *logrus.Logger) (*MoveToValuesCommand, error) {
 	cmd := &MoveToValuesCommand{
 		Logger: logger,
 		Command: &cobra.Command{
-			Use:   "move-to-values api-version kind field template",
+			Use:   moveToValuesCommand + " api-version kind field template",
 			Short: "Move a value from a template into values.yaml",
 			Args:  cobra.ExactArgs(4),
 		},
@@ -28,6 +33,8 @@ func NewMoveToValuesCmd(logger *logrus.Logger) (*MoveToValuesCommand, error) {
 
 	cmd.PersistentFlags().StringVarP(&cmd.ProjectRoot, "project-root", "d", ".", "The project root directory")
 	cmd.PersistentFlags().StringVarP(&cmd.OutputDir, "output-directory", "o", "", "The output directory; if unspecified overwrites file in project-root")
+	addCacheDirFlag(cmd.PersistentFlags(), &cmd.CacheDir)
+	addLockTimeoutFlag(cmd.PersistentFlags(), &cmd.LockTimeout)
 
 	cmd.Command.RunE = cmd.runE
 
@@ -35,11 +42,24 @@ func NewMoveToValuesCmd(logger *logrus.Logger) (*MoveToValuesCommand, error) {
 }
 
 func (c *MoveToValuesCommand) runE(_ *cobra.Command, args []string) error {
+	unlock, err := lockProject(c.ProjectRoot, c.CacheDir, c.LockTimeout, c.Logger)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
-	chartBuilder, err := NewChartBuilder(c.ProjectRoot, c.OutputDir, c.Logger)
+	return moveToValues(c.ProjectRoot, c.OutputDir, c.CacheDir, args, c.LockTimeout, c.Logger)
+}
+
+// moveToValues applies the move-to-values action informed by args to the chart in projectRoot, whose cache is in
+// cacheDir if informed, writes the chart to outputDir and records the operation in the written chart's journal. The
+// caller must hold the lock of projectRoot; the written chart is locked within lockTimeout if it's elsewhere.
+func moveToValues(projectRoot string, outputDir string, cacheDir string, args []string, lockTimeout time.Duration, logger *logrus.Logger) error {
+	chartBuilder, err := NewChartBuilder(projectRoot, outputDir, logger)
 	if err != nil {
 		return fmt.Errorf("error creating builder: %w", err)
 	}
+	chartBuilder.Cache = projectCache(projectRoot, cacheDir)
 
 	chartBuilder.AddAction(&Action{
 		apiVersion: args[0],
@@ -53,12 +73,31 @@ func (c *MoveToValuesCommand) runE(_ *cobra.Command, args []string) error {
 		return fmt.Errorf("error building chart: %w", buildErr)
 	}
 
-	saveErr := chartutil.SaveDir(chrt, c.OutputDir)
+	chartDir := filepath.Join(outputDir, chrt.Name())
+	sameDir, err := isSameDir(projectRoot, chartDir)
+	if err != nil {
+		return err
+	}
+	if !sameDir {
+		unlock, err := lockProject(chartDir, "", lockTimeout, logger)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	// the snapshots stored by Build are the templates' original contents, so they're kept even if this is undone.
+	before, err := snapshotChartDir(chartDir, "")
+	if err != nil {
+		return err
+	}
+
+	saveErr := saveChartDir(chrt, outputDir)
 	if saveErr != nil {
 		return fmt.Errorf("error saving chart: %w", saveErr)
 	}
 
-	return nil
+	return recordOperation(chartDir, "", moveToValuesCommand, args, before)
 }
 
 func init() {
@@ -1,9 +1,14 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/redhat-developer/helm-dump/pkg/cache"
+	"github.com/redhat-developer/helm-dump/pkg/fsutil"
 	hdtesting "github.com/redhat-developer/helm-dump/pkg/test"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
@@ -11,6 +16,20 @@ import (
 	"k8s.io/apimachinery/pkg/api/equality"
 )
 
+// scalarsConfigMap is a ConfigMap template holding a quoted number and a block scalar.
+const scalarsConfigMap = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  annotations:
+    helm-dump/name: cm
+  name: cm
+data:
+  port: "8080"
+  script: |
+    echo a
+    echo b
+`
+
 func TestMoveToValuesCmd(t *testing.T) {
 	logger := logrus.New()
 	logger.Level = logrus.DebugLevel
@@ -28,7 +47,7 @@ func TestMoveToValuesCmd(t *testing.T) {
 	t.Run("extract-integer", func(t *testing.T) {
 		// Arrange
 		tempDir := hdtesting.TempDir(t)
-		inputDir := "move_to_values_test/extract-integer/input-chart"
+		inputDir := hdtesting.CopyDir(t, "move_to_values_test/extract-integer/input-chart")
 		expectedDir := "move_to_values_test/extract-integer/expected-chart"
 
 		cmd, err := NewMoveToValuesCmd(logger)
@@ -69,7 +88,7 @@ func TestMoveToValuesCmd(t *testing.T) {
 	t.Run("extract-string", func(t *testing.T) {
 		// Arrange
 		tempDir := hdtesting.TempDir(t)
-		inputDir := "move_to_values_test/extract-string/input-chart"
+		inputDir := hdtesting.CopyDir(t, "move_to_values_test/extract-string/input-chart")
 		expectedDir := "move_to_values_test/extract-string/expected-chart"
 
 		cmd, err := NewMoveToValuesCmd(logger)
@@ -107,4 +126,150 @@ func TestMoveToValuesCmd(t *testing.T) {
 		}
 	})
 
+	t.Run("extract-quoted-and-block-scalars", func(t *testing.T) {
+		// Arrange
+		projectRoot := hdtesting.CopyDir(t, "move_to_values_test/extract-integer/input-chart")
+		require.NoError(t, os.WriteFile(filepath.Join(projectRoot, "templates", "cm.yaml"), []byte(scalarsConfigMap), 0644))
+
+		// Act
+		for _, field := range []string{".data.port", ".data.script"} {
+			cmd, err := NewMoveToValuesCmd(logger)
+			require.NoError(t, err)
+			cmd.SetArgs([]string{"-d", projectRoot, "-o", filepath.Dir(projectRoot), "v1", "ConfigMap", field, `{{ resourceName . }}` + strings.Replace(field, ".data", "", 1)})
+			require.NoError(t, cmd.Execute())
+		}
+
+		// Assert
+		chrt, err := loader.LoadDir(projectRoot)
+		require.NoError(t, err)
+		rendered := hdtesting.RequireRenderedTemplates(t, chrt, "test")[chrt.Name()+"/templates/cm.yaml"]
+		require.Contains(t, rendered, "port: \"8080\"", "the quoted number should still render as a string")
+		require.Contains(t, rendered, "echo a\n", "the block scalar should still render as a block")
+		require.Equal(t, hdtesting.LoadBytesFixture(t, []byte(scalarsConfigMap)).Object["data"], hdtesting.LoadBytesFixture(t, []byte(rendered)).Object["data"])
+	})
+
+	t.Run("locked-project", func(t *testing.T) {
+		// Arrange
+		projectRoot := hdtesting.CopyDir(t, "move_to_values_test/extract-integer/input-chart")
+		lock, err := fsutil.LockDir(filepath.Join(projectRoot, cacheDirName), 0)
+		require.NoError(t, err)
+		defer lock.Unlock()
+
+		cmd, err := NewMoveToValuesCmd(logger)
+		require.NoError(t, err)
+		cmd.SetArgs([]string{
+			"-d", projectRoot,
+			"--lock-timeout", "200ms",
+			"apps/v1",
+			"Deployment",
+			`.spec.replicas`,
+			`{{ resourceName . }}.replicas`,
+		})
+
+		// Act
+		err = cmd.Execute()
+
+		// Assert
+		require.ErrorIs(t, err, fsutil.ErrLocked)
+		require.Contains(t, err.Error(), fmt.Sprintf("project is locked by pid %d", os.Getpid()))
+	})
+
+	t.Run("using-cache-dir", func(t *testing.T) {
+		// Arrange
+		projectRoot := hdtesting.CopyDir(t, "move_to_values_test/extract-integer/input-chart")
+		require.NoError(t, os.RemoveAll(filepath.Join(projectRoot, cacheDirName)))
+		cacheDir := filepath.Join(hdtesting.TempDir(t), "cache")
+
+		cmd, err := NewMoveToValuesCmd(logger)
+		require.NoError(t, err)
+		cmd.SetArgs([]string{
+			"-d", projectRoot,
+			"-o", hdtesting.TempDir(t),
+			"--cache-dir", cacheDir,
+			"apps/v1",
+			"Deployment",
+			`.spec.replicas`,
+			`{{ resourceName . }}.replicas`,
+		})
+
+		// Act
+		require.NoError(t, cmd.Execute())
+
+		// Assert
+		keys, err := (&cache.Cache{RootDir: cacheDir}).Keys()
+		require.NoError(t, err)
+		require.Equal(t, []string{"templates/nginx-deployment_apps_v1.yaml"}, keys)
+		_, err = os.Stat(filepath.Join(projectRoot, cacheDirName, cache.ManifestFileName))
+		require.True(t, os.IsNotExist(err), "the project's cache shouldn't be used")
+	})
+
+	t.Run("using-memory-store", func(t *testing.T) {
+		// Arrange
+		projectRoot := hdtesting.CopyDir(t, "move_to_values_test/extract-integer/input-chart")
+		require.NoError(t, os.RemoveAll(filepath.Join(projectRoot, cacheDirName)))
+		store := cache.NewMemoryStore()
+
+		chartBuilder, err := NewChartBuilder(projectRoot, "", logger)
+		require.NoError(t, err)
+		chartBuilder.Cache = store
+		chartBuilder.AddAction(&Action{
+			apiVersion: "apps/v1",
+			kind:       "Deployment",
+			path:       ".spec.replicas",
+			template:   "{{ resourceName . }}.replicas",
+		})
+
+		// Act
+		chrt, err := chartBuilder.Build()
+
+		// Assert
+		require.NoError(t, err)
+		for _, tmpl := range chrt.Templates {
+			if tmpl.Name == "templates/nginx-deployment_apps_v1.yaml" {
+				require.Contains(t, string(tmpl.Data), ".Values.", "the template should be patched")
+			}
+		}
+		keys, err := store.Keys()
+		require.NoError(t, err)
+		require.Equal(t, []string{"templates/nginx-deployment_apps_v1.yaml"}, keys)
+		_, err = os.Stat(filepath.Join(projectRoot, cacheDirName))
+		require.True(t, os.IsNotExist(err), "nothing should be written to disk")
+	})
+
+	t.Run("multiple-actions", func(t *testing.T) {
+		// Arrange
+		projectRoot := hdtesting.CopyDir(t, "move_to_values_test/extract-integer/input-chart")
+		chartBuilder, err := NewChartBuilder(projectRoot, "", logger)
+		require.NoError(t, err)
+		chartBuilder.Cache = cache.NewMemoryStore()
+		for _, action := range []*Action{
+			{apiVersion: "apps/v1", kind: "Deployment", path: ".spec.template.spec.containers[0].image", template: "{{ resourceName . }}.image"},
+			{apiVersion: "apps/v1", kind: "Deployment", path: ".spec.replicas", template: "{{ resourceName . }}.replicas"},
+			{apiVersion: "apps/v1", kind: "Deployment", path: ".spec.replicas", template: "{{ resourceName . }}.replicaCount"},
+		} {
+			chartBuilder.AddAction(action)
+		}
+
+		// Act
+		chrt, err := chartBuilder.Build()
+
+		// Assert
+		require.NoError(t, err)
+		var data string
+		for _, tmpl := range chrt.Templates {
+			if tmpl.Name == "templates/nginx-deployment_apps_v1.yaml" {
+				data = string(tmpl.Data)
+			}
+		}
+		require.Contains(t, data, "  replicas: {{ .Values.nginx.replicas }}\n")
+		require.Contains(t, data, "      - image: {{ .Values.nginx.image }}\n        name: nginx\n")
+		var values string
+		for _, f := range chrt.Raw {
+			if f.Name == "values.yaml" {
+				values = string(f.Data)
+			}
+		}
+		require.Equal(t, "nginx:\n  image: nginx:1.14.2\n  replicas: \"3\"\n", values,
+			"the value of the action conflicting with a previous one shouldn't be moved")
+	})
 }
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/redhat-developer/helm-dump/pkg/crane/plugin"
+)
+
+// defaultPluginDir returns the directory binary crane plugins are looked up by default.
+func defaultPluginDir() (string, error) {
+	ex, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+
+	// Assume crane plugins will be available in the same directory as helm-dump is stored; this plays
+	// nicely in the current scenario where a release produces a bundle with binaries for all available
+	// targets or in a different one where one bundle per target.
+	return path.Join(filepath.Dir(ex), "crane-plugins"), nil
+}
+
+type PluginInfoCommand struct {
+	*cobra.Command
+	Logger         *logrus.Logger
+	PluginDir      string
+	BuiltinPlugins []string
+	SkipPlugins    []string
+}
+
+func NewPluginCmd(logger *logrus.Logger) (*cobra.Command, error) {
+	cmd := &cobra.Command{
+		Use:   "plugin",
+		Short: "inspect the transform plugins used by init",
+	}
+
+	infoCmd, err := NewPluginInfoCmd(logger)
+	if err != nil {
+		return nil, err
+	}
+	cmd.AddCommand(infoCmd.Command)
+
+	return cmd, nil
+}
+
+func NewPluginInfoCmd(logger *logrus.Logger) (*PluginInfoCommand, error) {
+	cmd := &PluginInfoCommand{
+		Logger: logger,
+		Command: &cobra.Command{
+			Use:   "info [plugin-name]",
+			Short: "list the plugins used by init and the optional fields they accept",
+			Args:  cobra.MaximumNArgs(1),
+		},
+	}
+
+	pluginDir, err := defaultPluginDir()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd.PersistentFlags().StringVarP(&cmd.PluginDir, "plugin-dir", "P", pluginDir, "The path where binary plugins are located")
+	cmd.PersistentFlags().StringSliceVar(&cmd.BuiltinPlugins, "builtin-plugins", plugin.BuiltinPluginNames(), "A comma-separated list of built-in plugins to run")
+	cmd.PersistentFlags().StringSliceVarP(&cmd.SkipPlugins, "skip-plugins", "S", nil, "A comma-separated list of plugins to skip")
+
+	cmd.Command.RunE = cmd.runE
+
+	return cmd, nil
+}
+
+func (c *PluginInfoCommand) runE(cmd *cobra.Command, args []string) error {
+	plugins, err := plugin.GetLayeredPlugins(c.BuiltinPlugins, c.PluginDir, c.SkipPlugins, c.Logger)
+	if err != nil {
+		return fmt.Errorf("error loading plugins: %w", err)
+	}
+
+	found := false
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+	for _, p := range plugins {
+		metadata := p.Metadata()
+		if len(args) > 0 && metadata.Name != args[0] {
+			continue
+		}
+		found = true
+
+		_, _ = fmt.Fprintf(w, "%s\t%s\n", metadata.Name, metadata.Version)
+		for _, field := range metadata.OptionalFields {
+			help := field.Help
+			if field.Example != "" {
+				help = fmt.Sprintf("%s (example: %s)", help, field.Example)
+			}
+			_, _ = fmt.Fprintf(w, "  --plugin-flag %s=...\t%s\n", field.FlagName, help)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(args) > 0 && !found {
+		return fmt.Errorf("plugin %q not found", args[0])
+	}
+
+	return nil
+}
+
+func init() {
+	logger := logrus.New()
+	logger.Level = logrus.DebugLevel
+
+	cmd, err := NewPluginCmd(logger)
+	if err != nil {
+		panic(err)
+	}
+	rootCmd.AddCommand(cmd)
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	hdtesting "github.com/redhat-developer/helm-dump/pkg/test"
+)
+
+func TestPluginInfoCmd(t *testing.T) {
+	logger := logrus.New()
+	logger.Level = logrus.DebugLevel
+
+	t.Run("without-arguments", func(t *testing.T) {
+		// Arrange
+		cmd, err := NewPluginInfoCmd(logger)
+		require.NoError(t, err)
+		cmd.SetArgs([]string{"--plugin-dir", hdtesting.TempDir(t)})
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+
+		// Act
+		require.NoError(t, cmd.Execute())
+
+		// Assert
+		require.Contains(t, outBuf.String(), "HelmDumpInit")
+		require.Contains(t, outBuf.String(), "--plugin-flag chart-name=...")
+		require.Contains(t, outBuf.String(), "KubernetesPlugin")
+		require.Contains(t, outBuf.String(), "--plugin-flag add-annotations=...")
+	})
+
+	t.Run("unknown-plugin", func(t *testing.T) {
+		// Arrange
+		cmd, err := NewPluginInfoCmd(logger)
+		require.NoError(t, err)
+		cmd.SetArgs([]string{"--plugin-dir", hdtesting.TempDir(t), "Unknown"})
+
+		// Act & Assert
+		require.Error(t, cmd.Execute())
+	})
+}
@@ -0,0 +1,309 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/redhat-developer/helm-dump/pkg/valuesref"
+)
+
+// valuesMvCommand is the name values mv operations are recorded with in the journal.
+const valuesMvCommand = "values mv"
+
+type ValuesCommand struct {
+	*cobra.Command
+	Logger      *logrus.Logger
+	ProjectRoot string
+	LockTimeout time.Duration
+	Prefix      string
+	DryRun      bool
+}
+
+func NewValuesCmd(logger *logrus.Logger) (*ValuesCommand, error) {
+	cmd := &ValuesCommand{
+		Logger: logger,
+		Command: &cobra.Command{
+			Use:   "values",
+			Short: "refactor the keys of the chart's values along with the templates referencing them",
+		},
+	}
+
+	cmd.PersistentFlags().StringVarP(&cmd.ProjectRoot, "project-root", "d", ".", "The project root directory")
+	addLockTimeoutFlag(cmd.PersistentFlags(), &cmd.LockTimeout)
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "mv src dst",
+			Short: "move a value or a subtree of values to another key, rewriting the templates referencing it",
+			Long: "Moves the key src of values.yaml, along with the keys below it, to dst, and rewrites every reference " +
+				"to them in the chart's templates, including _helpers.tpl, such as .Values.src.replicas, to refer to dst. " +
+				"Fails if dst already exists. The chart is modified in place.",
+			Args: cobra.ExactArgs(2),
+			RunE: cmd.mvE,
+		},
+	)
+
+	dedupeCmd := &cobra.Command{
+		Use:   "dedupe [name...]",
+		Short: "consolidate the values held by several keys into shared keys, rewriting the templates referencing them",
+		Long: "Finds the strings and numbers held by several keys of values.yaml, such as the image tags extracted from " +
+			"many resources, and moves each of them to a shared key, such as global.imageTag, rewriting the references " +
+			"to the moved keys in the chart's templates. Only the keys named after one of names are considered, if " +
+			"informed. The proposed keys are reported; use --dry-run to review them without modifying the chart.",
+		RunE: cmd.dedupeE,
+	}
+	dedupeCmd.Flags().StringVar(&cmd.Prefix, "prefix", defaultSharedValuesPrefix, "The key holding the shared keys")
+	dedupeCmd.Flags().BoolVar(&cmd.DryRun, "dry-run", false, "Report the duplicate values and their shared keys without modifying the chart")
+	cmd.AddCommand(dedupeCmd)
+
+	return cmd, nil
+}
+
+func (c *ValuesCommand) mvE(cmd *cobra.Command, args []string) error {
+	projectRoot, err := inPlaceChartDir(c.ProjectRoot)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := lockProject(projectRoot, "", c.LockTimeout, c.Logger)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	rewritten, err := moveValue(projectRoot, args, c.Logger)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%s moved to %s, %d reference(s) rewritten\n", args[0], args[1], rewritten)
+	return nil
+}
+
+// identifierRegexp matches the keys which can be referenced as fields, such as .Values.replicaCount.
+var identifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validateValuesKey returns an error if the dotted key, such as nginx.replicas, can't be referenced as a field of
+// .Values.
+func validateValuesKey(key string) error {
+	for _, k := range strings.Split(key, ".") {
+		if !identifierRegexp.MatchString(k) {
+			return fmt.Errorf("%s can't be referenced as .Values.%s; keys must be letters, digits and underscores", key, key)
+		}
+	}
+	return nil
+}
+
+// moveValue moves the key args[0] of the values of the chart in projectRoot to args[1], rewriting the references to
+// it in the chart's templates; the chart is written in place and the operation recorded in its journal. The number
+// of rewritten references is returned.
+func moveValue(projectRoot string, args []string, logger *logrus.Logger) (int, error) {
+	if err := validateValuesKey(args[1]); err != nil {
+		return 0, err
+	}
+	src, dst := strings.Split(args[0], "."), strings.Split(args[1], ".")
+	if isKeyPrefix(src, dst) {
+		return 0, fmt.Errorf("%s can't be moved into itself", args[0])
+	}
+
+	chrt, err := loader.LoadDir(projectRoot)
+	if err != nil {
+		return 0, fmt.Errorf("error loading chart from %q: %w", projectRoot, err)
+	}
+	valuesFile := filepath.Join(projectRoot, "values.yaml")
+	value, found, err := unstructured.NestedFieldNoCopy(chrt.Values, src...)
+	if err != nil || !found {
+		return 0, fmt.Errorf("key %s not found in %s", args[0], valuesFile)
+	}
+	if _, found, _ := unstructured.NestedFieldNoCopy(chrt.Values, dst...); found {
+		return 0, fmt.Errorf("key %s already exists in %s", args[1], valuesFile)
+	}
+
+	refs := make(map[string][]rewrittenReference)
+	for _, tmpl := range chrt.Templates {
+		found, err := valuesref.Find(tmpl.Name, tmpl.Data)
+		if err != nil {
+			return 0, err
+		}
+		for _, ref := range found {
+			switch {
+			case ref.HasPrefix(src):
+				key := append(append([]string(nil), dst...), ref.Key[len(src):]...)
+				refs[tmpl.Name] = append(refs[tmpl.Name], rewrittenReference{Reference: ref, key: key})
+			case ref.Overlaps(src):
+				logger.Warnf("%s:%d: %s no longer holds %s", tmpl.Name, ref.Line, tmpl.Data[ref.Begin:ref.End], args[0])
+			}
+		}
+	}
+
+	before, err := snapshotChartDir(projectRoot, "")
+	if err != nil {
+		return 0, err
+	}
+
+	rewritten := 0
+	for _, tmpl := range chrt.Templates {
+		if len(refs[tmpl.Name]) == 0 {
+			continue
+		}
+		tmpl.Data = rewriteReferences(tmpl.Data, refs[tmpl.Name])
+		rewritten += len(refs[tmpl.Name])
+	}
+	if rewritten == 0 {
+		logger.Warnf("%s isn't referenced by any template", args[0])
+	}
+
+	removeValue(chrt.Values, src)
+	if err := unstructured.SetNestedField(chrt.Values, value, dst...); err != nil {
+		return 0, fmt.Errorf("error moving %s to %s: %w", args[0], args[1], err)
+	}
+	if err := appendValuesYaml(chrt, chrt.Values); err != nil {
+		return 0, err
+	}
+	if chrt.Schema != nil {
+		schema, err := moveSchemaProperty(chrt.Schema, src, dst)
+		if err != nil {
+			return 0, err
+		}
+		chrt.Schema = schema
+	}
+
+	if err := saveChartDir(chrt, filepath.Dir(projectRoot)); err != nil {
+		return 0, fmt.Errorf("error saving chart: %w", err)
+	}
+	return rewritten, recordOperation(projectRoot, "", valuesMvCommand, args, before)
+}
+
+// isKeyPrefix returns whether key is prefix or below it.
+func isKeyPrefix(prefix []string, key []string) bool {
+	return valuesref.Reference{Key: key}.HasPrefix(prefix)
+}
+
+// rewrittenReference is a reference to a moved key in a template, along with the key it refers to after the move.
+type rewrittenReference struct {
+	valuesref.Reference
+	key []string
+}
+
+// rewriteReferences returns data with refs referring to their new keys; the .Values or $.Values they start with is
+// kept.
+func rewriteReferences(data []byte, refs []rewrittenReference) []byte {
+	var out strings.Builder
+	offset := 0
+	for _, ref := range refs {
+		text := string(data[ref.Begin:ref.End])
+		head := text[:strings.Index(text, "Values")+len("Values")]
+		out.Write(data[offset:ref.Begin])
+		out.WriteString(head + "." + strings.Join(ref.key, "."))
+		offset = ref.End
+	}
+	out.Write(data[offset:])
+	return []byte(out.String())
+}
+
+// takeSchemaProperty removes the property describing key from the JSON schema root, returning it along with whether
+// it was required; found is false if root doesn't describe key.
+func takeSchemaProperty(root map[string]interface{}, key []string) (property map[string]interface{}, required bool, found bool) {
+	node := root
+	for i, k := range key {
+		properties, ok := node["properties"].(map[string]interface{})
+		if !ok {
+			return nil, false, false
+		}
+		child, ok := properties[k].(map[string]interface{})
+		if !ok {
+			return nil, false, false
+		}
+		if i < len(key)-1 {
+			node = child
+			continue
+		}
+
+		delete(properties, k)
+		if names, ok := node["required"].([]interface{}); ok {
+			kept := make([]interface{}, 0, len(names))
+			for _, r := range names {
+				if r == k {
+					required = true
+					continue
+				}
+				kept = append(kept, r)
+			}
+			node["required"] = kept
+		}
+		property = child
+	}
+	return property, required, true
+}
+
+// putSchemaProperty sets property as the one describing key in the JSON schema root, adding the objects holding it
+// if they aren't described yet.
+func putSchemaProperty(root map[string]interface{}, key []string, property map[string]interface{}, required bool) {
+	node := root
+	for i, k := range key {
+		properties, ok := node["properties"].(map[string]interface{})
+		if !ok {
+			properties = make(map[string]interface{})
+			node["properties"] = properties
+		}
+		if i == len(key)-1 {
+			properties[k] = property
+			break
+		}
+		child, ok := properties[k].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{"type": "object"}
+			properties[k] = child
+		}
+		node = child
+	}
+
+	if !required {
+		return
+	}
+	names, _ := node["required"].([]interface{})
+	node["required"] = append(names, key[len(key)-1])
+}
+
+// moveSchemaProperty returns the JSON schema of values with the property describing src moved to dst; schema is
+// returned as is if it doesn't describe src.
+func moveSchemaProperty(schema []byte, src []string, dst []string) ([]byte, error) {
+	root := make(map[string]interface{})
+	if err := json.Unmarshal(schema, &root); err != nil {
+		return nil, fmt.Errorf("error parsing values.schema.json: %w", err)
+	}
+	property, required, found := takeSchemaProperty(root, src)
+	if !found {
+		return schema, nil
+	}
+	putSchemaProperty(root, dst, property, required)
+	return marshalSchema(root)
+}
+
+// marshalSchema returns the JSON schema root as written to values.schema.json.
+func marshalSchema(root map[string]interface{}) ([]byte, error) {
+	data, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling values.schema.json: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+func init() {
+	logger := logrus.New()
+	logger.Level = logrus.DebugLevel
+
+	cmd, err := NewValuesCmd(logger)
+	if err != nil {
+		panic(err)
+	}
+	rootCmd.AddCommand(cmd.Command)
+}
@@ -0,0 +1,426 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/redhat-developer/helm-dump/pkg/cache"
+	"github.com/redhat-developer/helm-dump/pkg/valuesref"
+)
+
+// valuesDedupeCommand is the name values dedupe operations are recorded with in the journal.
+const valuesDedupeCommand = "values dedupe"
+
+// defaultSharedValuesPrefix is the key holding the shared keys proposed for duplicate values.
+const defaultSharedValuesPrefix = "global"
+
+// DuplicateValue is a value held by several keys of a chart's values, which can be consolidated into a shared key.
+type DuplicateValue struct {
+	// Key is the shared key proposed for the value, such as global.imageTag.
+	Key string
+	// Value is the value held by Keys.
+	Value interface{}
+	// Keys are the sorted keys holding the value, such as nginx.image.tag and redis.image.tag.
+	Keys []string
+}
+
+// String returns d as recorded in the journal, such as global.imageTag=nginx.image.tag,redis.image.tag.
+func (d DuplicateValue) String() string {
+	return d.Key + "=" + strings.Join(d.Keys, ",")
+}
+
+// parseDuplicateValues parses the duplicate values recorded in the journal as args; their values aren't recorded.
+func parseDuplicateValues(args []string) ([]DuplicateValue, error) {
+	dups := make([]DuplicateValue, 0, len(args))
+	for _, arg := range args {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid duplicate value %q; expected key=key,key", arg)
+		}
+		dups = append(dups, DuplicateValue{Key: parts[0], Keys: strings.Split(parts[1], ",")})
+	}
+	return dups, nil
+}
+
+// valueLeaf is a scalar value of a chart's values and its key.
+type valueLeaf struct {
+	key   []string
+	value interface{}
+}
+
+// valueLeaves returns the string and number values below values, which hold key, sorted by key; empty strings and
+// zeros are skipped, as they're the defaults of unrelated keys rather than shared settings.
+func valueLeaves(values map[string]interface{}, key []string) []valueLeaf {
+	var leaves []valueLeaf
+	for k, v := range values {
+		leafKey := append(append([]string(nil), key...), k)
+		switch v := v.(type) {
+		case map[string]interface{}:
+			leaves = append(leaves, valueLeaves(v, leafKey)...)
+		case string, float64, int64, int:
+			if v != "" && v != 0.0 && v != int64(0) && v != 0 {
+				leaves = append(leaves, valueLeaf{key: leafKey, value: v})
+			}
+		}
+	}
+	sort.Slice(leaves, func(i, j int) bool {
+		return strings.Join(leaves[i].key, ".") < strings.Join(leaves[j].key, ".")
+	})
+	return leaves
+}
+
+// keyWordRegexp matches the words of a key, such as storage and class in storage-class.
+var keyWordRegexp = regexp.MustCompile(`[A-Za-z0-9]+`)
+
+// sharedKeyName returns the name of the shared key of a value held by keys, made of the last words they have in
+// common, such as imageTag for nginx.image.tag and redis.image.tag, or "" if they have none.
+func sharedKeyName(keys [][]string) string {
+	var suffix []string
+	for n := 1; n <= 2; n++ {
+		var candidate []string
+		for i, key := range keys {
+			if len(key) < n {
+				candidate = nil
+				break
+			}
+			if i == 0 {
+				candidate = key[len(key)-n:]
+				continue
+			}
+			if strings.Join(key[len(key)-n:], ".") != strings.Join(candidate, ".") {
+				candidate = nil
+				break
+			}
+		}
+		if candidate == nil {
+			break
+		}
+		suffix = candidate
+	}
+	if suffix == nil {
+		return ""
+	}
+
+	var name strings.Builder
+	for _, word := range keyWordRegexp.FindAllString(strings.Join(suffix, "."), -1) {
+		if name.Len() > 0 {
+			word = strings.ToUpper(word[:1]) + word[1:]
+		}
+		name.WriteString(word)
+	}
+	if !identifierRegexp.MatchString(name.String()) {
+		return "value" + name.String()
+	}
+	return name.String()
+}
+
+// FindDuplicateValues returns the string and number values held by several keys of the values of chrt, such as the
+// chart built by a ChartBuilder, along with the shared keys below prefix proposed for them, sorted by their first
+// key. Only the keys whose last segment is one of names are considered, if informed. Values held by keys without a
+// common last segment, such as fullnameOverride and nameOverride, are unrelated and skipped. Keys which can't be
+// referenced as fields, or whose holders are referenced by templates, as in {{ toYaml .Values.nginx }}, are skipped,
+// since their references can't be rewritten.
+func FindDuplicateValues(chrt *chart.Chart, prefix string, names []string, logger *logrus.Logger) ([]DuplicateValue, error) {
+	if err := validateValuesKey(prefix); err != nil {
+		return nil, err
+	}
+	prefixKey := strings.Split(prefix, ".")
+	if holder, found, err := unstructured.NestedFieldNoCopy(chrt.Values, prefixKey...); err != nil {
+		return nil, fmt.Errorf("%s can't hold shared keys: %w", prefix, err)
+	} else if _, ok := holder.(map[string]interface{}); found && !ok {
+		return nil, fmt.Errorf("%s can't hold shared keys: it isn't a map", prefix)
+	}
+
+	var holders []valuesref.Reference
+	var locations []string
+	for _, tmpl := range chrt.Templates {
+		found, err := valuesref.Find(tmpl.Name, tmpl.Data)
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range found {
+			holders = append(holders, ref)
+			locations = append(locations, fmt.Sprintf("%s:%d: %s", tmpl.Name, ref.Line, tmpl.Data[ref.Begin:ref.End]))
+		}
+	}
+
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+	groups := make(map[string][]valueLeaf)
+	var fingerprints []string
+LEAVES:
+	for _, leaf := range valueLeaves(chrt.Values, nil) {
+		if len(wanted) > 0 && !wanted[leaf.key[len(leaf.key)-1]] {
+			continue
+		}
+		for _, k := range leaf.key {
+			if !identifierRegexp.MatchString(k) {
+				logger.Warnf("%s is skipped: it can't be referenced as a field", strings.Join(leaf.key, "."))
+				continue LEAVES
+			}
+		}
+		for i, ref := range holders {
+			if len(ref.Key) < len(leaf.key) && ref.Overlaps(leaf.key) {
+				logger.Warnf("%s is skipped: %s uses the values holding it", strings.Join(leaf.key, "."), locations[i])
+				continue LEAVES
+			}
+		}
+		// values of different types, such as 3 and "3", aren't duplicates.
+		fingerprint, err := json.Marshal(leaf.value)
+		if err != nil {
+			return nil, fmt.Errorf("error marshalling %s: %w", strings.Join(leaf.key, "."), err)
+		}
+		if _, ok := groups[string(fingerprint)]; !ok {
+			fingerprints = append(fingerprints, string(fingerprint))
+		}
+		groups[string(fingerprint)] = append(groups[string(fingerprint)], leaf)
+	}
+
+	var dups []DuplicateValue
+	proposed := make(map[string]bool)
+	for _, fingerprint := range fingerprints {
+		leaves := groups[fingerprint]
+		if len(leaves) < 2 {
+			continue
+		}
+		dup := DuplicateValue{Value: leaves[0].value}
+		keys := make([][]string, 0, len(leaves))
+		for _, leaf := range leaves {
+			dup.Keys = append(dup.Keys, strings.Join(leaf.key, "."))
+			keys = append(keys, leaf.key)
+		}
+		if sharedKeyName(keys) == "" {
+			logger.Debugf("%s are skipped: they hold the same value but have no name in common", strings.Join(dup.Keys, ", "))
+			continue
+		}
+		dup.Key = proposeSharedKey(chrt.Values, prefix, keys, proposed)
+		proposed[dup.Key] = true
+		dups = append(dups, dup)
+	}
+	return dups, nil
+}
+
+// proposeSharedKey returns the shared key below prefix for the value held by keys, which is one of them if it's
+// already directly below prefix; a number is appended to the key's name if it's taken, either in values or by the
+// keys already proposed.
+func proposeSharedKey(values map[string]interface{}, prefix string, keys [][]string, proposed map[string]bool) string {
+	prefixLen := len(strings.Split(prefix, "."))
+	for _, key := range keys {
+		joined := strings.Join(key, ".")
+		if len(key) == prefixLen+1 && strings.HasPrefix(joined, prefix+".") && !proposed[joined] {
+			return joined
+		}
+	}
+
+	name := prefix + "." + sharedKeyName(keys)
+	for n := 1; ; n++ {
+		candidate := name
+		if n > 1 {
+			candidate = fmt.Sprintf("%s%d", name, n)
+		}
+		_, found, err := unstructured.NestedFieldNoCopy(values, strings.Split(candidate, ".")...)
+		if !found && err == nil && !proposed[candidate] {
+			return candidate
+		}
+	}
+}
+
+// consolidateValues moves the keys of each of dups to its shared key in the values of the chart in projectRoot,
+// rewriting the references to them in the chart's templates; the chart is written in place and the operation
+// recorded in its journal. The number of rewritten references is returned.
+func consolidateValues(projectRoot string, dups []DuplicateValue, logger *logrus.Logger) (int, error) {
+	chrt, err := loader.LoadDir(projectRoot)
+	if err != nil {
+		return 0, fmt.Errorf("error loading chart from %q: %w", projectRoot, err)
+	}
+	valuesFile := filepath.Join(projectRoot, "values.yaml")
+
+	// the keys of dups must still hold the same value, since they might have changed since they were found.
+	values := make([]interface{}, len(dups))
+	for i, dup := range dups {
+		var fingerprint []byte
+		for _, key := range dup.Keys {
+			value, found, err := unstructured.NestedFieldNoCopy(chrt.Values, strings.Split(key, ".")...)
+			if err != nil || !found {
+				return 0, fmt.Errorf("key %s not found in %s", key, valuesFile)
+			}
+			current, err := json.Marshal(value)
+			if err != nil {
+				return 0, fmt.Errorf("error marshalling %s: %w", key, err)
+			}
+			if fingerprint == nil {
+				fingerprint, values[i] = current, value
+			} else if string(current) != string(fingerprint) {
+				return 0, fmt.Errorf("%s and %s don't hold the same value", dup.Keys[0], key)
+			}
+		}
+		if _, found, _ := unstructured.NestedFieldNoCopy(chrt.Values, strings.Split(dup.Key, ".")...); found && !dup.holds(dup.Key) {
+			return 0, fmt.Errorf("key %s already exists in %s", dup.Key, valuesFile)
+		}
+	}
+
+	refs := make(map[string][]rewrittenReference)
+	for _, tmpl := range chrt.Templates {
+		found, err := valuesref.Find(tmpl.Name, tmpl.Data)
+		if err != nil {
+			return 0, err
+		}
+		for _, ref := range found {
+			for _, dup := range dups {
+				for _, key := range dup.Keys {
+					src := strings.Split(key, ".")
+					switch {
+					case key == dup.Key:
+					case ref.HasPrefix(src):
+						dst := append(strings.Split(dup.Key, "."), ref.Key[len(src):]...)
+						refs[tmpl.Name] = append(refs[tmpl.Name], rewrittenReference{Reference: ref, key: dst})
+					case ref.Overlaps(src):
+						return 0, fmt.Errorf("%s can't be moved: %s:%d: %s uses the values holding it", key, tmpl.Name, ref.Line, tmpl.Data[ref.Begin:ref.End])
+					}
+				}
+			}
+		}
+	}
+
+	before, err := snapshotChartDir(projectRoot, "")
+	if err != nil {
+		return 0, err
+	}
+
+	rewritten := 0
+	for _, tmpl := range chrt.Templates {
+		if len(refs[tmpl.Name]) == 0 {
+			continue
+		}
+		tmpl.Data = rewriteReferences(tmpl.Data, refs[tmpl.Name])
+		rewritten += len(refs[tmpl.Name])
+	}
+	if rewritten == 0 {
+		logger.Warnf("none of the consolidated keys is referenced by any template")
+	}
+
+	schema := make(map[string]interface{})
+	if chrt.Schema != nil {
+		if err := json.Unmarshal(chrt.Schema, &schema); err != nil {
+			return 0, fmt.Errorf("error parsing values.schema.json: %w", err)
+		}
+	}
+	for i, dup := range dups {
+		dst := strings.Split(dup.Key, ".")
+		keys := dup.Keys
+		if dup.holds(dup.Key) {
+			// the shared key keeps its own property.
+			keys = append([]string{dup.Key}, dup.Keys...)
+		}
+		var property map[string]interface{}
+		var required bool
+		for _, key := range keys {
+			if p, r, found := takeSchemaProperty(schema, strings.Split(key, ".")); found && property == nil {
+				property, required = p, r
+			}
+			removeValue(chrt.Values, strings.Split(key, "."))
+		}
+		if err := unstructured.SetNestedField(chrt.Values, values[i], dst...); err != nil {
+			return 0, fmt.Errorf("error moving %s to %s: %w", strings.Join(dup.Keys, ", "), dup.Key, err)
+		}
+		if property != nil {
+			putSchemaProperty(schema, dst, property, required)
+		}
+	}
+	if err := appendValuesYaml(chrt, chrt.Values); err != nil {
+		return 0, err
+	}
+	if chrt.Schema != nil {
+		if chrt.Schema, err = marshalSchema(schema); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := saveChartDir(chrt, filepath.Dir(projectRoot)); err != nil {
+		return 0, fmt.Errorf("error saving chart: %w", err)
+	}
+	args := make([]string, 0, len(dups))
+	for _, dup := range dups {
+		args = append(args, dup.String())
+	}
+	return rewritten, recordOperation(projectRoot, "", valuesDedupeCommand, args, before)
+}
+
+// holds returns whether key is one of the keys holding d's value.
+func (d DuplicateValue) holds(key string) bool {
+	for _, k := range d.Keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *ValuesCommand) dedupeE(cmd *cobra.Command, args []string) error {
+	projectRoot, err := inPlaceChartDir(c.ProjectRoot)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := lockProject(projectRoot, "", c.LockTimeout, c.Logger)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// the chart is only analysed, so its snapshots are kept in memory rather than added to the project's cache.
+	chartBuilder, err := NewChartBuilder(projectRoot, "", c.Logger)
+	if err != nil {
+		return err
+	}
+	chartBuilder.Cache = cache.NewMemoryStore()
+	chrt, err := chartBuilder.Build()
+	if err != nil {
+		return err
+	}
+	dups, err := FindDuplicateValues(chrt, c.Prefix, args, c.Logger)
+	if err != nil {
+		return err
+	}
+	if len(dups) == 0 {
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), "no duplicate values found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "KEY\tVALUE\tREPLACES")
+	for _, dup := range dups {
+		value, err := json.Marshal(dup.Value)
+		if err != nil {
+			return fmt.Errorf("error marshalling %s: %w", dup.Keys[0], err)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", dup.Key, value, strings.Join(dup.Keys, ", "))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if c.DryRun {
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), "dry run; the chart wasn't modified")
+		return nil
+	}
+
+	rewritten, err := consolidateValues(projectRoot, dups, c.Logger)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%d value(s) consolidated, %d reference(s) rewritten\n", len(dups), rewritten)
+	return nil
+}
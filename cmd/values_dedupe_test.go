@@ -0,0 +1,257 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+func TestFindDuplicateValues(t *testing.T) {
+	logger := logrus.New()
+	logger.Level = logrus.DebugLevel
+
+	imageTags := func() map[string]interface{} {
+		return map[string]interface{}{
+			"nginx": map[string]interface{}{"image": map[string]interface{}{"tag": "1.21"}, "replicas": "3"},
+			"redis": map[string]interface{}{"image": map[string]interface{}{"tag": "1.21"}, "replicas": float64(3)},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		values   map[string]interface{}
+		template string
+		names    []string
+		expected []DuplicateValue
+	}{
+		{
+			name:   "image-tags",
+			values: imageTags(),
+			expected: []DuplicateValue{
+				{Key: "global.imageTag", Value: "1.21", Keys: []string{"nginx.image.tag", "redis.image.tag"}},
+			},
+		},
+		{
+			name:   "different-types",
+			values: map[string]interface{}{"nginx": map[string]interface{}{"replicas": "3"}, "redis": map[string]interface{}{"replicas": float64(3)}},
+		},
+		{
+			name:   "no-common-name",
+			values: map[string]interface{}{"nginx": map[string]interface{}{"host": "registry"}, "redis": map[string]interface{}{"registry": "registry"}},
+		},
+		{
+			name: "empty-and-zero-values",
+			values: map[string]interface{}{
+				"fullnameOverride": "",
+				"nameOverride":     "",
+				"nginx":            map[string]interface{}{"port": float64(0)},
+				"redis":            map[string]interface{}{"port": float64(0)},
+			},
+		},
+		{
+			name: "taken-key",
+			values: map[string]interface{}{
+				"global": map[string]interface{}{"imageTag": "latest"},
+				"nginx":  map[string]interface{}{"image": map[string]interface{}{"tag": "1.21"}},
+				"redis":  map[string]interface{}{"image": map[string]interface{}{"tag": "1.21"}},
+			},
+			expected: []DuplicateValue{
+				{Key: "global.imageTag2", Value: "1.21", Keys: []string{"nginx.image.tag", "redis.image.tag"}},
+			},
+		},
+		{
+			name: "existing-shared-key",
+			values: map[string]interface{}{
+				"global": map[string]interface{}{"tag": "1.21"},
+				"nginx":  map[string]interface{}{"image": map[string]interface{}{"tag": "1.21"}},
+			},
+			expected: []DuplicateValue{
+				{Key: "global.tag", Value: "1.21", Keys: []string{"global.tag", "nginx.image.tag"}},
+			},
+		},
+		{
+			name:     "referenced-holder",
+			values:   imageTags(),
+			template: "data: {{ toYaml .Values.nginx | nindent 2 }}\n",
+		},
+		{
+			name:   "unreferenceable-key",
+			values: map[string]interface{}{"nginx-deployment": map[string]interface{}{"tag": "1.21"}, "redis": map[string]interface{}{"tag": "1.21"}},
+		},
+		{
+			name: "names",
+			values: map[string]interface{}{
+				"nginx": map[string]interface{}{"image": map[string]interface{}{"tag": "1.21"}, "replicas": "3"},
+				"redis": map[string]interface{}{"image": map[string]interface{}{"tag": "1.21"}, "replicas": "3"},
+			},
+			names: []string{"tag"},
+			expected: []DuplicateValue{
+				{Key: "global.imageTag", Value: "1.21", Keys: []string{"nginx.image.tag", "redis.image.tag"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			chrt := &chart.Chart{Values: tt.values}
+			if tt.template != "" {
+				chrt.Templates = []*chart.File{{Name: "templates/configmap.yaml", Data: []byte(tt.template)}}
+			}
+
+			// Act
+			dups, err := FindDuplicateValues(chrt, defaultSharedValuesPrefix, tt.names, logger)
+
+			// Assert
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, dups)
+		})
+	}
+
+	t.Run("scalar-prefix", func(t *testing.T) {
+		// Arrange
+		chrt := &chart.Chart{Values: map[string]interface{}{"global": "x"}}
+
+		// Act & Assert
+		_, err := FindDuplicateValues(chrt, defaultSharedValuesPrefix, nil, logger)
+		require.Error(t, err)
+	})
+
+	t.Run("invalid-prefix", func(t *testing.T) {
+		// Arrange
+		chrt := &chart.Chart{Values: map[string]interface{}{}}
+
+		// Act & Assert
+		_, err := FindDuplicateValues(chrt, "shared-values", nil, logger)
+		require.EqualError(t, err, "shared-values can't be referenced as .Values.shared-values; keys must be letters, digits and underscores")
+	})
+}
+
+func TestParseDuplicateValues(t *testing.T) {
+	t.Run("round-trip", func(t *testing.T) {
+		// Arrange
+		dup := DuplicateValue{Key: "global.imageTag", Keys: []string{"nginx.image.tag", "redis.image.tag"}}
+
+		// Act
+		dups, err := parseDuplicateValues([]string{dup.String()})
+
+		// Assert
+		require.NoError(t, err)
+		require.Equal(t, []DuplicateValue{dup}, dups)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		// Act & Assert
+		_, err := parseDuplicateValues([]string{"global.imageTag"})
+		require.Error(t, err)
+	})
+}
+
+func TestValuesDedupeCmd(t *testing.T) {
+	logger := logrus.New()
+	logger.Level = logrus.DebugLevel
+
+	const templateName = "templates/images.yaml"
+	const template = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: images
+data:
+  nginx: "{{ .Values.nginx.image.tag }}"
+  redis: {{ $.Values.redis.image.tag | quote }}
+`
+
+	// imagesChart returns a copy of the extract-integer chart with a template referencing two identical image tags.
+	imagesChart := func(t *testing.T) string {
+		projectRoot := inPlaceChart(t)
+		values := "nginx:\n  image:\n    tag: \"1.21\"\nredis:\n  image:\n    tag: \"1.21\"\n"
+		require.NoError(t, os.WriteFile(filepath.Join(projectRoot, "values.yaml"), []byte(values), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(projectRoot, templateName), []byte(template), 0644))
+		return projectRoot
+	}
+
+	t.Run("dry-run", func(t *testing.T) {
+		// Arrange
+		projectRoot := imagesChart(t)
+
+		// Act
+		out, err := runCmd(t, logger, "values", "dedupe", "-d", projectRoot, "--dry-run")
+
+		// Assert
+		require.NoError(t, err)
+		require.Contains(t, out, `global.imageTag  "1.21"  nginx.image.tag, redis.image.tag`)
+		require.Contains(t, out, "dry run")
+		require.Equal(t, template, readFile(t, filepath.Join(projectRoot, templateName)), "the chart shouldn't be modified")
+	})
+
+	t.Run("dry-run-keeps-cache", func(t *testing.T) {
+		// Arrange
+		projectRoot := imagesChart(t)
+
+		// Act
+		_, err := runCmd(t, logger, "values", "dedupe", "-d", projectRoot, "--dry-run")
+
+		// Assert
+		require.NoError(t, err)
+		exists, err := projectCache(projectRoot, "").Exists(templateName)
+		require.NoError(t, err)
+		require.False(t, exists, "the analysed chart's snapshots shouldn't be cached")
+	})
+
+	t.Run("init-defaults", func(t *testing.T) {
+		// Arrange
+		projectRoot := imagesChart(t)
+		values := "fullnameOverride: \"\"\nnameOverride: \"\"\nnginx:\n  image:\n    tag: \"1.21\"\n"
+		require.NoError(t, os.WriteFile(filepath.Join(projectRoot, "values.yaml"), []byte(values), 0644))
+
+		// Act
+		out, err := runCmd(t, logger, "values", "dedupe", "-d", projectRoot, "--dry-run")
+
+		// Assert
+		require.NoError(t, err)
+		require.Contains(t, out, "no duplicate values found")
+	})
+
+	t.Run("consolidate", func(t *testing.T) {
+		// Arrange
+		projectRoot := imagesChart(t)
+		schema := `{"properties": {"nginx": {"properties": {"image": {"properties": {"tag": {"type": "string"}}}}}}}`
+		require.NoError(t, os.WriteFile(filepath.Join(projectRoot, "values.schema.json"), []byte(schema), 0644))
+
+		// Act
+		out, err := runCmd(t, logger, "values", "dedupe", "-d", projectRoot, "--prefix", "shared")
+
+		// Assert
+		require.NoError(t, err)
+		require.Contains(t, out, "1 value(s) consolidated, 2 reference(s) rewritten")
+		tmpl := readFile(t, filepath.Join(projectRoot, templateName))
+		require.Contains(t, tmpl, `nginx: "{{ .Values.shared.imageTag }}"`)
+		require.Contains(t, tmpl, `redis: {{ $.Values.shared.imageTag | quote }}`)
+		require.Equal(t, "shared:\n  imageTag: \"1.21\"\n", readFile(t, filepath.Join(projectRoot, "values.yaml")))
+		expectedSchema := `{"properties": {
+			"nginx": {"properties": {"image": {"properties": {}}}},
+			"shared": {"type": "object", "properties": {"imageTag": {"type": "string"}}}
+		}}`
+		require.JSONEq(t, expectedSchema, readFile(t, filepath.Join(projectRoot, "values.schema.json")))
+		entries, err := projectJournal(projectRoot).Entries()
+		require.NoError(t, err)
+		require.Equal(t, valuesDedupeCommand, entries[len(entries)-1].Command)
+		require.Equal(t, []string{"shared.imageTag=nginx.image.tag,redis.image.tag"}, entries[len(entries)-1].Args)
+	})
+
+	t.Run("no-duplicates", func(t *testing.T) {
+		// Arrange
+		projectRoot := imagesChart(t)
+
+		// Act
+		out, err := runCmd(t, logger, "values", "dedupe", "-d", projectRoot, "replicas")
+
+		// Assert
+		require.NoError(t, err)
+		require.Contains(t, out, "no duplicate values found")
+	})
+}
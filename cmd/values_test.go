@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValuesMvCmd(t *testing.T) {
+	logger := logrus.New()
+	logger.Level = logrus.DebugLevel
+
+	// helperChart returns movedChart whose helpers also reference the replicas.
+	helperChart := func(t *testing.T, values string) string {
+		projectRoot := movedChart(t, logger, values)
+		helpers := `{{ define "my-chart.replicas" }}{{ $.Values.nginx.replicas | default 1 }}{{ end }}` + "\n"
+		require.NoError(t, os.WriteFile(filepath.Join(projectRoot, "templates", "_replicas.tpl"), []byte(helpers), 0644))
+		return projectRoot
+	}
+
+	t.Run("move-subtree", func(t *testing.T) {
+		// Arrange
+		projectRoot := helperChart(t, "nginx:\n  replicas: 5\nother: true\n")
+
+		// Act
+		_, err := runCmd(t, logger, "values", "mv", "-d", projectRoot, "nginx", "web")
+
+		// Assert
+		require.NoError(t, err)
+		require.Contains(t, readFile(t, filepath.Join(projectRoot, movedTemplateName)), "replicas: {{ .Values.web.replicas }}")
+		require.Contains(t, readFile(t, filepath.Join(projectRoot, "templates", "_replicas.tpl")), "{{ $.Values.web.replicas | default 1 }}")
+		require.Equal(t, "other: true\nweb:\n  replicas: 5\n", readFile(t, filepath.Join(projectRoot, "values.yaml")))
+	})
+
+	t.Run("move-to-values-after-rename", func(t *testing.T) {
+		// Arrange
+		projectRoot := helperChart(t, "nginx:\n  replicas: \"3\"\n")
+		moveToValues := func(path string, key string) {
+			_, err := runCmd(t, logger, "move-to-values", "-d", projectRoot, "-o", filepath.Dir(projectRoot), "apps/v1", "Deployment", path, key)
+			require.NoError(t, err)
+		}
+		moveToValues(`.spec.template.spec.containers[0].image`, `{{ resourceName . }}.image`)
+		_, err := runCmd(t, logger, "values", "mv", "-d", projectRoot, "nginx.image", "web.image")
+		require.NoError(t, err)
+
+		// Act
+		moveToValues(`.spec.template.spec.containers[0].name`, `{{ resourceName . }}.containerName`)
+
+		// Assert
+		data := readFile(t, filepath.Join(projectRoot, movedTemplateName))
+		require.Contains(t, data, "replicas: {{ .Values.nginx.replicas }}")
+		require.Contains(t, data, "- image: {{ .Values.web.image }}\n        name: {{ .Values.nginx.containerName }}\n", "the rename should be kept")
+		values := readFile(t, filepath.Join(projectRoot, "values.yaml"))
+		require.Contains(t, values, "web:\n  image: nginx:1.14.2\n")
+		require.NotContains(t, values, "  image: nginx:1.14.2\n  replicas:", "the renamed key shouldn't be extracted again")
+	})
+
+	t.Run("rename-key-and-schema", func(t *testing.T) {
+		// Arrange
+		projectRoot := helperChart(t, "nginx:\n  replicas: 5\n")
+		schema := `{"properties": {"nginx": {"properties": {"replicas": {"type": "integer"}}, "required": ["replicas"]}}}`
+		require.NoError(t, os.WriteFile(filepath.Join(projectRoot, "values.schema.json"), []byte(schema), 0644))
+
+		// Act
+		_, err := runCmd(t, logger, "values", "mv", "-d", projectRoot, "nginx.replicas", "web.replicaCount")
+
+		// Assert
+		require.NoError(t, err)
+		require.Contains(t, readFile(t, filepath.Join(projectRoot, movedTemplateName)), "replicas: {{ .Values.web.replicaCount }}")
+		require.Contains(t, readFile(t, filepath.Join(projectRoot, "templates", "_replicas.tpl")), "{{ $.Values.web.replicaCount | default 1 }}")
+		require.Equal(t, "web:\n  replicaCount: 5\n", readFile(t, filepath.Join(projectRoot, "values.yaml")), "the maps left empty should be removed")
+		expectedSchema := `{"properties": {
+			"nginx": {"properties": {}, "required": []},
+			"web": {"type": "object", "properties": {"replicaCount": {"type": "integer"}}, "required": ["replicaCount"]}
+		}}`
+		require.JSONEq(t, expectedSchema, readFile(t, filepath.Join(projectRoot, "values.schema.json")))
+		entries, err := projectJournal(projectRoot).Entries()
+		require.NoError(t, err)
+		require.Equal(t, valuesMvCommand, entries[len(entries)-1].Command)
+		require.Equal(t, []string{"nginx.replicas", "web.replicaCount"}, entries[len(entries)-1].Args)
+	})
+
+	t.Run("destination-exists", func(t *testing.T) {
+		// Arrange
+		projectRoot := helperChart(t, "nginx:\n  replicas: 5\nweb:\n  replicas: 1\n")
+		templatePath := filepath.Join(projectRoot, movedTemplateName)
+		data := readFile(t, templatePath)
+
+		// Act
+		_, err := runCmd(t, logger, "values", "mv", "-d", projectRoot, "nginx", "web")
+
+		// Assert
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "key web already exists")
+		require.Equal(t, data, readFile(t, templatePath), "the chart shouldn't be modified")
+	})
+
+	t.Run("invalid-destination", func(t *testing.T) {
+		tests := []struct {
+			name string
+			src  string
+			dst  string
+		}{
+			{name: "not-an-identifier", src: "nginx", dst: "web-server"},
+			{name: "into-itself", src: "nginx", dst: "nginx.web"},
+			{name: "unknown-source", src: "nginx.image", dst: "web.image"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				// Arrange
+				projectRoot := helperChart(t, "nginx:\n  replicas: 5\n")
+
+				// Act
+				_, err := runCmd(t, logger, "values", "mv", "-d", projectRoot, tt.src, tt.dst)
+
+				// Assert
+				require.Error(t, err)
+			})
+		}
+	})
+}
@@ -0,0 +1,165 @@
+package apiversions
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// KubeVersion is a Kubernetes minor release, such as 1.25.
+type KubeVersion struct {
+	Major int
+	Minor int
+}
+
+func (v KubeVersion) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// Before returns whether v is an earlier release than other.
+func (v KubeVersion) Before(other KubeVersion) bool {
+	if v.Major != other.Major {
+		return v.Major < other.Major
+	}
+	return v.Minor < other.Minor
+}
+
+// ParseKubeVersion parses versions such as "1.25", "v1.25" or "1.25.3"; the patch version is ignored.
+func ParseKubeVersion(s string) (KubeVersion, error) {
+	parts := strings.Split(strings.TrimPrefix(strings.TrimSpace(s), "v"), ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return KubeVersion{}, fmt.Errorf("invalid Kubernetes version %q; expected major.minor, for example 1.25", s)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return KubeVersion{}, fmt.Errorf("invalid Kubernetes version %q: %w", s, err)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return KubeVersion{}, fmt.Errorf("invalid Kubernetes version %q: %w", s, err)
+	}
+	return KubeVersion{Major: major, Minor: minor}, nil
+}
+
+func v1(minor int) KubeVersion {
+	return KubeVersion{Major: 1, Minor: minor}
+}
+
+// convertFunc converts the object's fields in place to the replacement API version's schema; the object's
+// apiVersion is updated by the caller.
+type convertFunc func(obj *unstructured.Unstructured) error
+
+// removedAPI is an API version of a kind no longer served starting with a Kubernetes release.
+type removedAPI struct {
+	removedIn KubeVersion
+	// replacement is the API version to convert to; empty if the kind has no replacement.
+	replacement string
+	convert     convertFunc
+}
+
+// removedAPIs are the API versions removed from Kubernetes, indexed by group, version and kind; see
+// https://kubernetes.io/docs/reference/using-api/deprecation-guide/.
+var removedAPIs = map[schema.GroupVersionKind]removedAPI{
+	{Group: "extensions", Version: "v1beta1", Kind: "Deployment"}:                 {v1(16), "apps/v1", convertWorkload},
+	{Group: "apps", Version: "v1beta1", Kind: "Deployment"}:                       {v1(16), "apps/v1", convertWorkload},
+	{Group: "apps", Version: "v1beta2", Kind: "Deployment"}:                       {v1(16), "apps/v1", convertWorkload},
+	{Group: "extensions", Version: "v1beta1", Kind: "DaemonSet"}:                  {v1(16), "apps/v1", convertWorkload},
+	{Group: "apps", Version: "v1beta2", Kind: "DaemonSet"}:                        {v1(16), "apps/v1", convertWorkload},
+	{Group: "extensions", Version: "v1beta1", Kind: "ReplicaSet"}:                 {v1(16), "apps/v1", convertWorkload},
+	{Group: "apps", Version: "v1beta1", Kind: "ReplicaSet"}:                       {v1(16), "apps/v1", convertWorkload},
+	{Group: "apps", Version: "v1beta2", Kind: "ReplicaSet"}:                       {v1(16), "apps/v1", convertWorkload},
+	{Group: "apps", Version: "v1beta1", Kind: "StatefulSet"}:                      {v1(16), "apps/v1", convertWorkload},
+	{Group: "apps", Version: "v1beta2", Kind: "StatefulSet"}:                      {v1(16), "apps/v1", convertWorkload},
+	{Group: "extensions", Version: "v1beta1", Kind: "NetworkPolicy"}:              {v1(16), "networking.k8s.io/v1", nil},
+	{Group: "extensions", Version: "v1beta1", Kind: "Ingress"}:                    {v1(22), "networking.k8s.io/v1", convertIngress},
+	{Group: "networking.k8s.io", Version: "v1beta1", Kind: "Ingress"}:             {v1(22), "networking.k8s.io/v1", convertIngress},
+	{Group: "rbac.authorization.k8s.io", Version: "v1beta1", Kind: "Role"}:        {v1(22), "rbac.authorization.k8s.io/v1", nil},
+	{Group: "rbac.authorization.k8s.io", Version: "v1beta1", Kind: "RoleBinding"}: {v1(22), "rbac.authorization.k8s.io/v1", nil},
+	{Group: "coordination.k8s.io", Version: "v1beta1", Kind: "Lease"}:             {v1(22), "coordination.k8s.io/v1", nil},
+	{Group: "batch", Version: "v1beta1", Kind: "CronJob"}:                         {v1(25), "batch/v1", nil},
+	{Group: "policy", Version: "v1beta1", Kind: "PodDisruptionBudget"}:            {v1(25), "policy/v1", nil},
+	{Group: "discovery.k8s.io", Version: "v1beta1", Kind: "EndpointSlice"}:        {v1(25), "discovery.k8s.io/v1", nil},
+	{Group: "events.k8s.io", Version: "v1beta1", Kind: "Event"}:                   {v1(25), "events.k8s.io/v1", nil},
+	{Group: "autoscaling", Version: "v2beta1", Kind: "HorizontalPodAutoscaler"}:   {v1(25), "autoscaling/v2", convertHPAv2beta1},
+	{Group: "autoscaling", Version: "v2beta2", Kind: "HorizontalPodAutoscaler"}:   {v1(26), "autoscaling/v2", nil},
+	{Group: "storage.k8s.io", Version: "v1beta1", Kind: "CSIStorageCapacity"}:     {v1(27), "storage.k8s.io/v1", nil},
+	{Group: "policy", Version: "v1beta1", Kind: "PodSecurityPolicy"}:              {v1(25), "", nil},
+	{Group: "extensions", Version: "v1beta1", Kind: "PodSecurityPolicy"}:          {v1(16), "", nil},
+}
+
+// addedAPIs are API versions introduced in recent Kubernetes releases, which earlier releases don't serve.
+var addedAPIs = map[schema.GroupVersionKind]KubeVersion{
+	{Group: "networking.k8s.io", Version: "v1", Kind: "Ingress"}:           v1(19),
+	{Group: "networking.k8s.io", Version: "v1", Kind: "IngressClass"}:      v1(19),
+	{Group: "batch", Version: "v1", Kind: "CronJob"}:                       v1(21),
+	{Group: "policy", Version: "v1", Kind: "PodDisruptionBudget"}:          v1(21),
+	{Group: "discovery.k8s.io", Version: "v1", Kind: "EndpointSlice"}:      v1(21),
+	{Group: "events.k8s.io", Version: "v1", Kind: "Event"}:                 v1(19),
+	{Group: "autoscaling", Version: "v2", Kind: "HorizontalPodAutoscaler"}: v1(23),
+	{Group: "storage.k8s.io", Version: "v1", Kind: "CSIStorageCapacity"}:   v1(24),
+}
+
+// Decision describes how an object's API version was handled for a target Kubernetes release.
+type Decision struct {
+	Kind   string
+	From   string
+	To     string
+	Target KubeVersion
+	// Err is set when the object couldn't be converted; the object is kept with its original API version.
+	Err error
+}
+
+// Converted returns whether the object was converted to another API version.
+func (d *Decision) Converted() bool {
+	return d.Err == nil && d.To != ""
+}
+
+// Comment returns a YAML comment recording the decision, suitable to be added to the object's template.
+func (d *Decision) Comment() string {
+	if d.Converted() {
+		return fmt.Sprintf("# helm-dump: converted from %s to %s for Kubernetes %s\n", d.From, d.To, d.Target)
+	}
+	return fmt.Sprintf("# helm-dump: %s %s isn't served by Kubernetes %s: %s\n", d.From, d.Kind, d.Target, d.Err)
+}
+
+// Convert converts obj in place to an API version served by the target Kubernetes release; a nil decision is
+// returned when the object's API version is served by the target.
+func Convert(obj *unstructured.Unstructured, target KubeVersion) *Decision {
+	gvk := obj.GroupVersionKind()
+	decision := &Decision{
+		Kind:   gvk.Kind,
+		From:   obj.GetAPIVersion(),
+		Target: target,
+	}
+
+	if addedIn, ok := addedAPIs[gvk]; ok && target.Before(addedIn) {
+		decision.Err = fmt.Errorf("introduced in Kubernetes %s and conversion to earlier API versions isn't supported", addedIn)
+		return decision
+	}
+
+	removed, ok := removedAPIs[gvk]
+	if !ok || target.Before(removed.removedIn) {
+		return nil
+	}
+	if removed.replacement == "" {
+		decision.Err = fmt.Errorf("removed in Kubernetes %s without a replacement", removed.removedIn)
+		return decision
+	}
+
+	// convert a copy, so obj is kept untouched if the conversion fails.
+	converted := obj.DeepCopy()
+	if removed.convert != nil {
+		if err := removed.convert(converted); err != nil {
+			decision.Err = fmt.Errorf("removed in Kubernetes %s and can't be converted to %s: %w", removed.removedIn, removed.replacement, err)
+			return decision
+		}
+	}
+	converted.SetAPIVersion(removed.replacement)
+	obj.Object = converted.Object
+	decision.To = removed.replacement
+
+	return decision
+}
@@ -0,0 +1,156 @@
+package apiversions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/redhat-developer/helm-dump/pkg/test"
+)
+
+func TestParseKubeVersion(t *testing.T) {
+	for _, tc := range []struct {
+		input    string
+		expected KubeVersion
+		err      bool
+	}{
+		{input: "1.25", expected: KubeVersion{Major: 1, Minor: 25}},
+		{input: "v1.22", expected: KubeVersion{Major: 1, Minor: 22}},
+		{input: "1.23.4", expected: KubeVersion{Major: 1, Minor: 23}},
+		{input: "1", err: true},
+		{input: "one.two", err: true},
+	} {
+		t.Run(tc.input, func(t *testing.T) {
+			// Act
+			actual, err := ParseKubeVersion(tc.input)
+
+			// Assert
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+	t.Run("ingress", func(t *testing.T) {
+		// Arrange
+		obj := test.LoadYamlFixture(t, "test/test_convert/ingress-v1beta1.yaml")
+
+		// Act
+		decision := Convert(obj, KubeVersion{Major: 1, Minor: 22})
+
+		// Assert
+		require.NotNil(t, decision)
+		require.True(t, decision.Converted())
+		require.Equal(t, "networking.k8s.io/v1", obj.GetAPIVersion())
+		require.Equal(t, "# helm-dump: converted from networking.k8s.io/v1beta1 to networking.k8s.io/v1 for Kubernetes 1.22\n", decision.Comment())
+
+		defaultBackend, _, _ := unstructured.NestedMap(obj.Object, "spec", "defaultBackend")
+		require.Equal(t, map[string]interface{}{
+			"service": map[string]interface{}{
+				"name": "default-http-backend",
+				"port": map[string]interface{}{"number": int64(80)},
+			},
+		}, defaultBackend)
+
+		rules, _, _ := unstructured.NestedSlice(obj.Object, "spec", "rules")
+		path := rules[0].(map[string]interface{})["http"].(map[string]interface{})["paths"].([]interface{})[0]
+		require.Equal(t, map[string]interface{}{
+			"path":     "/",
+			"pathType": "ImplementationSpecific",
+			"backend": map[string]interface{}{
+				"service": map[string]interface{}{
+					"name": "nginx",
+					"port": map[string]interface{}{"name": "http"},
+				},
+			},
+		}, path)
+	})
+
+	t.Run("hpa", func(t *testing.T) {
+		// Arrange
+		obj := test.LoadYamlFixture(t, "test/test_convert/hpa-v2beta1.yaml")
+
+		// Act
+		decision := Convert(obj, KubeVersion{Major: 1, Minor: 25})
+
+		// Assert
+		require.True(t, decision.Converted())
+		require.Equal(t, "autoscaling/v2", obj.GetAPIVersion())
+		metrics, _, _ := unstructured.NestedSlice(obj.Object, "spec", "metrics")
+		require.Equal(t, []interface{}{
+			map[string]interface{}{
+				"type": "Resource",
+				"resource": map[string]interface{}{
+					"name":   "cpu",
+					"target": map[string]interface{}{"type": "Utilization", "averageUtilization": int64(80)},
+				},
+			},
+			map[string]interface{}{
+				"type": "Pods",
+				"pods": map[string]interface{}{
+					"metric": map[string]interface{}{"name": "packets-per-second"},
+					"target": map[string]interface{}{"type": "AverageValue", "averageValue": "1k"},
+				},
+			},
+		}, metrics)
+	})
+
+	t.Run("deployment-without-selector", func(t *testing.T) {
+		// Arrange
+		obj := test.LoadYamlFixture(t, "test/test_convert/deployment-extensions.yaml")
+
+		// Act
+		decision := Convert(obj, KubeVersion{Major: 1, Minor: 16})
+
+		// Assert
+		require.True(t, decision.Converted())
+		require.Equal(t, "apps/v1", obj.GetAPIVersion())
+		matchLabels, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "selector", "matchLabels")
+		require.Equal(t, map[string]string{"app": "nginx"}, matchLabels)
+	})
+
+	t.Run("served-by-target", func(t *testing.T) {
+		// Arrange
+		obj := test.LoadYamlFixture(t, "test/test_convert/pdb-v1beta1.yaml")
+
+		// Act
+		decision := Convert(obj, KubeVersion{Major: 1, Minor: 24})
+
+		// Assert
+		require.Nil(t, decision)
+		require.Equal(t, "policy/v1beta1", obj.GetAPIVersion())
+	})
+
+	t.Run("without-replacement", func(t *testing.T) {
+		// Arrange
+		obj := test.LoadYamlFixture(t, "test/test_convert/psp-v1beta1.yaml")
+
+		// Act
+		decision := Convert(obj, KubeVersion{Major: 1, Minor: 25})
+
+		// Assert
+		require.NotNil(t, decision)
+		require.False(t, decision.Converted())
+		require.Error(t, decision.Err)
+		require.Equal(t, "policy/v1beta1", obj.GetAPIVersion())
+	})
+
+	t.Run("introduced-after-target", func(t *testing.T) {
+		// Arrange
+		obj := test.LoadYamlFixture(t, "test/test_convert/pdb-v1.yaml")
+
+		// Act
+		decision := Convert(obj, KubeVersion{Major: 1, Minor: 20})
+
+		// Assert
+		require.NotNil(t, decision)
+		require.False(t, decision.Converted())
+		require.Equal(t, "policy/v1", obj.GetAPIVersion())
+	})
+}
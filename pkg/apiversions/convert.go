@@ -0,0 +1,193 @@
+package apiversions
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// convertWorkload converts workloads to apps/v1, where the selector is required; earlier versions defaulted it to
+// the pod template's labels.
+func convertWorkload(obj *unstructured.Unstructured) error {
+	unstructured.RemoveNestedField(obj.Object, "spec", "rollbackTo")
+	unstructured.RemoveNestedField(obj.Object, "spec", "templateGeneration")
+
+	if _, found, _ := unstructured.NestedMap(obj.Object, "spec", "selector"); found {
+		return nil
+	}
+	labels, found, _ := unstructured.NestedMap(obj.Object, "spec", "template", "metadata", "labels")
+	if !found || len(labels) == 0 {
+		return fmt.Errorf("spec.selector is required and the pod template has no labels to derive it from")
+	}
+	return unstructured.SetNestedMap(obj.Object, labels, "spec", "selector", "matchLabels")
+}
+
+// convertIngress converts extensions/v1beta1 and networking.k8s.io/v1beta1 Ingresses to networking.k8s.io/v1.
+func convertIngress(obj *unstructured.Unstructured) error {
+	spec, found, _ := unstructured.NestedMap(obj.Object, "spec")
+	if !found {
+		return nil
+	}
+
+	if backend, ok := spec["backend"].(map[string]interface{}); ok {
+		if err := convertIngressBackend(backend); err != nil {
+			return err
+		}
+		spec["defaultBackend"] = backend
+		delete(spec, "backend")
+	}
+
+	rules, _ := spec["rules"].([]interface{})
+	for _, rule := range rules {
+		rule, _ := rule.(map[string]interface{})
+		http, _ := rule["http"].(map[string]interface{})
+		paths, _ := http["paths"].([]interface{})
+		for _, path := range paths {
+			path, ok := path.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if _, ok := path["pathType"]; !ok {
+				path["pathType"] = "ImplementationSpecific"
+			}
+			if backend, ok := path["backend"].(map[string]interface{}); ok {
+				if err := convertIngressBackend(backend); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return unstructured.SetNestedMap(obj.Object, spec, "spec")
+}
+
+// convertIngressBackend converts a backend's serviceName and servicePort fields to a service backend.
+func convertIngressBackend(backend map[string]interface{}) error {
+	serviceName, hasName := backend["serviceName"]
+	servicePort, hasPort := backend["servicePort"]
+	if !hasName && !hasPort {
+		return nil
+	}
+	name, ok := serviceName.(string)
+	if !ok || name == "" {
+		return fmt.Errorf("backend's serviceName is required")
+	}
+
+	port := make(map[string]interface{})
+	switch p := servicePort.(type) {
+	case int64:
+		port["number"] = p
+	case float64:
+		port["number"] = int64(p)
+	case string:
+		if n, err := strconv.ParseInt(p, 10, 32); err == nil {
+			port["number"] = n
+		} else {
+			port["name"] = p
+		}
+	default:
+		return fmt.Errorf("backend's servicePort %v is invalid", servicePort)
+	}
+
+	delete(backend, "serviceName")
+	delete(backend, "servicePort")
+	backend["service"] = map[string]interface{}{
+		"name": name,
+		"port": port,
+	}
+	return nil
+}
+
+// convertHPAv2beta1 converts autoscaling/v2beta1 HorizontalPodAutoscalers' metrics to autoscaling/v2, where
+// targets are described by a MetricTarget.
+func convertHPAv2beta1(obj *unstructured.Unstructured) error {
+	metrics, found, _ := unstructured.NestedSlice(obj.Object, "spec", "metrics")
+	if !found {
+		return nil
+	}
+
+	for i, m := range metrics {
+		metric, ok := m.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		metricType, _ := metric["type"].(string)
+		source, _ := metric[lowerFirst(metricType)].(map[string]interface{})
+		if source == nil {
+			return fmt.Errorf("metric %d of type %q has no source", i, metricType)
+		}
+		converted, err := convertMetricSource(metricType, source)
+		if err != nil {
+			return fmt.Errorf("metric %d: %w", i, err)
+		}
+		metric[lowerFirst(metricType)] = converted
+	}
+
+	return unstructured.SetNestedSlice(obj.Object, metrics, "spec", "metrics")
+}
+
+func convertMetricSource(metricType string, source map[string]interface{}) (map[string]interface{}, error) {
+	converted := make(map[string]interface{})
+	target := make(map[string]interface{})
+
+	switch metricType {
+	case "Resource":
+		converted["name"] = source["name"]
+		if v, ok := source["targetAverageUtilization"]; ok {
+			target["type"] = "Utilization"
+			target["averageUtilization"] = v
+		} else if v, ok := source["targetAverageValue"]; ok {
+			target["type"] = "AverageValue"
+			target["averageValue"] = v
+		}
+	case "Pods":
+		converted["metric"] = metricIdentifier(source["metricName"], source["selector"])
+		target["type"] = "AverageValue"
+		target["averageValue"] = source["targetAverageValue"]
+	case "Object":
+		converted["describedObject"] = source["target"]
+		converted["metric"] = metricIdentifier(source["metricName"], source["selector"])
+		if v, ok := source["averageValue"]; ok {
+			target["type"] = "AverageValue"
+			target["averageValue"] = v
+		} else {
+			target["type"] = "Value"
+			target["value"] = source["targetValue"]
+		}
+	case "External":
+		converted["metric"] = metricIdentifier(source["metricName"], source["metricSelector"])
+		if v, ok := source["targetAverageValue"]; ok {
+			target["type"] = "AverageValue"
+			target["averageValue"] = v
+		} else {
+			target["type"] = "Value"
+			target["value"] = source["targetValue"]
+		}
+	default:
+		return nil, fmt.Errorf("unknown metric type %q", metricType)
+	}
+
+	if _, ok := target["type"]; !ok {
+		return nil, fmt.Errorf("%s metric has no target", metricType)
+	}
+	converted["target"] = target
+	return converted, nil
+}
+
+func metricIdentifier(name interface{}, selector interface{}) map[string]interface{} {
+	identifier := map[string]interface{}{"name": name}
+	if selector != nil {
+		identifier["selector"] = selector
+	}
+	return identifier
+}
+
+// lowerFirst returns the field name holding a metric's source, such as "resource" for the Resource type.
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
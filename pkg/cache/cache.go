@@ -1,28 +1,157 @@
 package cache
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/ghodss/yaml"
+	"github.com/redhat-developer/helm-dump/pkg/fsutil"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
 )
 
+const (
+	// FormatVersion is the version of the cache's layout; caches written by later versions aren't read.
+	FormatVersion = 1
+	// ManifestFileName is the name of the file mapping template names to the hashes of their contents.
+	ManifestFileName = "manifest.json"
+	// ObjectsDir is the directory holding the cached contents, named by their hashes.
+	ObjectsDir = "objects"
+)
+
+// ErrCorrupted is returned when the cache's manifest can't be read or a cached content doesn't match its hash.
+var ErrCorrupted = errors.New("cache is corrupted")
+
+// ErrUnsupportedVersion is returned when the cache was written by a later version of helm-dump.
+var ErrUnsupportedVersion = errors.New("unsupported cache format version")
+
+// Manifest maps template names to the hashes of their cached contents.
+type Manifest struct {
+	Version int               `json:"version"`
+	Entries map[string]string `json:"entries"`
+}
+
+// Cache is the Store keeping the templates generated by init in RootDir, addressed by the hashes of their contents.
 type Cache struct {
 	RootDir string
+
+	manifest *Manifest
 }
 
 var replacer = strings.NewReplacer("/", "_", ".", "_")
 
-func (c *Cache) GetResourcePath(key string) string {
-	maybeResourcePath := filepath.Join(c.RootDir, replacer.Replace(key))
-	return maybeResourcePath
+// legacyNameRegexp matches the names of the files where unversioned caches stored the templates, which are the
+// templates' names flattened by replacer.
+var legacyNameRegexp = regexp.MustCompile(`^templates_[^.]+$`)
+
+// legacyPath returns the path where unversioned caches stored key, flattened into a single file name.
+func (c *Cache) legacyPath(key string) string {
+	return filepath.Join(c.RootDir, replacer.Replace(key))
+}
+
+// legacyNames returns the sorted names of the entries of an unversioned cache which haven't been migrated yet; they
+// can't be told back apart into template names, so they're reported by their flattened names until the templates
+// they belong to are loaded.
+func (c *Cache) legacyNames() ([]string, error) {
+	files, err := ioutil.ReadDir(c.RootDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading cache: %w", err)
+	}
+	var names []string
+	for _, f := range files {
+		if f.Mode().IsRegular() && legacyNameRegexp.MatchString(f.Name()) {
+			names = append(names, f.Name())
+		}
+	}
+	return names, nil
+}
+
+func (c *Cache) manifestPath() string {
+	return filepath.Join(c.RootDir, ManifestFileName)
+}
+
+func (c *Cache) objectPath(hash string) string {
+	return filepath.Join(c.RootDir, ObjectsDir, strings.TrimPrefix(hash, "sha256:"))
+}
+
+// Hash returns the hash identifying data in the cache.
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func (c *Cache) corrupted(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s: %s; remove %q to rebuild it", ErrCorrupted, c.RootDir, fmt.Sprintf(format, args...), c.RootDir)
+}
+
+// Manifest returns the cache's manifest, which is empty if the cache doesn't exist yet.
+func (c *Cache) Manifest() (*Manifest, error) {
+	if c.manifest != nil {
+		return c.manifest, nil
+	}
+
+	data, err := ioutil.ReadFile(c.manifestPath())
+	if errors.Is(err, os.ErrNotExist) {
+		c.manifest = &Manifest{Version: FormatVersion, Entries: make(map[string]string)}
+		return c.manifest, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading cache manifest: %w", err)
+	}
+
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, c.corrupted("invalid manifest: %v", err)
+	}
+	if manifest.Version > FormatVersion {
+		return nil, fmt.Errorf("%w %d in %s; this version of helm-dump supports up to %d", ErrUnsupportedVersion, manifest.Version, c.RootDir, FormatVersion)
+	}
+	if manifest.Version < 1 {
+		return nil, c.corrupted("invalid manifest version %d", manifest.Version)
+	}
+	if manifest.Entries == nil {
+		manifest.Entries = make(map[string]string)
+	}
+	c.manifest = manifest
+	return c.manifest, nil
 }
 
+func (c *Cache) writeManifest() error {
+	data, err := json.MarshalIndent(c.manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling cache manifest: %w", err)
+	}
+	if err := fsutil.WriteFile(c.manifestPath(), data, 0644); err != nil {
+		return fmt.Errorf("error writing cache manifest: %w", err)
+	}
+	return nil
+}
+
+// Lock locks the cache for the calling process, waiting up to timeout for other processes to unlock it; the cache
+// should be locked while it, or the chart holding it, is modified.
+func (c *Cache) Lock(timeout time.Duration) (*fsutil.Lock, error) {
+	return fsutil.LockDir(c.RootDir, timeout)
+}
+
+// Exists returns whether key is cached.
 func (c *Cache) Exists(key string) (bool, error) {
-	_, err := os.Stat(c.GetResourcePath(key))
+	manifest, err := c.Manifest()
+	if err != nil {
+		return false, err
+	}
+	if _, ok := manifest.Entries[key]; ok {
+		return true, nil
+	}
+	_, err = os.Stat(c.legacyPath(key))
 	if errors.Is(err, os.ErrNotExist) {
 		return false, nil
 	}
@@ -32,41 +161,234 @@ func (c *Cache) Exists(key string) (bool, error) {
 	return true, nil
 }
 
+// Store caches data as the content of key.
 func (c *Cache) Store(key string, data []byte) error {
-	if _, err := os.Stat(c.RootDir); errors.Is(err, os.ErrNotExist) {
-		mkdirErr := os.MkdirAll(c.RootDir, os.ModePerm)
-		if mkdirErr != nil {
-			return fmt.Errorf("error creating cache root dir: %w", mkdirErr)
+	manifest, err := c.Manifest()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(c.RootDir, ObjectsDir), os.ModePerm); err != nil {
+		return fmt.Errorf("error creating cache root dir: %w", err)
+	}
+
+	hash := Hash(data)
+	if _, err := os.Stat(c.objectPath(hash)); errors.Is(err, os.ErrNotExist) {
+		if err := fsutil.WriteFile(c.objectPath(hash), data, 0644); err != nil {
+			return fmt.Errorf("error writing resource cache: %w", err)
 		}
 	}
-	err := ioutil.WriteFile(c.GetResourcePath(key), data, 0644)
-	if err != nil {
-		return fmt.Errorf("error writing resource cache: %w", err)
+	previous, overwritten := manifest.Entries[key]
+	manifest.Entries[key] = hash
+	if err := c.writeManifest(); err != nil {
+		return err
+	}
+	if overwritten && previous != hash {
+		if err := c.removeUnreferenced(previous); err != nil {
+			return err
+		}
+	}
+
+	// entries of unversioned caches are migrated once they're stored in the manifest.
+	if err := os.Remove(c.legacyPath(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error removing migrated cache entry: %w", err)
 	}
 	return nil
 }
 
-func (c *Cache) GetCachedResource(key string, data []byte) ([]byte, error) {
-	exists, err := c.Exists(key)
+// Load returns the cached content of key, verifying it matches the hash recorded in the manifest.
+func (c *Cache) Load(key string) ([]byte, error) {
+	manifest, err := c.Manifest()
 	if err != nil {
-		return nil, fmt.Errorf("error checking if cache key exists: %w", err)
+		return nil, err
 	}
-	if exists {
-		cachedBytes, err := ioutil.ReadFile(c.GetResourcePath(key))
+
+	hash, ok := manifest.Entries[key]
+	if !ok {
+		data, err := ioutil.ReadFile(c.legacyPath(key))
 		if err != nil {
 			return nil, fmt.Errorf("error reading cached resource: %w", err)
 		}
-		return cachedBytes, nil
+		if err := c.Store(key, data); err != nil {
+			return nil, err
+		}
+		return data, nil
 	}
 
-	var out map[string]interface{}
-	unmarshalErr := yaml.Unmarshal(data, &out)
-	if unmarshalErr != nil {
-		return nil, fmt.Errorf("error unmarshalling resource bytes: %w", unmarshalErr)
+	data, err := ioutil.ReadFile(c.objectPath(hash))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, c.corrupted("content of %q is missing", key)
 	}
-	storeErr := c.Store(key, data)
-	if storeErr != nil {
-		return nil, storeErr
+	if err != nil {
+		return nil, fmt.Errorf("error reading cached resource: %w", err)
+	}
+	if Hash(data) != hash {
+		return nil, c.corrupted("content of %q doesn't match its hash", key)
 	}
 	return data, nil
 }
+
+// GetCachedResource returns the cached content of key, caching data first if key isn't cached yet.
+func (c *Cache) GetCachedResource(key string, data []byte) ([]byte, error) {
+	return GetCachedResource(c, key, data)
+}
+
+// Stale returns the sorted cached keys missing from keys, which are entries of templates deleted or renamed since
+// they were cached; unmigrated entries of unversioned caches are stale if none of keys flattens to their names.
+func (c *Cache) Stale(keys []string) ([]string, error) {
+	stale, err := Stale(c, keys)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := c.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	flattened := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		flattened[replacer.Replace(k)] = true
+	}
+	kept := stale[:0]
+	for _, k := range stale {
+		if _, ok := manifest.Entries[k]; !ok && flattened[k] {
+			continue
+		}
+		kept = append(kept, k)
+	}
+	return kept, nil
+}
+
+// EntryHash returns the hash of the cached content of key, which is also computed for unmigrated entries of
+// unversioned caches; found is false if key isn't cached.
+func (c *Cache) EntryHash(key string) (hash string, found bool, err error) {
+	manifest, err := c.Manifest()
+	if err != nil {
+		return "", false, err
+	}
+	if hash, ok := manifest.Entries[key]; ok {
+		return hash, true, nil
+	}
+	data, err := ioutil.ReadFile(c.legacyPath(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("error reading cached resource: %w", err)
+	}
+	return Hash(data), true, nil
+}
+
+// Remove removes keys from the cache, along with the contents no longer referenced by any key; keys can also be the
+// names of unmigrated entries of unversioned caches, as returned by Keys.
+func (c *Cache) Remove(keys ...string) error {
+	manifest, err := c.Manifest()
+	if err != nil {
+		return err
+	}
+	removed := make(map[string]bool)
+	for _, k := range keys {
+		if hash, ok := manifest.Entries[k]; ok {
+			removed[hash] = true
+			delete(manifest.Entries, k)
+			continue
+		}
+		if !legacyNameRegexp.MatchString(k) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.RootDir, k)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error removing cached resource: %w", err)
+		}
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+	if err := c.writeManifest(); err != nil {
+		return err
+	}
+	for hash := range removed {
+		if err := c.removeUnreferenced(hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeUnreferenced removes the content identified by hash unless an entry of the manifest still references it.
+func (c *Cache) removeUnreferenced(hash string) error {
+	for _, h := range c.manifest.Entries {
+		if h == hash {
+			return nil
+		}
+	}
+	if err := os.Remove(c.objectPath(hash)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error removing cached resource: %w", err)
+	}
+	return nil
+}
+
+// Keys returns the sorted cached keys, along with the names of the unmigrated entries of unversioned caches.
+func (c *Cache) Keys() ([]string, error) {
+	manifest, err := c.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	legacy, err := c.legacyNames()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(manifest.Entries)+len(legacy))
+	for k := range manifest.Entries {
+		keys = append(keys, k)
+	}
+	keys = append(keys, legacy...)
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Prune removes the entries stale with respect to keys, and the contents not referenced by any entry; the removed
+// entries are returned.
+func (c *Cache) Prune(keys []string) ([]string, error) {
+	stale, err := c.Stale(keys)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Remove(stale...); err != nil {
+		return nil, err
+	}
+
+	referenced := make(map[string]bool)
+	for _, hash := range c.manifest.Entries {
+		referenced[filepath.Base(c.objectPath(hash))] = true
+	}
+	objects, err := ioutil.ReadDir(filepath.Join(c.RootDir, ObjectsDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return stale, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading cached resources: %w", err)
+	}
+	for _, object := range objects {
+		if referenced[object.Name()] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.RootDir, ObjectsDir, object.Name())); err != nil {
+			return nil, fmt.Errorf("error removing cached resource: %w", err)
+		}
+	}
+	return stale, nil
+}
+
+// Clear removes the cache's manifest and contents, along with the entries of unversioned caches; other files in
+// RootDir, such as its lock and journal, are kept.
+func (c *Cache) Clear() error {
+	legacy, err := c.legacyNames()
+	if err != nil {
+		return err
+	}
+	for _, name := range append([]string{ManifestFileName, ObjectsDir}, legacy...) {
+		if err := os.RemoveAll(filepath.Join(c.RootDir, name)); err != nil {
+			return fmt.Errorf("error removing cache: %w", err)
+		}
+	}
+	c.manifest = nil
+	return nil
+}
@@ -0,0 +1,206 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/redhat-developer/helm-dump/pkg/test"
+)
+
+const deployment = "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: nginx\n"
+
+func TestCache(t *testing.T) {
+	t.Run("store-and-load", func(t *testing.T) {
+		// Arrange
+		c := &Cache{RootDir: filepath.Join(test.TempDir(t), ".helm-dump")}
+
+		// Act
+		require.NoError(t, c.Store("templates/deployment/nginx.yaml", []byte(deployment)))
+		actual, err := (&Cache{RootDir: c.RootDir}).Load("templates/deployment/nginx.yaml")
+
+		// Assert
+		require.NoError(t, err)
+		require.Equal(t, deployment, string(actual))
+		manifest, err := ioutil.ReadFile(filepath.Join(c.RootDir, ManifestFileName))
+		require.NoError(t, err)
+		require.Contains(t, string(manifest), `"version": 1`)
+	})
+
+	t.Run("keys-flattening-to-the-same-name", func(t *testing.T) {
+		// Arrange
+		c := &Cache{RootDir: filepath.Join(test.TempDir(t), ".helm-dump")}
+
+		// Act
+		require.NoError(t, c.Store("a.b/c", []byte("first")))
+		require.NoError(t, c.Store("a_b_c", []byte("second")))
+
+		// Assert
+		first, err := c.Load("a.b/c")
+		require.NoError(t, err)
+		require.Equal(t, "first", string(first))
+		second, err := c.Load("a_b_c")
+		require.NoError(t, err)
+		require.Equal(t, "second", string(second))
+	})
+
+	t.Run("unversioned-cache", func(t *testing.T) {
+		// Arrange
+		rootDir := filepath.Join(test.TempDir(t), ".helm-dump")
+		require.NoError(t, os.MkdirAll(rootDir, os.ModePerm))
+		legacyPath := filepath.Join(rootDir, "templates_nginx_yaml")
+		require.NoError(t, ioutil.WriteFile(legacyPath, []byte(deployment), 0644))
+		c := &Cache{RootDir: rootDir}
+
+		// Act
+		actual, err := c.GetCachedResource("templates/nginx.yaml", []byte("modified"))
+
+		// Assert
+		require.NoError(t, err)
+		require.Equal(t, deployment, string(actual))
+		_, err = os.Stat(legacyPath)
+		require.True(t, os.IsNotExist(err), "the entry should be migrated")
+		actual, err = (&Cache{RootDir: rootDir}).Load("templates/nginx.yaml")
+		require.NoError(t, err)
+		require.Equal(t, deployment, string(actual))
+	})
+
+	t.Run("overwritten-entry", func(t *testing.T) {
+		// Arrange
+		c := &Cache{RootDir: filepath.Join(test.TempDir(t), ".helm-dump")}
+		require.NoError(t, c.Store("templates/nginx.yaml", []byte(deployment)))
+		require.NoError(t, c.Store("templates/shared.yaml", []byte("shared")))
+		require.NoError(t, c.Store("templates/copy.yaml", []byte("shared")))
+
+		// Act
+		require.NoError(t, c.Store("templates/nginx.yaml", []byte("modified")))
+		require.NoError(t, c.Store("templates/copy.yaml", []byte("modified")))
+
+		// Assert
+		_, err := os.Stat(c.objectPath(Hash([]byte(deployment))))
+		require.True(t, os.IsNotExist(err), "the overwritten content should be removed")
+		_, err = os.Stat(c.objectPath(Hash([]byte("shared"))))
+		require.NoError(t, err, "contents still referenced should be kept")
+	})
+
+	t.Run("unmigrated-entries", func(t *testing.T) {
+		// Arrange
+		rootDir := filepath.Join(test.TempDir(t), ".helm-dump")
+		require.NoError(t, os.MkdirAll(rootDir, os.ModePerm))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(rootDir, "templates_nginx_yaml"), []byte(deployment), 0644))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(rootDir, "templates_deleted_yaml"), []byte(deployment), 0644))
+		c := &Cache{RootDir: rootDir}
+		require.NoError(t, c.Store("templates/configmap.yaml", []byte("configmap")))
+
+		// Act
+		keys, err := c.Keys()
+		require.NoError(t, err)
+		stale, err := c.Stale([]string{"templates/nginx.yaml", "templates/configmap.yaml"})
+		require.NoError(t, err)
+		require.NoError(t, c.Remove(stale...))
+
+		// Assert
+		require.Equal(t, []string{"templates/configmap.yaml", "templates_deleted_yaml", "templates_nginx_yaml"}, keys)
+		require.Equal(t, []string{"templates_deleted_yaml"}, stale)
+		_, err = os.Stat(filepath.Join(rootDir, "templates_deleted_yaml"))
+		require.True(t, os.IsNotExist(err), "the stale entry should be removed")
+		hash, found, err := c.EntryHash("templates/nginx.yaml")
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, Hash([]byte(deployment)), hash)
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		// Arrange
+		c := &Cache{RootDir: filepath.Join(test.TempDir(t), ".helm-dump")}
+		require.NoError(t, c.Store("templates/nginx.yaml", []byte(deployment)))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(c.RootDir, "templates_configmap_yaml"), []byte("legacy"), 0644))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(c.RootDir, "Chart.yaml"), []byte("name: my-chart\n"), 0644))
+
+		// Act
+		err := c.Clear()
+
+		// Assert
+		require.NoError(t, err)
+		entries, err := os.ReadDir(c.RootDir)
+		require.NoError(t, err)
+		require.Len(t, entries, 1, "only the cache's files should be removed")
+		require.Equal(t, "Chart.yaml", entries[0].Name())
+	})
+
+	t.Run("corrupted-content", func(t *testing.T) {
+		// Arrange
+		c := &Cache{RootDir: filepath.Join(test.TempDir(t), ".helm-dump")}
+		require.NoError(t, c.Store("templates/nginx.yaml", []byte(deployment)))
+		require.NoError(t, ioutil.WriteFile(c.objectPath(Hash([]byte(deployment))), []byte("tampered"), 0644))
+
+		// Act
+		_, err := (&Cache{RootDir: c.RootDir}).Load("templates/nginx.yaml")
+
+		// Assert
+		require.ErrorIs(t, err, ErrCorrupted)
+	})
+
+	t.Run("missing-content", func(t *testing.T) {
+		// Arrange
+		c := &Cache{RootDir: filepath.Join(test.TempDir(t), ".helm-dump")}
+		require.NoError(t, c.Store("templates/nginx.yaml", []byte(deployment)))
+		require.NoError(t, os.RemoveAll(filepath.Join(c.RootDir, ObjectsDir)))
+
+		// Act
+		_, err := c.GetCachedResource("templates/nginx.yaml", []byte(deployment))
+
+		// Assert
+		require.ErrorIs(t, err, ErrCorrupted)
+	})
+
+	t.Run("corrupted-manifest", func(t *testing.T) {
+		// Arrange
+		rootDir := filepath.Join(test.TempDir(t), ".helm-dump")
+		require.NoError(t, os.MkdirAll(rootDir, os.ModePerm))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(rootDir, ManifestFileName), []byte(`{"version": 1, "entr`), 0644))
+
+		// Act
+		_, err := (&Cache{RootDir: rootDir}).Exists("templates/nginx.yaml")
+
+		// Assert
+		require.ErrorIs(t, err, ErrCorrupted)
+	})
+
+	t.Run("later-format-version", func(t *testing.T) {
+		// Arrange
+		rootDir := filepath.Join(test.TempDir(t), ".helm-dump")
+		require.NoError(t, os.MkdirAll(rootDir, os.ModePerm))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(rootDir, ManifestFileName), []byte(`{"version": 99, "entries": {}}`), 0644))
+
+		// Act
+		err := (&Cache{RootDir: rootDir}).Store("templates/nginx.yaml", []byte(deployment))
+
+		// Assert
+		require.ErrorIs(t, err, ErrUnsupportedVersion)
+	})
+
+	t.Run("stale-entries", func(t *testing.T) {
+		// Arrange
+		c := &Cache{RootDir: filepath.Join(test.TempDir(t), ".helm-dump")}
+		require.NoError(t, c.Store("templates/nginx.yaml", []byte(deployment)))
+		require.NoError(t, c.Store("templates/renamed.yaml", []byte("renamed")))
+		require.NoError(t, c.Store("templates/deleted.yaml", []byte(deployment)))
+
+		// Act
+		stale, err := c.Stale([]string{"templates/nginx.yaml", "templates/new-name.yaml"})
+		require.NoError(t, err)
+		require.NoError(t, c.Remove(stale...))
+
+		// Assert
+		require.Equal(t, []string{"templates/deleted.yaml", "templates/renamed.yaml"}, stale)
+		_, err = os.Stat(c.objectPath(Hash([]byte("renamed"))))
+		require.True(t, os.IsNotExist(err), "unreferenced contents should be removed")
+		actual, err := c.Load("templates/nginx.yaml")
+		require.NoError(t, err, "contents still referenced should be kept")
+		require.Equal(t, deployment, string(actual))
+	})
+}
@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ghodss/yaml"
+
+	"github.com/redhat-developer/helm-dump/pkg/helm/chartutil"
+)
+
+// Store keeps the templates generated by init, so commands modifying a chart have access to the original templates.
+type Store interface {
+	// Exists returns whether key is cached.
+	Exists(key string) (bool, error)
+	// Load returns the cached content of key.
+	Load(key string) ([]byte, error)
+	// Store caches data as the content of key.
+	Store(key string, data []byte) error
+	// Keys returns the sorted cached keys.
+	Keys() ([]string, error)
+	// Remove removes keys from the cache.
+	Remove(keys ...string) error
+}
+
+var (
+	_ Store = &Cache{}
+	_ Store = &MemoryStore{}
+)
+
+// GetCachedResource returns the content of key cached in s, caching data first if key isn't cached yet.
+func GetCachedResource(s Store, key string, data []byte) ([]byte, error) {
+	exists, err := s.Exists(key)
+	if err != nil {
+		return nil, fmt.Errorf("error checking if cache key exists: %w", err)
+	}
+	if exists {
+		return s.Load(key)
+	}
+
+	var out map[string]interface{}
+	unmarshalErr := yaml.Unmarshal(chartutil.MaskStandaloneActions(data), &out)
+	if unmarshalErr != nil {
+		return nil, fmt.Errorf("error unmarshalling resource bytes: %w", unmarshalErr)
+	}
+	storeErr := s.Store(key, data)
+	if storeErr != nil {
+		return nil, storeErr
+	}
+	return data, nil
+}
+
+// Stale returns the sorted keys cached in s missing from keys, which are entries of templates deleted or renamed
+// since they were cached.
+func Stale(s Store, keys []string) ([]string, error) {
+	cached, err := s.Keys()
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		current[k] = true
+	}
+	var stale []string
+	for _, k := range cached {
+		if !current[k] {
+			stale = append(stale, k)
+		}
+	}
+	sort.Strings(stale)
+	return stale, nil
+}
@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/redhat-developer/helm-dump/pkg/test"
+)
+
+func TestStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"filesystem": func(t *testing.T) Store {
+			return &Cache{RootDir: filepath.Join(test.TempDir(t), ".helm-dump")}
+		},
+		"memory": func(t *testing.T) Store {
+			return NewMemoryStore()
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			t.Run("get-cached-resource", func(t *testing.T) {
+				// Arrange
+				s := newStore(t)
+
+				// Act
+				first, err := GetCachedResource(s, "templates/nginx.yaml", []byte(deployment))
+				require.NoError(t, err)
+				second, err := GetCachedResource(s, "templates/nginx.yaml", []byte("kind: Edited\n"))
+
+				// Assert
+				require.NoError(t, err)
+				require.Equal(t, deployment, string(first))
+				require.Equal(t, deployment, string(second), "the content cached first should be returned")
+			})
+
+			t.Run("invalid-resource", func(t *testing.T) {
+				// Act
+				_, err := GetCachedResource(newStore(t), "templates/nginx.yaml", []byte("kind: [\n"))
+
+				// Assert
+				require.Error(t, err)
+			})
+
+			t.Run("stale-and-remove", func(t *testing.T) {
+				// Arrange
+				s := newStore(t)
+				require.NoError(t, s.Store("templates/a.yaml", []byte("a")))
+				require.NoError(t, s.Store("templates/b.yaml", []byte("b")))
+
+				// Act
+				stale, err := Stale(s, []string{"templates/a.yaml"})
+				require.NoError(t, err)
+				require.NoError(t, s.Remove(stale...))
+
+				// Assert
+				require.Equal(t, []string{"templates/b.yaml"}, stale)
+				keys, err := s.Keys()
+				require.NoError(t, err)
+				require.Equal(t, []string{"templates/a.yaml"}, keys)
+				exists, err := s.Exists("templates/b.yaml")
+				require.NoError(t, err)
+				require.False(t, exists)
+			})
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package plugin
+
+import (
+	"fmt"
+
+	"github.com/konveyor/crane-lib/transform"
+	"github.com/sirupsen/logrus"
+
+	"github.com/redhat-developer/helm-dump/pkg/crane/plugin/cleanup"
+	"github.com/redhat-developer/helm-dump/pkg/crane/plugin/helmdumpinit"
+)
+
+// builtinPlugins are the plugins compiled into helm-dump, in the order they're run.
+var builtinPlugins = []transform.Plugin{
+	helmdumpinit.NewPlugin(),
+	cleanup.NewPlugin(),
+}
+
+// BuiltinPluginNames returns the names of all plugins compiled into helm-dump.
+func BuiltinPluginNames() []string {
+	names := make([]string, 0, len(builtinPlugins))
+	for _, p := range builtinPlugins {
+		names = append(names, p.Metadata().Name)
+	}
+	return names
+}
+
+// GetBuiltinPlugins returns the built-in plugins matching the given names, in registration order; an error is
+// returned if any of the names isn't a built-in plugin.
+func GetBuiltinPlugins(names []string) ([]transform.Plugin, error) {
+	known := make(map[string]bool, len(builtinPlugins))
+	for _, p := range builtinPlugins {
+		known[p.Metadata().Name] = true
+	}
+	for _, name := range names {
+		if !known[name] {
+			return nil, fmt.Errorf("unknown built-in plugin %q; available plugins are %v", name, BuiltinPluginNames())
+		}
+	}
+
+	var pluginList []transform.Plugin
+	for _, p := range builtinPlugins {
+		if isPluginInList(p, names) {
+			pluginList = append(pluginList, p)
+		}
+	}
+	return pluginList, nil
+}
+
+// GetLayeredPlugins returns the selected built-in plugins followed by the plugins found in pluginDir; a binary
+// plugin replaces the built-in plugin with the same name. Plugins listed in skipPlugins are removed from the result.
+func GetLayeredPlugins(builtinNames []string, pluginDir string, skipPlugins []string, logger *logrus.Logger) ([]transform.Plugin, error) {
+	builtins, err := GetBuiltinPlugins(builtinNames)
+	if err != nil {
+		return nil, err
+	}
+
+	discovered, err := GetPlugins(pluginDir, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	discoveredNames := make([]string, 0, len(discovered))
+	for _, p := range discovered {
+		discoveredNames = append(discoveredNames, p.Metadata().Name)
+	}
+
+	var pluginList []transform.Plugin
+	for _, p := range builtins {
+		if isPluginInList(p, discoveredNames) {
+			logger.Debugf("built-in plugin %q overridden by binary plugin in %s", p.Metadata().Name, pluginDir)
+			continue
+		}
+		pluginList = append(pluginList, p)
+	}
+	pluginList = append(pluginList, discovered...)
+
+	return filterPlugins(pluginList, skipPlugins), nil
+}
@@ -0,0 +1,44 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/redhat-developer/helm-dump/pkg/crane/plugin/cleanup"
+	"github.com/redhat-developer/helm-dump/pkg/crane/plugin/helmdumpinit"
+	hdtesting "github.com/redhat-developer/helm-dump/pkg/test"
+)
+
+func pluginNames(t *testing.T, names []string, pluginDir string, skipPlugins []string) []string {
+	plugins, err := GetLayeredPlugins(names, pluginDir, skipPlugins, logrus.New())
+	require.NoError(t, err)
+	actual := make([]string, 0, len(plugins))
+	for _, p := range plugins {
+		actual = append(actual, p.Metadata().Name)
+	}
+	return actual
+}
+
+func TestGetLayeredPlugins(t *testing.T) {
+	t.Run("all-builtin-plugins", func(t *testing.T) {
+		actual := pluginNames(t, BuiltinPluginNames(), hdtesting.TempDir(t), nil)
+		require.Equal(t, []string{helmdumpinit.Name, cleanup.Name, "KubernetesPlugin"}, actual)
+	})
+
+	t.Run("selected-builtin-plugins", func(t *testing.T) {
+		actual := pluginNames(t, []string{cleanup.Name}, hdtesting.TempDir(t), nil)
+		require.Equal(t, []string{cleanup.Name, "KubernetesPlugin"}, actual)
+	})
+
+	t.Run("skipped-plugins", func(t *testing.T) {
+		actual := pluginNames(t, BuiltinPluginNames(), hdtesting.TempDir(t), []string{helmdumpinit.Name, "KubernetesPlugin"})
+		require.Equal(t, []string{cleanup.Name}, actual)
+	})
+
+	t.Run("unknown-builtin-plugin", func(t *testing.T) {
+		_, err := GetLayeredPlugins([]string{"Unknown"}, hdtesting.TempDir(t), nil, logrus.New())
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,72 @@
+package cleanup
+
+import (
+	"fmt"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/konveyor/crane-lib/transform"
+	"github.com/konveyor/crane-lib/transform/cli"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Name is the name the plugin is registered with.
+const Name = "HelmDumpCleanup"
+
+// NewPlugin returns the HelmDumpCleanup plugin, which removes data added by the cluster or by client tooling that
+// shouldn't be part of a chart template.
+func NewPlugin() transform.Plugin {
+	return cli.NewCustomPlugin(Name, "v1", nil, Run)
+}
+
+// annotationsToRemove are annotations maintained by controllers and client tooling.
+var annotationsToRemove = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+	"deployment.kubernetes.io/revision",
+}
+
+// fieldsToRemove are fields assigned by the API server which are not covered by the Kubernetes crane plugin.
+var fieldsToRemove = [][]string{
+	{"spec", "template", "metadata", "creationTimestamp"},
+}
+
+// escapePathSegment escapes a JSON pointer segment as described in RFC 6901.
+var escapePathSegment = strings.NewReplacer("~", "~0", "/", "~1").Replace
+
+func Run(request transform.PluginRequest) (transform.PluginResponse, error) {
+	obj := request.Unstructured
+	resp := transform.PluginResponse{
+		Version: "v1",
+		Patches: nil,
+	}
+
+	var opsJSON []string
+
+	anns := obj.GetAnnotations()
+	for _, ann := range annotationsToRemove {
+		if _, ok := anns[ann]; ok {
+			opsJSON = append(opsJSON, fmt.Sprintf(`{"op": "remove", "path": "/metadata/annotations/%s"}`, escapePathSegment(ann)))
+		}
+	}
+
+	for _, field := range fieldsToRemove {
+		if _, found, _ := unstructured.NestedFieldNoCopy(obj.Object, field...); found {
+			opsJSON = append(opsJSON, fmt.Sprintf(`{"op": "remove", "path": "/%s"}`, strings.Join(field, "/")))
+		}
+	}
+
+	if len(opsJSON) == 0 {
+		return resp, nil
+	}
+
+	patchJSON := fmt.Sprintf("[%s]", strings.Join(opsJSON, ","))
+
+	patch, err := jsonpatch.DecodePatch([]byte(patchJSON))
+	if err != nil {
+		return transform.PluginResponse{}, err
+	}
+
+	resp.Patches = patch
+
+	return resp, nil
+}
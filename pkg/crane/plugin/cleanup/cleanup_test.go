@@ -0,0 +1,49 @@
+package cleanup
+
+import (
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/konveyor/crane-lib/transform"
+	"github.com/redhat-developer/helm-dump/pkg/test"
+	"github.com/stretchr/testify/require"
+)
+
+func requireRemoveOp(t *testing.T, op jsonpatch.Operation, expectedPath string) {
+	require.Equal(t, "remove", op.Kind())
+	actual, err := op.Path()
+	require.NoError(t, err)
+	require.Equal(t, expectedPath, actual)
+}
+
+func TestRun(t *testing.T) {
+
+	t.Run("with-cluster-data", func(t *testing.T) {
+		// Arrange
+		fixture := test.LoadYamlFixture(t, "test/test_run/nginx-deployment-with-cluster-data.yaml")
+		req := transform.PluginRequest{Unstructured: *fixture}
+
+		// Act
+		resp, err := Run(req)
+		require.NoError(t, err)
+
+		// Assert
+		require.Len(t, resp.Patches, 3)
+		requireRemoveOp(t, resp.Patches[0], "/metadata/annotations/kubectl.kubernetes.io~1last-applied-configuration")
+		requireRemoveOp(t, resp.Patches[1], "/metadata/annotations/deployment.kubernetes.io~1revision")
+		requireRemoveOp(t, resp.Patches[2], "/spec/template/metadata/creationTimestamp")
+	})
+
+	t.Run("without-cluster-data", func(t *testing.T) {
+		// Arrange
+		fixture := test.LoadYamlFixture(t, "test/test_run/nginx-deployment-without-metadata.yaml")
+		req := transform.PluginRequest{Unstructured: *fixture}
+
+		// Act
+		resp, err := Run(req)
+		require.NoError(t, err)
+
+		// Assert
+		require.Empty(t, resp.Patches)
+	})
+}
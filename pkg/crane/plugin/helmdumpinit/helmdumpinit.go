@@ -0,0 +1,148 @@
+package helmdumpinit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/konveyor/crane-lib/transform"
+	"github.com/konveyor/crane-lib/transform/cli"
+)
+
+const (
+	// Name is the name the plugin is registered with.
+	Name = "HelmDumpInit"
+	// ChartNameField is the optional field informing the name of the chart being generated.
+	ChartNameField = "chart-name"
+	// NamingStrategyField is the optional field informing how resource names are templated.
+	NamingStrategyField = "naming-strategy"
+	// StandardLabelsField is the optional field enabling the use of the chart's labels and selectorLabels helpers.
+	StandardLabelsField = "standard-labels"
+	// TemplateNamespaceField is the optional field controlling whether namespaces are replaced by the release's.
+	TemplateNamespaceField = "template-namespace"
+
+	// NamespaceTemplate is the template replacing the namespace of namespaced resources.
+	NamespaceTemplate = "{{ .Release.Namespace }}"
+)
+
+// OptionalFields are the optional fields understood by the plugin.
+var OptionalFields = []transform.OptionalFields{
+	{
+		FlagName: ChartNameField,
+		Help:     "The name of the chart being generated",
+		Example:  "my-chart",
+	},
+	{
+		FlagName: NamingStrategyField,
+		Help:     fmt.Sprintf("How resource names are templated, one of %v; defaults to %s", NamingStrategies, DefaultNamingStrategy),
+		Example:  string(Fullname),
+	},
+	{
+		FlagName: StandardLabelsField,
+		Help:     "Use the chart's labels and selectorLabels helpers for labels, selectors and pod templates",
+		Example:  "true",
+	},
+	{
+		FlagName: TemplateNamespaceField,
+		Help:     "Replace the namespace of resources by the release's namespace; defaults to true",
+		Example:  "false",
+	},
+}
+
+// ParseTemplateNamespace returns whether namespaces should be templated according to the value of the
+// template-namespace field.
+func ParseTemplateNamespace(v string) (bool, error) {
+	if v == "" {
+		return true, nil
+	}
+	templateNamespace, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s value %q: %w", TemplateNamespaceField, v, err)
+	}
+	return templateNamespace, nil
+}
+
+// NewPlugin returns the HelmDumpInit plugin, suitable to be run in-process or wrapped in a binary plugin.
+func NewPlugin() transform.Plugin {
+	return cli.NewCustomPlugin(Name, "v1", OptionalFields, Run)
+}
+
+func Run(request transform.PluginRequest) (transform.PluginResponse, error) {
+	obj := request.Unstructured
+	resp := transform.PluginResponse{
+		Version: "v1",
+		Patches: nil,
+	}
+
+	chartName, ok := request.Extras[ChartNameField]
+	if !ok {
+		return transform.PluginResponse{}, fmt.Errorf("chart-name should be informed")
+	}
+
+	namingStrategy, err := ParseNamingStrategy(request.Extras[NamingStrategyField])
+	if err != nil {
+		return transform.PluginResponse{}, err
+	}
+
+	standardLabels := false
+	if v, ok := request.Extras[StandardLabelsField]; ok && v != "" {
+		standardLabels, err = strconv.ParseBool(v)
+		if err != nil {
+			return transform.PluginResponse{}, fmt.Errorf("invalid %s value %q: %w", StandardLabelsField, v, err)
+		}
+	}
+
+	templateNamespace, err := ParseTemplateNamespace(request.Extras[TemplateNamespaceField])
+	if err != nil {
+		return transform.PluginResponse{}, err
+	}
+
+	var opsJSON []string
+
+	// patch the object's name accordingly
+	newName := namingStrategy.TemplateName(chartName, obj.GetKind(), obj.GetName())
+	opsJSON = append(opsJSON, fmt.Sprintf(`{"op": "add", "path": "/metadata/name", "value": %q}`, newName))
+
+	if labels := obj.GetLabels(); labels == nil {
+		opsJSON = append(opsJSON, `{"op": "add", "path": "/metadata/labels", "value": {}}`)
+	}
+
+	if standardLabels {
+		opsJSON = append(opsJSON, includeOps(obj, "/metadata/labels", LabelsHelper)...)
+		for _, selectorPath := range selectorPaths(obj) {
+			opsJSON = append(opsJSON, includeOps(obj, selectorPath, SelectorLabelsHelper)...)
+		}
+	} else {
+		opsJSON = append(opsJSON,
+			fmt.Sprintf(`{"op": "add", "path": "/metadata/labels/app.kubernetes.io~1name", "value": "{{ template \"%s.fullname\" $ }}"}`, chartName),
+			`{"op": "add", "path": "/metadata/labels/app.kubernetes.io~1instance", "value": "{{ $.Release.Name }}"}`,
+		)
+	}
+
+	// keep the original name for modifications in other commands (so removing Helm
+	// template data from the name is not necessary in other CLI commands).
+	if anns := obj.GetAnnotations(); anns == nil {
+		opsJSON = append(opsJSON, `{"op": "add", "path": "/metadata/annotations", "value": {}}`)
+	}
+
+	opsJSON = append(opsJSON, fmt.Sprintf(`{"op": "add", "path": "/metadata/annotations/helm-dump~1name", "value": "%s"}`, obj.GetName()))
+
+	opsJSON = append(opsJSON, `{"op": "remove", "path": "/metadata/managedFields"}`)
+
+	// resources are installed in the release's namespace rather than the namespace they were collected from.
+	if templateNamespace && obj.GetNamespace() != "" {
+		opsJSON = append(opsJSON, fmt.Sprintf(`{"op": "add", "path": "/metadata/namespace", "value": %q}`, NamespaceTemplate))
+	}
+
+	patchJSON := fmt.Sprintf("[%s]", strings.Join(opsJSON, ","))
+
+	patch, err := jsonpatch.DecodePatch([]byte(patchJSON))
+	if err != nil {
+		return transform.PluginResponse{}, err
+	}
+
+	resp.Patches = patch
+
+	return resp, nil
+}
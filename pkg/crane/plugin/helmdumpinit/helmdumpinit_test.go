@@ -0,0 +1,223 @@
+package helmdumpinit
+
+import (
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/konveyor/crane-lib/transform"
+	"github.com/redhat-developer/helm-dump/pkg/test"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"testing"
+)
+
+type OperationAsserter jsonpatch.Operation
+
+func (m OperationAsserter) requirePath(t *testing.T, expected string) {
+	p := jsonpatch.Operation(m)
+	actual, err := p.Path()
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+}
+
+func (m OperationAsserter) requireValue(t *testing.T, expected interface{}) {
+	p := jsonpatch.Operation(m)
+	actual, err := p.ValueInterface()
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+}
+
+func (m OperationAsserter) requireKind(t *testing.T, expected string) {
+	p := jsonpatch.Operation(m)
+	require.Equal(t, expected, p.Kind())
+}
+
+func TestRun(t *testing.T) {
+
+	t.Run("with-metadata", func(t *testing.T) {
+		// Arrange
+		fixture := test.LoadYamlFixture(t, "test/test_run/nginx-deployment-with-metadata.yaml")
+		req := transform.PluginRequest{
+			Unstructured: *fixture,
+			Extras: map[string]string{
+				ChartNameField: "my-app",
+			},
+		}
+
+		// Act
+		resp, err := Run(req)
+		require.NoError(t, err)
+
+		// Assert
+		require.Len(t, resp.Patches, 6)
+
+		nameOp := OperationAsserter(resp.Patches[0])
+		nameOp.requireKind(t, "add")
+		nameOp.requirePath(t, "/metadata/name")
+		nameOp.requireValue(t, `nginx-deployment-{{ .Release.Name | trunc 46 | trimSuffix "-" }}`)
+
+		appNameOp := OperationAsserter(resp.Patches[1])
+		appNameOp.requireKind(t, "add")
+		appNameOp.requirePath(t, "/metadata/labels/app.kubernetes.io~1name")
+		appNameOp.requireValue(t, `{{ template "my-app.fullname" $ }}`)
+
+		appInstanceOp := OperationAsserter(resp.Patches[2])
+		appInstanceOp.requireKind(t, "add")
+		appInstanceOp.requirePath(t, "/metadata/labels/app.kubernetes.io~1instance")
+		appInstanceOp.requireValue(t, "{{ $.Release.Name }}")
+
+		appNameAnnotationOp := OperationAsserter(resp.Patches[3])
+		appNameAnnotationOp.requireKind(t, "add")
+		appNameAnnotationOp.requirePath(t, "/metadata/annotations/helm-dump~1name")
+		appNameAnnotationOp.requireValue(t, "nginx-deployment")
+
+		namespaceOp := OperationAsserter(resp.Patches[5])
+		namespaceOp.requireKind(t, "add")
+		namespaceOp.requirePath(t, "/metadata/namespace")
+		namespaceOp.requireValue(t, NamespaceTemplate)
+	})
+	t.Run("without-metadata", func(t *testing.T) {
+		// Arrange
+		fixture := test.LoadYamlFixture(t, "test/test_run/nginx-deployment-without-metadata.yaml")
+		req := transform.PluginRequest{
+			Unstructured: *fixture,
+			Extras: map[string]string{
+				ChartNameField: "my-app",
+			},
+		}
+
+		// Act
+		resp, err := Run(req)
+		require.NoError(t, err)
+
+		// Assert
+		require.Len(t, resp.Patches, 8)
+
+		nameOp := OperationAsserter(resp.Patches[0])
+		nameOp.requireKind(t, "add")
+		nameOp.requirePath(t, "/metadata/name")
+		nameOp.requireValue(t, `nginx-deployment-{{ .Release.Name | trunc 46 | trimSuffix "-" }}`)
+
+		labelsOp := OperationAsserter(resp.Patches[1])
+		labelsOp.requireKind(t, "add")
+		labelsOp.requirePath(t, "/metadata/labels")
+		labelsOp.requireValue(t, map[string]interface{}{})
+
+		appNameOp := OperationAsserter(resp.Patches[2])
+		appNameOp.requireKind(t, "add")
+		appNameOp.requirePath(t, "/metadata/labels/app.kubernetes.io~1name")
+		appNameOp.requireValue(t, `{{ template "my-app.fullname" $ }}`)
+
+		appInstanceOp := OperationAsserter(resp.Patches[3])
+		appInstanceOp.requireKind(t, "add")
+		appInstanceOp.requirePath(t, "/metadata/labels/app.kubernetes.io~1instance")
+		appInstanceOp.requireValue(t, "{{ $.Release.Name }}")
+
+		annsOp := OperationAsserter(resp.Patches[4])
+		annsOp.requireKind(t, "add")
+		annsOp.requirePath(t, "/metadata/annotations")
+		annsOp.requireValue(t, map[string]interface{}{})
+
+		appNameAnnotationOp := OperationAsserter(resp.Patches[5])
+		appNameAnnotationOp.requireKind(t, "add")
+		appNameAnnotationOp.requirePath(t, "/metadata/annotations/helm-dump~1name")
+		appNameAnnotationOp.requireValue(t, "nginx-deployment")
+
+	})
+
+	t.Run("with-standard-labels", func(t *testing.T) {
+		// Arrange
+		fixture := test.LoadYamlFixture(t, "test/test_run/nginx-deployment-with-metadata.yaml")
+		req := transform.PluginRequest{
+			Unstructured: *fixture,
+			Extras: map[string]string{
+				ChartNameField:      "my-app",
+				StandardLabelsField: "true",
+			},
+		}
+
+		// Act
+		resp, err := Run(req)
+		require.NoError(t, err)
+
+		// Assert
+		require.Len(t, resp.Patches, 7)
+
+		labelsOp := OperationAsserter(resp.Patches[1])
+		labelsOp.requireKind(t, "add")
+		labelsOp.requirePath(t, "/metadata/labels/helm-dump~1include")
+		labelsOp.requireValue(t, LabelsHelper)
+
+		selectorOp := OperationAsserter(resp.Patches[2])
+		selectorOp.requireKind(t, "add")
+		selectorOp.requirePath(t, "/spec/selector/matchLabels/helm-dump~1include")
+		selectorOp.requireValue(t, SelectorLabelsHelper)
+
+		podTemplateOp := OperationAsserter(resp.Patches[3])
+		podTemplateOp.requireKind(t, "add")
+		podTemplateOp.requirePath(t, "/spec/template/metadata/labels/helm-dump~1include")
+		podTemplateOp.requireValue(t, SelectorLabelsHelper)
+	})
+
+	t.Run("with-standard-labels-already-set", func(t *testing.T) {
+		// Arrange
+		fixture := test.LoadYamlFixture(t, "test/test_run/nginx-deployment-with-metadata.yaml")
+		fixture.SetLabels(map[string]string{
+			"app":                          "nginx",
+			"app.kubernetes.io/name":       "nginx",
+			"app.kubernetes.io/managed-by": "Helm",
+		})
+		fixture.SetManagedFields([]metav1.ManagedFieldsEntry{{Manager: "helm"}})
+		matchLabels := map[string]interface{}{"app": "nginx", "app.kubernetes.io/instance": "production"}
+		require.NoError(t, unstructured.SetNestedMap(fixture.Object, matchLabels, "spec", "selector", "matchLabels"))
+		req := transform.PluginRequest{
+			Unstructured: *fixture,
+			Extras: map[string]string{
+				ChartNameField:      "my-app",
+				StandardLabelsField: "true",
+			},
+		}
+
+		// Act
+		resp, err := Run(req)
+		require.NoError(t, err)
+
+		// Assert
+		data, err := fixture.MarshalJSON()
+		require.NoError(t, err)
+		data, err = resp.Patches.Apply(data)
+		require.NoError(t, err)
+		patched := &unstructured.Unstructured{}
+		require.NoError(t, patched.UnmarshalJSON(data))
+		require.Equal(t, map[string]string{"app": "nginx", includeMarker: LabelsHelper}, patched.GetLabels(),
+			"the labels rendered by the helper should be removed")
+		actualMatchLabels, _, err := unstructured.NestedStringMap(patched.Object, "spec", "selector", "matchLabels")
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"app": "nginx", includeMarker: SelectorLabelsHelper}, actualMatchLabels,
+			"the labels rendered by the helper should be removed")
+	})
+
+	t.Run("without-template-namespace", func(t *testing.T) {
+		// Arrange
+		fixture := test.LoadYamlFixture(t, "test/test_run/nginx-deployment-with-metadata.yaml")
+		req := transform.PluginRequest{
+			Unstructured: *fixture,
+			Extras: map[string]string{
+				ChartNameField:         "my-app",
+				TemplateNamespaceField: "false",
+			},
+		}
+
+		// Act
+		resp, err := Run(req)
+		require.NoError(t, err)
+
+		// Assert
+		require.Len(t, resp.Patches, 5)
+		for _, op := range resp.Patches {
+			path, err := op.Path()
+			require.NoError(t, err)
+			require.NotEqual(t, "/metadata/namespace", path)
+		}
+	})
+
+}
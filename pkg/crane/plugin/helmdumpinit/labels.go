@@ -0,0 +1,87 @@
+package helmdumpinit
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// LabelsHelper is the name of the chart's common labels helper, without the chart name prefix.
+	LabelsHelper = "labels"
+	// SelectorLabelsHelper is the name of the chart's selector labels helper, without the chart name prefix.
+	SelectorLabelsHelper = "selectorLabels"
+
+	// includeMarker is the label key marking a map where a helper should be included; an include action can't be
+	// expressed as a field, so the marker is replaced by the include action by RenderIncludes once the resource is
+	// serialized.
+	includeMarker = "helm-dump/include"
+)
+
+// selectorPathsByKind are the paths of label selectors and of the labels they must match, for kinds where the
+// selector labels helper is included.
+var selectorPathsByKind = map[string][]string{
+	"Deployment":  {"/spec/selector/matchLabels", "/spec/template/metadata/labels"},
+	"StatefulSet": {"/spec/selector/matchLabels", "/spec/template/metadata/labels"},
+	"DaemonSet":   {"/spec/selector/matchLabels", "/spec/template/metadata/labels"},
+	"ReplicaSet":  {"/spec/selector/matchLabels", "/spec/template/metadata/labels"},
+	"Service":     {"/spec/selector"},
+}
+
+// selectorPaths returns the paths where the selector labels helper should be included for obj.
+func selectorPaths(obj unstructured.Unstructured) []string {
+	// services without a selector, such as ExternalName services, select no pods and must be kept that way.
+	if obj.GetKind() == "Service" {
+		if _, found, _ := unstructured.NestedMap(obj.Object, "spec", "selector"); !found {
+			return nil
+		}
+	}
+	return selectorPathsByKind[obj.GetKind()]
+}
+
+// helperLabels are the keys of the labels each helper renders, as defined by the helpers of created charts.
+var helperLabels = map[string][]string{
+	LabelsHelper: {
+		"helm.sh/chart",
+		"app.kubernetes.io/name",
+		"app.kubernetes.io/instance",
+		"app.kubernetes.io/version",
+		"app.kubernetes.io/managed-by",
+	},
+	SelectorLabelsHelper: {"app.kubernetes.io/name", "app.kubernetes.io/instance"},
+}
+
+// includeOps returns the operations adding the include marker for helper to the map of labels of obj at path, along
+// with the operations removing the labels rendered by helper from it, which would otherwise be duplicate keys.
+func includeOps(obj unstructured.Unstructured, path string, helper string) []string {
+	labels, _, _ := unstructured.NestedStringMap(obj.Object, strings.Split(strings.TrimPrefix(path, "/"), "/")...)
+	var ops []string
+	for _, key := range helperLabels[helper] {
+		if _, ok := labels[key]; ok {
+			ops = append(ops, fmt.Sprintf(`{"op": "remove", "path": "%s/%s"}`, path, jsonPointerEscaper.Replace(key)))
+		}
+	}
+	return append(ops, includeOp(path, helper))
+}
+
+// jsonPointerEscaper escapes keys to be used as JSON pointer segments (RFC 6901).
+var jsonPointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// includeOp returns the operation adding the include marker for helper to the map at path.
+func includeOp(path string, helper string) string {
+	return fmt.Sprintf(`{"op": "add", "path": "%s/helm-dump~1include", "value": %q}`, path, helper)
+}
+
+var includeMarkerRegexp = regexp.MustCompile(`(?m)^( *)` + regexp.QuoteMeta(includeMarker) + `: (\w+)$`)
+
+// RenderIncludes replaces the include markers in a serialized resource with the actions including the chart's
+// helpers, for example "{{- include "my-chart.labels" . | nindent 4 }}".
+func RenderIncludes(data []byte, chartName string) []byte {
+	return includeMarkerRegexp.ReplaceAllFunc(data, func(match []byte) []byte {
+		groups := includeMarkerRegexp.FindSubmatch(match)
+		indent, helper := groups[1], groups[2]
+		return []byte(fmt.Sprintf(`%s{{- include "%s.%s" . | nindent %d }}`, indent, chartName, helper, len(indent)))
+	})
+}
@@ -0,0 +1,29 @@
+package helmdumpinit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderIncludes(t *testing.T) {
+	data := []byte(`metadata:
+  labels:
+    app: nginx
+    helm-dump/include: labels
+spec:
+  selector:
+    matchLabels:
+      helm-dump/include: selectorLabels
+`)
+	expected := `metadata:
+  labels:
+    app: nginx
+    {{- include "my-app.labels" . | nindent 4 }}
+spec:
+  selector:
+    matchLabels:
+      {{- include "my-app.selectorLabels" . | nindent 6 }}
+`
+	require.Equal(t, expected, string(RenderIncludes(data, "my-app")))
+}
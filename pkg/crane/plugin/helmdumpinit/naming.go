@@ -0,0 +1,109 @@
+package helmdumpinit
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+)
+
+// NamingStrategy determines how names of resources are templated in the generated chart.
+type NamingStrategy string
+
+const (
+	// ReleaseSuffix appends the release name to the original name, for example "nginx-{{ .Release.Name }}".
+	ReleaseSuffix NamingStrategy = "release-suffix"
+	// ReleasePrefix prepends the release name to the original name, for example "{{ .Release.Name }}-nginx".
+	ReleasePrefix NamingStrategy = "release-prefix"
+	// Fullname prepends the chart's fullname helper to the original name, as charts created by "helm create" do.
+	Fullname NamingStrategy = "fullname"
+	// NameOverride prepends the chart's nameOverride value, defaulting to the release name, to the original name.
+	NameOverride NamingStrategy = "name-override"
+	// Unchanged keeps the original name.
+	Unchanged NamingStrategy = "unchanged"
+)
+
+// DefaultNamingStrategy is the strategy used when none is informed.
+const DefaultNamingStrategy = ReleaseSuffix
+
+// NamingStrategies are all known naming strategies.
+var NamingStrategies = []NamingStrategy{ReleaseSuffix, ReleasePrefix, Fullname, NameOverride, Unchanged}
+
+// ParseNamingStrategy returns the naming strategy for s, or DefaultNamingStrategy if s is empty.
+func ParseNamingStrategy(s string) (NamingStrategy, error) {
+	if s == "" {
+		return DefaultNamingStrategy, nil
+	}
+	for _, strategy := range NamingStrategies {
+		if string(strategy) == s {
+			return strategy, nil
+		}
+	}
+	return "", fmt.Errorf("unknown naming strategy %q; available strategies are %v", s, NamingStrategies)
+}
+
+const (
+	// dnsLabelMaxLength is the maximum length of names which must be valid DNS labels (RFC 1123 and RFC 1035).
+	dnsLabelMaxLength = 63
+	// dnsSubdomainMaxLength is the maximum length of names which must be valid DNS subdomains (RFC 1123).
+	dnsSubdomainMaxLength = 253
+	// minDynamicLength is the minimum length reserved for the templated part of a name.
+	minDynamicLength = 16
+)
+
+// kindMaxNameLength are kinds whose names are limited to less than a DNS subdomain, either by validation or because
+// the name is used as a label value or to generate names of other resources.
+var kindMaxNameLength = map[string]int{
+	"Service":     dnsLabelMaxLength,
+	"Deployment":  dnsLabelMaxLength,
+	"StatefulSet": dnsLabelMaxLength,
+	"DaemonSet":   dnsLabelMaxLength,
+	"ReplicaSet":  dnsLabelMaxLength,
+	"Job":         dnsLabelMaxLength,
+	// the CronJob controller appends an 11 characters suffix to created jobs.
+	"CronJob": 52,
+	"Pod":     dnsLabelMaxLength,
+}
+
+// MaxNameLength returns the maximum length of names of the given kind.
+func MaxNameLength(kind string) int {
+	if l, ok := kindMaxNameLength[kind]; ok {
+		return l
+	}
+	return dnsSubdomainMaxLength
+}
+
+// nameHashLength is the length of the hash of the original name appended to truncated names.
+const nameHashLength = 8
+
+// truncateName returns name truncated to maxLength; a hash of name replaces its end so names sharing a long prefix
+// are still told apart.
+func truncateName(name string, maxLength int) string {
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(name)))[:nameHashLength]
+	return strings.TrimSuffix(name[:maxLength-nameHashLength-1], "-") + "-" + hash
+}
+
+// TemplateName returns the templated name for a resource of the given kind; the templated part of the name is
+// truncated at render time so the resulting name doesn't exceed the kind's maximum length, and names too long to leave
+// room for it are truncated with a hash suffix.
+func (s NamingStrategy) TemplateName(chartName string, kind string, name string) string {
+	if s == Unchanged {
+		return name
+	}
+
+	maxLength := MaxNameLength(kind)
+	if staticLength := maxLength - minDynamicLength - 1; len(name) > staticLength {
+		name = truncateName(name, staticLength)
+	}
+	dynamicLength := maxLength - len(name) - 1
+
+	switch s {
+	case ReleasePrefix:
+		return fmt.Sprintf(`{{ .Release.Name | trunc %d | trimSuffix "-" }}-%s`, dynamicLength, name)
+	case Fullname:
+		return fmt.Sprintf(`{{ include "%s.fullname" $ | trunc %d | trimSuffix "-" }}-%s`, chartName, dynamicLength, name)
+	case NameOverride:
+		return fmt.Sprintf(`{{ default .Release.Name .Values.nameOverride | trunc %d | trimSuffix "-" }}-%s`, dynamicLength, name)
+	default:
+		return fmt.Sprintf(`%s-{{ .Release.Name | trunc %d | trimSuffix "-" }}`, name, dynamicLength)
+	}
+}
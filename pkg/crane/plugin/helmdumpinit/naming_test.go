@@ -0,0 +1,112 @@
+package helmdumpinit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamingStrategy_TemplateName(t *testing.T) {
+	longName := strings.Repeat("a", 60)
+
+	tests := []struct {
+		name     string
+		strategy NamingStrategy
+		kind     string
+		resource string
+		expected string
+	}{
+		{
+			name:     "release-suffix",
+			strategy: ReleaseSuffix,
+			kind:     "Deployment",
+			resource: "nginx",
+			expected: `nginx-{{ .Release.Name | trunc 57 | trimSuffix "-" }}`,
+		},
+		{
+			name:     "release-prefix",
+			strategy: ReleasePrefix,
+			kind:     "Deployment",
+			resource: "nginx",
+			expected: `{{ .Release.Name | trunc 57 | trimSuffix "-" }}-nginx`,
+		},
+		{
+			name:     "fullname",
+			strategy: Fullname,
+			kind:     "Deployment",
+			resource: "nginx",
+			expected: `{{ include "my-app.fullname" $ | trunc 57 | trimSuffix "-" }}-nginx`,
+		},
+		{
+			name:     "name-override",
+			strategy: NameOverride,
+			kind:     "Deployment",
+			resource: "nginx",
+			expected: `{{ default .Release.Name .Values.nameOverride | trunc 57 | trimSuffix "-" }}-nginx`,
+		},
+		{
+			name:     "unchanged",
+			strategy: Unchanged,
+			kind:     "Deployment",
+			resource: longName,
+			expected: longName,
+		},
+		{
+			name:     "subdomain-kind",
+			strategy: ReleaseSuffix,
+			kind:     "ConfigMap",
+			resource: "nginx",
+			expected: `nginx-{{ .Release.Name | trunc 247 | trimSuffix "-" }}`,
+		},
+		{
+			name:     "cronjob",
+			strategy: ReleaseSuffix,
+			kind:     "CronJob",
+			resource: "backup",
+			expected: `backup-{{ .Release.Name | trunc 45 | trimSuffix "-" }}`,
+		},
+		{
+			name:     "long-name",
+			strategy: ReleaseSuffix,
+			kind:     "Service",
+			resource: longName,
+			expected: strings.Repeat("a", 37) + `-11ee3912-{{ .Release.Name | trunc 16 | trimSuffix "-" }}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, tt.strategy.TemplateName("my-app", tt.kind, tt.resource))
+		})
+	}
+
+	t.Run("long-names-with-common-prefix", func(t *testing.T) {
+		// Act
+		first := ReleaseSuffix.TemplateName("my-app", "Service", longName+"-first")
+		second := ReleaseSuffix.TemplateName("my-app", "Service", longName+"-second")
+
+		// Assert
+		require.NotEqual(t, first, second)
+		require.Equal(t, len(first), len(second))
+	})
+}
+
+func TestParseNamingStrategy(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		actual, err := ParseNamingStrategy("")
+		require.NoError(t, err)
+		require.Equal(t, DefaultNamingStrategy, actual)
+	})
+
+	t.Run("known", func(t *testing.T) {
+		actual, err := ParseNamingStrategy("fullname")
+		require.NoError(t, err)
+		require.Equal(t, Fullname, actual)
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := ParseNamingStrategy("unknown")
+		require.Error(t, err)
+	})
+}
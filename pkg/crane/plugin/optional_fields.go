@@ -0,0 +1,57 @@
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/konveyor/crane-lib/transform"
+)
+
+// ParseOptionalFlags parses a list of key=value pairs into a map; values may contain both '=' and ',' so plugins
+// accepting lists or maps receive them unchanged.
+func ParseOptionalFlags(pairs []string) (map[string]string, error) {
+	flags := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid plugin flag %q; expected key=value", pair)
+		}
+		flags[kv[0]] = kv[1]
+	}
+	return flags, nil
+}
+
+// OptionalFieldNames returns the sorted names of all optional fields declared by the given plugins.
+func OptionalFieldNames(plugins []transform.Plugin) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, p := range plugins {
+		for _, field := range p.Metadata().OptionalFields {
+			if seen[field.FlagName] {
+				continue
+			}
+			seen[field.FlagName] = true
+			names = append(names, field.FlagName)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidateOptionalFlags returns an error if any of the given flags isn't declared as an optional field by at least
+// one of the plugins.
+func ValidateOptionalFlags(plugins []transform.Plugin, flags map[string]string) error {
+	known := OptionalFieldNames(plugins)
+	var unknown []string
+	for key := range flags {
+		if i := sort.SearchStrings(known, key); i == len(known) || known[i] != key {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown plugin flags %v; flags accepted by the loaded plugins are %v", unknown, known)
+	}
+	return nil
+}
@@ -1,9 +1,17 @@
 package plugin
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch"
 
 	"github.com/konveyor/crane-lib/transform"
 	binary_plugin "github.com/konveyor/crane-lib/transform/binary-plugin"
@@ -58,20 +66,24 @@ func IsExecAny(mode os.FileMode) bool {
 }
 
 func GetFilteredPlugins(pluginDir string, skipPlugins []string, logger *logrus.Logger) ([]transform.Plugin, error) {
-	var filteredPlugins []transform.Plugin
 	plugins, err := GetPlugins(pluginDir, logger)
 	if err != nil {
-		return filteredPlugins, err
+		return nil, err
 	}
+	return filterPlugins(plugins, skipPlugins), nil
+}
+
+func filterPlugins(plugins []transform.Plugin, skipPlugins []string) []transform.Plugin {
 	if len(skipPlugins) == 0 {
-		return plugins, nil
+		return plugins
 	}
+	var filteredPlugins []transform.Plugin
 	for _, thisPlugin := range plugins {
 		if !isPluginInList(thisPlugin, skipPlugins) {
 			filteredPlugins = append(filteredPlugins, thisPlugin)
 		}
 	}
-	return filteredPlugins, nil
+	return filteredPlugins
 }
 
 func isPluginInList(plugin transform.Plugin, list []string) bool {
@@ -83,3 +95,80 @@ func isPluginInList(plugin transform.Plugin, list []string) bool {
 	}
 	return false
 }
+
+// SortTransformFile sorts the operations of a transform file produced by transform.Runner by path. The runner
+// deduplicates operations using a map, so their order is random and an operation adding an empty object could run
+// after the operations adding its fields; sorting places parents before their children and keeps output stable.
+// Array indexes are compared as numbers, and the elements removed from the same array are removed from the last one
+// so the indexes of the following operations still refer to the elements they were computed for.
+func SortTransformFile(transformFile []byte) ([]byte, error) {
+	patch, err := jsonpatch.DecodePatch(transformFile)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([][]string, len(patch))
+	for i, op := range patch {
+		path, err := op.Path()
+		if err != nil {
+			return nil, err
+		}
+		paths[i] = strings.Split(path, "/")
+	}
+
+	indexes := make([]int, len(patch))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return lessPath(paths[indexes[i]], patch[indexes[i]].Kind() == "remove", paths[indexes[j]], patch[indexes[j]].Kind() == "remove")
+	})
+
+	sorted := make(jsonpatch.Patch, 0, len(patch))
+	for _, i := range indexes {
+		sorted = append(sorted, patch[i])
+	}
+
+	return json.Marshal(sorted)
+}
+
+// lessPath returns whether the operation on the JSON pointer segments a runs before the one on b; removeA and removeB
+// are whether they are remove operations. Parents run before their children and the elements of an array in index
+// order, except for the removals of elements, which run after the other operations on the array from the last one.
+func lessPath(a []string, removeA bool, b []string, removeB bool) bool {
+	for k := 0; k < len(a) && k < len(b); k++ {
+		if a[k] == b[k] {
+			continue
+		}
+		i, errA := arrayIndex(a[k])
+		j, errB := arrayIndex(b[k])
+		if errA != nil || errB != nil {
+			return a[k] < b[k]
+		}
+		removalA, removalB := removeA && k == len(a)-1, removeB && k == len(b)-1
+		switch {
+		case removalA != removalB:
+			return removalB
+		case removalA:
+			return i > j
+		default:
+			return i < j
+		}
+	}
+	return len(a) < len(b)
+}
+
+// arrayIndexRegexp matches the JSON pointer segments referring to array elements.
+var arrayIndexRegexp = regexp.MustCompile(`^(0|[1-9][0-9]*)$`)
+
+// arrayIndex returns the index of the array element the JSON pointer segment refers to; "-", the element past the
+// end of the array, is after every other one.
+func arrayIndex(segment string) (int, error) {
+	if segment == "-" {
+		return math.MaxInt32, nil
+	}
+	if !arrayIndexRegexp.MatchString(segment) {
+		return 0, fmt.Errorf("%q isn't an array index", segment)
+	}
+	return strconv.Atoi(segment)
+}
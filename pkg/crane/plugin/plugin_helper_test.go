@@ -0,0 +1,90 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortTransformFile(t *testing.T) {
+	tests := []struct {
+		name          string
+		transformFile string
+		expected      string
+	}{
+		{
+			name: "parents-first",
+			transformFile: `[
+				{"op": "add", "path": "/metadata/labels/app", "value": "nginx"},
+				{"op": "add", "path": "/metadata/labels", "value": {}}
+			]`,
+			expected: `[
+				{"op": "add", "path": "/metadata/labels", "value": {}},
+				{"op": "add", "path": "/metadata/labels/app", "value": "nginx"}
+			]`,
+		},
+		{
+			name: "numeric-indexes",
+			transformFile: `[
+				{"op": "replace", "path": "/spec/ports/10/port", "value": 10},
+				{"op": "replace", "path": "/spec/ports/2/port", "value": 2},
+				{"op": "add", "path": "/spec/ports/-", "value": {}}
+			]`,
+			expected: `[
+				{"op": "replace", "path": "/spec/ports/2/port", "value": 2},
+				{"op": "replace", "path": "/spec/ports/10/port", "value": 10},
+				{"op": "add", "path": "/spec/ports/-", "value": {}}
+			]`,
+		},
+		{
+			name: "removals-from-last",
+			transformFile: `[
+				{"op": "remove", "path": "/spec/ports/2"},
+				{"op": "remove", "path": "/spec/ports/10"},
+				{"op": "remove", "path": "/spec/ports/0/name"}
+			]`,
+			expected: `[
+				{"op": "remove", "path": "/spec/ports/0/name"},
+				{"op": "remove", "path": "/spec/ports/10"},
+				{"op": "remove", "path": "/spec/ports/2"}
+			]`,
+		},
+		{
+			name: "removals-after-other-operations",
+			transformFile: `[
+				{"op": "remove", "path": "/spec/ports/1"},
+				{"op": "replace", "path": "/spec/ports/3/port", "value": 3},
+				{"op": "remove", "path": "/spec/ports/2"},
+				{"op": "add", "path": "/spec/ports/-", "value": {}}
+			]`,
+			expected: `[
+				{"op": "replace", "path": "/spec/ports/3/port", "value": 3},
+				{"op": "add", "path": "/spec/ports/-", "value": {}},
+				{"op": "remove", "path": "/spec/ports/2"},
+				{"op": "remove", "path": "/spec/ports/1"}
+			]`,
+		},
+		{
+			name: "non-index-segments",
+			transformFile: `[
+				{"op": "add", "path": "/data/10", "value": "b"},
+				{"op": "add", "path": "/data/01", "value": "a"}
+			]`,
+			expected: `[
+				{"op": "add", "path": "/data/01", "value": "a"},
+				{"op": "add", "path": "/data/10", "value": "b"}
+			]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			actual, err := SortTransformFile([]byte(tt.transformFile))
+
+			// Assert
+			require.NoError(t, err)
+			require.JSONEq(t, tt.expected, string(actual))
+		})
+	}
+}
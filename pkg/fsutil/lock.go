@@ -0,0 +1,116 @@
+package fsutil
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LockFileName is the name of the file holding the pid of the process which locked a directory.
+const LockFileName = "lock"
+
+// lockRetryInterval is how often a locked directory is checked while waiting for it to be unlocked.
+const lockRetryInterval = 100 * time.Millisecond
+
+// ErrLocked is returned when a directory is still locked by another process once the timeout expires.
+var ErrLocked = errors.New("locked")
+
+// LockedError reports the process holding the lock of a directory.
+type LockedError struct {
+	Dir string
+	Pid int
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("project is locked by pid %d; wait for it to finish", e.Pid)
+}
+
+func (e *LockedError) Is(target error) bool {
+	return target == ErrLocked
+}
+
+// Lock is an advisory lock on a directory, held on the file named LockFileName in it. The operating system releases
+// it when the process holding it exits, so locks are never left behind; the file itself is kept, as removing it would
+// let another process lock a new file while a waiting one locks the removed one.
+type Lock struct {
+	file *os.File
+}
+
+// LockDir locks dir, creating it if needed, waiting up to timeout for the process holding the lock to release it.
+func LockDir(dir string, timeout time.Duration) (*Lock, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("error creating %s: %w", dir, err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, LockFileName), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error locking %s: %w", dir, err)
+	}
+	l := &Lock{file: f}
+	deadline := time.Now().Add(timeout)
+	for {
+		locked, err := tryLockFile(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("error locking %s: %w", dir, err)
+		}
+		if locked {
+			break
+		}
+		if !time.Now().Before(deadline) {
+			pid, _ := l.holder()
+			_ = f.Close()
+			return nil, &LockedError{Dir: dir, Pid: pid}
+		}
+		time.Sleep(lockRetryInterval)
+	}
+
+	// the pid is informational, reported to the processes waiting for the lock.
+	if err := l.writeHolder(strconv.Itoa(os.Getpid())); err != nil {
+		_ = l.Unlock()
+		return nil, fmt.Errorf("error locking %s: %w", dir, err)
+	}
+	return l, nil
+}
+
+func (l *Lock) writeHolder(pid string) error {
+	if err := l.file.Truncate(0); err != nil {
+		return err
+	}
+	_, err := l.file.WriteAt([]byte(pid), 0)
+	return err
+}
+
+// holder returns the pid recorded in the lock file; it's not available while the lock is being taken.
+func (l *Lock) holder() (int, bool) {
+	data, err := ioutil.ReadFile(l.file.Name())
+	if err != nil {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, false
+	}
+	return pid, true
+}
+
+// Unlock releases the lock.
+func (l *Lock) Unlock() error {
+	dir := filepath.Dir(l.file.Name())
+	err := l.writeHolder("")
+	if unlockErr := unlockFile(l.file); err == nil {
+		err = unlockErr
+	}
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error unlocking %s: %w", dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/redhat-developer/helm-dump/pkg/test"
+)
+
+func TestLockDir(t *testing.T) {
+	t.Run("locked", func(t *testing.T) {
+		// Arrange
+		dir := filepath.Join(test.TempDir(t), ".helm-dump")
+		lock, err := LockDir(dir, 0)
+		require.NoError(t, err)
+
+		// Act
+		_, err = LockDir(dir, 200*time.Millisecond)
+
+		// Assert
+		require.ErrorIs(t, err, ErrLocked)
+		require.Equal(t, &LockedError{Dir: dir, Pid: os.Getpid()}, err)
+		require.NoError(t, lock.Unlock())
+		lock, err = LockDir(dir, 0)
+		require.NoError(t, err, "the directory should be lockable once unlocked")
+		require.NoError(t, lock.Unlock())
+	})
+
+	t.Run("wait-for-unlock", func(t *testing.T) {
+		// Arrange
+		dir := test.TempDir(t)
+		lock, err := LockDir(dir, 0)
+		require.NoError(t, err)
+		time.AfterFunc(200*time.Millisecond, func() { _ = lock.Unlock() })
+
+		// Act
+		waited, err := LockDir(dir, 10*time.Second)
+
+		// Assert
+		require.NoError(t, err)
+		require.NoError(t, waited.Unlock())
+	})
+
+	t.Run("stale-lock", func(t *testing.T) {
+		// Arrange
+		dir := test.TempDir(t)
+		// pids are far below the maximum int32 on every supported platform.
+		stale := strconv.Itoa(1<<31 - 1)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, LockFileName), []byte(stale), 0644))
+
+		// Act
+		lock, err := LockDir(dir, 0)
+
+		// Assert
+		require.NoError(t, err, "lock files left behind by processes no longer running shouldn't lock the directory")
+		data, err := os.ReadFile(filepath.Join(dir, LockFileName))
+		require.NoError(t, err)
+		require.Equal(t, strconv.Itoa(os.Getpid()), string(data))
+		require.NoError(t, lock.Unlock())
+	})
+
+	t.Run("mutual-exclusion", func(t *testing.T) {
+		// Arrange
+		dir := test.TempDir(t)
+		const waiters = 8
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		holders, maxHolders := 0, 0
+		errs := make(chan error, 2*waiters)
+
+		// Act
+		for i := 0; i < waiters; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				lock, err := LockDir(dir, 10*time.Second)
+				if err != nil {
+					errs <- err
+					return
+				}
+				mu.Lock()
+				holders++
+				if holders > maxHolders {
+					maxHolders = holders
+				}
+				mu.Unlock()
+				time.Sleep(10 * time.Millisecond)
+				mu.Lock()
+				holders--
+				mu.Unlock()
+				if err := lock.Unlock(); err != nil {
+					errs <- err
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		// Assert
+		for err := range errs {
+			require.NoError(t, err)
+		}
+		require.Equal(t, 1, maxHolders, "the lock should be held by one holder at a time")
+	})
+}
+
+func TestWriteFile(t *testing.T) {
+	// Arrange
+	dir := test.TempDir(t)
+	name := filepath.Join(dir, "values.yaml")
+	require.NoError(t, os.WriteFile(name, []byte("replicas: 1\n"), 0644))
+
+	// Act
+	err := WriteFile(name, []byte("replicas: 3\n"), 0644)
+
+	// Assert
+	require.NoError(t, err)
+	data, err := os.ReadFile(name)
+	require.NoError(t, err)
+	require.Equal(t, "replicas: 3\n", string(data))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "no temporary files should be left behind")
+}
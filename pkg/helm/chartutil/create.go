@@ -6,6 +6,23 @@ import (
 	"strings"
 )
 
+// DefaultHelmignore returns the .helmignore file of charts created by helm-dump, which ignores helm-dump's cache
+// besides the patterns ignored by charts created by Helm.
+func DefaultHelmignore() *chart.File {
+	return &chart.File{
+		Name: chartutil.IgnorefileName,
+		Data: []byte(defaultIgnore),
+	}
+}
+
+// DefaultValues returns the values required by the default helpers.
+func DefaultValues() map[string]interface{} {
+	return map[string]interface{}{
+		"nameOverride":     "",
+		"fullnameOverride": "",
+	}
+}
+
 func DefaultHelpers(name string) *chart.File {
 	return &chart.File{
 		Name: chartutil.HelpersName,
@@ -76,3 +93,30 @@ Create the name of the service account to use
 {{- end }}
 {{- end }}
 `
+
+const defaultIgnore = `# Patterns to ignore when building packages.
+# This supports shell glob matching, relative path matching, and
+# negation (prefixed with !). Only one pattern per line.
+.DS_Store
+# helm-dump cache
+.helm-dump/
+# Common VCS dirs
+.git/
+.gitignore
+.bzr/
+.bzrignore
+.hg/
+.hgignore
+.svn/
+# Common backup files
+*.swp
+*.bak
+*.tmp
+*.orig
+*~
+# Various IDEs
+.project
+.idea/
+*.tmproj
+.vscode/
+`
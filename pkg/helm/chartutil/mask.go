@@ -0,0 +1,37 @@
+package chartutil
+
+import (
+	"regexp"
+	"strings"
+)
+
+// standaloneActionRegexp matches template actions taking a whole line, such as the ones including helpers.
+var standaloneActionRegexp = regexp.MustCompile(`(?m)^ *\{\{.*\}\} *$`)
+
+// MaskStandaloneActions returns a copy of data where template actions taking a whole line are turned into YAML
+// comments of the same length, so templates can be parsed as YAML without changing the offsets of their nodes.
+func MaskStandaloneActions(data []byte) []byte {
+	return standaloneActionRegexp.ReplaceAllFunc(data, func(line []byte) []byte {
+		masked := make([]byte, len(line))
+		copy(masked, line)
+		for i := range masked {
+			if masked[i] == '{' {
+				masked[i] = '#'
+				break
+			}
+		}
+		return masked
+	})
+}
+
+// actionRegexp matches template actions within a line.
+var actionRegexp = regexp.MustCompile(`\{\{.*?\}\}`)
+
+// MaskActions returns a copy of data where template actions taking a whole line are masked as by
+// MaskStandaloneActions, and the ones within a line, such as references to values, are turned into plain strings of
+// the same length, so templates whose values were already moved can also be parsed as YAML.
+func MaskActions(data []byte) []byte {
+	return actionRegexp.ReplaceAllFunc(MaskStandaloneActions(data), func(action []byte) []byte {
+		return []byte(strings.Repeat("x", len(action)))
+	})
+}
@@ -0,0 +1,43 @@
+package chartutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaskStandaloneActions(t *testing.T) {
+	data := []byte(`metadata:
+  name: nginx-{{ .Release.Name }}
+  labels:
+    {{- include "my-app.labels" . | nindent 4 }}
+`)
+	expected := `metadata:
+  name: nginx-{{ .Release.Name }}
+  labels:
+    #{- include "my-app.labels" . | nindent 4 }}
+`
+	actual := MaskStandaloneActions(data)
+	require.Equal(t, expected, string(actual))
+	require.Len(t, actual, len(data))
+}
+
+func TestMaskActions(t *testing.T) {
+	data := []byte(`metadata:
+  name: nginx-{{ .Release.Name }}
+  labels:
+    {{- include "my-app.labels" . | nindent 4 }}
+spec:
+  replicas: {{ .Values.nginx.replicas }}
+`)
+	expected := `metadata:
+  name: nginx-xxxxxxxxxxxxxxxxxxx
+  labels:
+    #{- include "my-app.labels" . | nindent 4 }}
+spec:
+  replicas: xxxxxxxxxxxxxxxxxxxxxxxxxxxx
+`
+	actual := MaskActions(data)
+	require.Equal(t, expected, string(actual))
+	require.Len(t, actual, len(data))
+}
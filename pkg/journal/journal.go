@@ -0,0 +1,358 @@
+package journal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/redhat-developer/helm-dump/pkg/fsutil"
+)
+
+const (
+	// FormatVersion is the version of the journal's layout; journals written by later versions aren't read.
+	FormatVersion = 1
+	// FileName is the name of the file holding the journal's entries.
+	FileName = "journal.json"
+	// ObjectsDir is the directory holding the contents of files before and after each operation, named by their
+	// hashes.
+	ObjectsDir = "objects"
+
+	// InitCommand is the command recorded when a chart is generated.
+	InitCommand = "init"
+)
+
+// ErrModified is returned when undoing an operation whose files were modified after the operation was recorded.
+var ErrModified = errors.New("files were modified since the operation")
+
+// Change records the hashes of a file's contents before and after an operation; an empty hash means the file didn't
+// exist. Name is relative to the chart's directory, or absolute for files outside of it, such as the ones of a cache
+// kept elsewhere.
+type Change struct {
+	Name   string `json:"name"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// Entry is an operation applied to a chart.
+type Entry struct {
+	ID      int       `json:"id"`
+	Time    time.Time `json:"time"`
+	Command string    `json:"command"`
+	Args    []string  `json:"args"`
+	Changes []Change  `json:"changes"`
+}
+
+type file struct {
+	Version int      `json:"version"`
+	Entries []*Entry `json:"entries"`
+}
+
+// Journal records the operations applied to the chart in ChartDir, along with the contents of the files they
+// changed, so operations can be listed, undone and replayed.
+type Journal struct {
+	ChartDir string
+	RootDir  string
+}
+
+func hashOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func (j *Journal) path() string {
+	return filepath.Join(j.RootDir, FileName)
+}
+
+// filePath returns the path of the file a change named name was recorded for.
+func (j *Journal) filePath(name string) string {
+	if p := filepath.FromSlash(name); filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(j.ChartDir, name)
+}
+
+func (j *Journal) objectPath(hash string) string {
+	return filepath.Join(j.RootDir, ObjectsDir, hash[len("sha256:"):])
+}
+
+func (j *Journal) read() (*file, error) {
+	data, err := ioutil.ReadFile(j.path())
+	if errors.Is(err, os.ErrNotExist) {
+		return &file{Version: FormatVersion}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading journal: %w", err)
+	}
+	f := &file{}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("journal %s is corrupted: %w", j.path(), err)
+	}
+	if f.Version > FormatVersion {
+		return nil, fmt.Errorf("unsupported journal format version %d in %s; this version of helm-dump supports up to %d", f.Version, j.path(), FormatVersion)
+	}
+	return f, nil
+}
+
+func (j *Journal) write(f *file) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling journal: %w", err)
+	}
+	if err := fsutil.WriteFile(j.path(), data, 0644); err != nil {
+		return fmt.Errorf("error writing journal: %w", err)
+	}
+	return nil
+}
+
+// Entries returns the recorded operations, oldest first.
+func (j *Journal) Entries() ([]*Entry, error) {
+	f, err := j.read()
+	if err != nil {
+		return nil, err
+	}
+	return f.Entries, nil
+}
+
+func (j *Journal) storeObject(data []byte) (string, error) {
+	hash := hashOf(data)
+	if _, err := os.Stat(j.objectPath(hash)); err == nil {
+		return hash, nil
+	}
+	if err := fsutil.WriteFile(j.objectPath(hash), data, 0644); err != nil {
+		return "", fmt.Errorf("error writing journal object: %w", err)
+	}
+	return hash, nil
+}
+
+// Record appends the operation command, invoked with args, which changed the chart's files from before to after;
+// both are snapshots taken with Snapshot. Nothing is recorded if no file changed.
+func (j *Journal) Record(command string, args []string, before, after map[string][]byte) (*Entry, error) {
+	f, err := j.read()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Join(j.RootDir, ObjectsDir), os.ModePerm); err != nil {
+		return nil, fmt.Errorf("error creating journal dir: %w", err)
+	}
+
+	names := make(map[string]bool)
+	for name := range before {
+		names[name] = true
+	}
+	for name := range after {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var changes []Change
+	for _, name := range sorted {
+		beforeData, hadBefore := before[name]
+		afterData, hasAfter := after[name]
+		if hadBefore && hasAfter && hashOf(beforeData) == hashOf(afterData) {
+			continue
+		}
+		change := Change{Name: name}
+		if hadBefore {
+			if change.Before, err = j.storeObject(beforeData); err != nil {
+				return nil, err
+			}
+		}
+		if hasAfter {
+			if change.After, err = j.storeObject(afterData); err != nil {
+				return nil, err
+			}
+		}
+		changes = append(changes, change)
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	id := 1
+	if len(f.Entries) > 0 {
+		id = f.Entries[len(f.Entries)-1].ID + 1
+	}
+	entry := &Entry{
+		ID:      id,
+		Time:    time.Now().UTC().Truncate(time.Second),
+		Command: command,
+		Args:    args,
+		Changes: changes,
+	}
+	f.Entries = append(f.Entries, entry)
+	if err := j.write(f); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Undo reverts the last recorded operation, restoring the files it changed, and removes it from the journal; unless
+// force is set, ErrModified is returned if any of the files changed since.
+func (j *Journal) Undo(force bool) (*Entry, error) {
+	f, err := j.read()
+	if err != nil {
+		return nil, err
+	}
+	if len(f.Entries) == 0 {
+		return nil, fmt.Errorf("no operations to undo")
+	}
+	entry := f.Entries[len(f.Entries)-1]
+
+	if !force {
+		for _, change := range entry.Changes {
+			current, err := ioutil.ReadFile(j.filePath(change.Name))
+			currentHash := ""
+			if err == nil {
+				currentHash = hashOf(current)
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("error reading %s: %w", change.Name, err)
+			}
+			if currentHash != change.After {
+				return nil, fmt.Errorf("%w %s #%d: %s; use --force to undo it anyway", ErrModified, entry.Command, entry.ID, change.Name)
+			}
+		}
+	}
+
+	for _, change := range entry.Changes {
+		p := j.filePath(change.Name)
+		if change.Before == "" {
+			if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("error removing %s: %w", change.Name, err)
+			}
+			continue
+		}
+		data, err := ioutil.ReadFile(j.objectPath(change.Before))
+		if err != nil {
+			return nil, fmt.Errorf("error reading the contents of %s before %s #%d: %w", change.Name, entry.Command, entry.ID, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+			return nil, fmt.Errorf("error restoring %s: %w", change.Name, err)
+		}
+		if err := fsutil.WriteFile(p, data, 0644); err != nil {
+			return nil, fmt.Errorf("error restoring %s: %w", change.Name, err)
+		}
+	}
+
+	f.Entries = f.Entries[:len(f.Entries)-1]
+	if err := j.write(f); err != nil {
+		return nil, err
+	}
+	return entry, j.collectGarbage(f)
+}
+
+// collectGarbage removes the objects no longer referenced by any entry.
+func (j *Journal) collectGarbage(f *file) error {
+	referenced := make(map[string]bool)
+	for _, entry := range f.Entries {
+		for _, change := range entry.Changes {
+			referenced[change.Before] = true
+			referenced[change.After] = true
+		}
+	}
+	objects, err := ioutil.ReadDir(filepath.Join(j.RootDir, ObjectsDir))
+	if err != nil {
+		return fmt.Errorf("error reading journal objects: %w", err)
+	}
+	for _, object := range objects {
+		if referenced["sha256:"+object.Name()] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(j.RootDir, ObjectsDir, object.Name())); err != nil {
+			return fmt.Errorf("error removing journal object: %w", err)
+		}
+	}
+	return nil
+}
+
+// PreviousGeneration returns the operations applied to the chart generated by the init before the last one, which
+// are the ones to replay onto the chart generated by the last init.
+func PreviousGeneration(entries []*Entry) []*Entry {
+	var inits []int
+	for i, entry := range entries {
+		if entry.Command == InitCommand {
+			inits = append(inits, i)
+		}
+	}
+	switch len(inits) {
+	case 0:
+		return nil
+	case 1:
+		return withoutInits(entries[:inits[0]])
+	default:
+		return withoutInits(entries[inits[len(inits)-2]+1 : inits[len(inits)-1]])
+	}
+}
+
+// LastGeneration returns the operations applied to the chart generated by the last init.
+func LastGeneration(entries []*Entry) []*Entry {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Command == InitCommand {
+			return entries[i+1:]
+		}
+	}
+	return entries
+}
+
+func withoutInits(entries []*Entry) []*Entry {
+	var out []*Entry
+	for _, entry := range entries {
+		if entry.Command != InitCommand {
+			out = append(out, entry)
+		}
+	}
+	return out
+}
+
+// Snapshot returns the contents of the files in dir, indexed by their slash-separated paths relative to dir; the
+// files and directories named in skip, relative to dir, aren't read.
+func Snapshot(dir string, skip ...string) (map[string][]byte, error) {
+	skipped := make(map[string]bool, len(skip))
+	for _, s := range skip {
+		skipped[filepath.ToSlash(s)] = true
+	}
+	files := make(map[string][]byte)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if errors.Is(err, os.ErrNotExist) && p == dir {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if skipped[rel] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if skipped[rel] {
+			return nil
+		}
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files[rel] = data
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", dir, err)
+	}
+	return files, nil
+}
@@ -0,0 +1,171 @@
+package journal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/redhat-developer/helm-dump/pkg/test"
+)
+
+func newJournal(t *testing.T) *Journal {
+	chartDir := test.TempDir(t)
+	return &Journal{ChartDir: chartDir, RootDir: filepath.Join(chartDir, ".helm-dump", "journal")}
+}
+
+func writeFile(t *testing.T, dir, name, data string) {
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0644))
+}
+
+func TestJournal(t *testing.T) {
+	t.Run("record", func(t *testing.T) {
+		// Arrange
+		j := newJournal(t)
+		before := map[string][]byte{"values.yaml": []byte("{}\n"), "templates/a.yaml": []byte("a")}
+		after := map[string][]byte{"values.yaml": []byte("replicas: 3\n"), "templates/a.yaml": []byte("a"), "templates/b.yaml": []byte("b")}
+
+		// Act
+		entry, err := j.Record("move-to-values", []string{"apps/v1", "Deployment"}, before, after)
+
+		// Assert
+		require.NoError(t, err)
+		require.Equal(t, 1, entry.ID)
+		require.Equal(t, []Change{
+			{Name: "templates/b.yaml", After: hashOf([]byte("b"))},
+			{Name: "values.yaml", Before: hashOf([]byte("{}\n")), After: hashOf([]byte("replicas: 3\n"))},
+		}, entry.Changes)
+		entries, err := (&Journal{ChartDir: j.ChartDir, RootDir: j.RootDir}).Entries()
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+	})
+
+	t.Run("record-without-changes", func(t *testing.T) {
+		// Arrange
+		j := newJournal(t)
+		files := map[string][]byte{"values.yaml": []byte("{}\n")}
+
+		// Act
+		entry, err := j.Record("move-to-values", nil, files, files)
+
+		// Assert
+		require.NoError(t, err)
+		require.Nil(t, entry)
+		entries, err := j.Entries()
+		require.NoError(t, err)
+		require.Empty(t, entries)
+	})
+
+	t.Run("undo", func(t *testing.T) {
+		// Arrange
+		j := newJournal(t)
+		writeFile(t, j.ChartDir, "values.yaml", "{}\n")
+		before, err := Snapshot(j.ChartDir, ".helm-dump")
+		require.NoError(t, err)
+		writeFile(t, j.ChartDir, "values.yaml", "replicas: 3\n")
+		writeFile(t, j.ChartDir, "templates/b.yaml", "b")
+		after, err := Snapshot(j.ChartDir, ".helm-dump")
+		require.NoError(t, err)
+		_, err = j.Record("move-to-values", nil, before, after)
+		require.NoError(t, err)
+
+		// Act
+		entry, err := j.Undo(false)
+
+		// Assert
+		require.NoError(t, err)
+		require.Equal(t, 1, entry.ID)
+		actual, err := Snapshot(j.ChartDir, ".helm-dump")
+		require.NoError(t, err)
+		require.Equal(t, before, actual)
+		entries, err := j.Entries()
+		require.NoError(t, err)
+		require.Empty(t, entries)
+		objects, err := os.ReadDir(filepath.Join(j.RootDir, ObjectsDir))
+		require.NoError(t, err)
+		require.Empty(t, objects, "objects of undone operations should be removed")
+	})
+
+	t.Run("undo-modified-files", func(t *testing.T) {
+		// Arrange
+		j := newJournal(t)
+		_, err := j.Record("move-to-values", nil,
+			map[string][]byte{"values.yaml": []byte("{}\n")},
+			map[string][]byte{"values.yaml": []byte("replicas: 3\n")})
+		require.NoError(t, err)
+		writeFile(t, j.ChartDir, "values.yaml", "replicas: 5\n")
+
+		// Act
+		_, err = j.Undo(false)
+
+		// Assert
+		require.ErrorIs(t, err, ErrModified)
+		_, err = j.Undo(true)
+		require.NoError(t, err)
+		data, err := os.ReadFile(filepath.Join(j.ChartDir, "values.yaml"))
+		require.NoError(t, err)
+		require.Equal(t, "{}\n", string(data))
+	})
+
+	t.Run("undo-files-outside-the-chart", func(t *testing.T) {
+		// Arrange
+		j := newJournal(t)
+		outside := filepath.ToSlash(filepath.Join(test.TempDir(t), "manifest.json"))
+		writeFile(t, filepath.Dir(outside), "manifest.json", "{}")
+		_, err := j.Record("move-to-template", nil,
+			map[string][]byte{outside: []byte("{}")},
+			map[string][]byte{outside: []byte(`{"version": 1}`)})
+		require.NoError(t, err)
+
+		// Act
+		_, err = j.Undo(true)
+
+		// Assert
+		require.NoError(t, err)
+		data, err := os.ReadFile(outside)
+		require.NoError(t, err)
+		require.Equal(t, "{}", string(data))
+	})
+
+	t.Run("undo-without-operations", func(t *testing.T) {
+		// Act
+		_, err := newJournal(t).Undo(false)
+
+		// Assert
+		require.Error(t, err)
+	})
+}
+
+func TestSnapshot(t *testing.T) {
+	// Arrange
+	dir := test.TempDir(t)
+	writeFile(t, dir, "values.yaml", "{}\n")
+	writeFile(t, dir, ".helm-dump/manifest.json", "{}")
+	writeFile(t, dir, ".helm-dump/lock", "1")
+	writeFile(t, dir, ".helm-dump/journal/journal.json", "{}")
+
+	// Act
+	actual, err := Snapshot(dir, ".helm-dump/journal", ".helm-dump/lock")
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, map[string][]byte{"values.yaml": []byte("{}\n"), ".helm-dump/manifest.json": []byte("{}")}, actual)
+}
+
+func TestGenerations(t *testing.T) {
+	// Arrange
+	entries := []*Entry{
+		{ID: 1, Command: InitCommand},
+		{ID: 2, Command: "move-to-values"},
+		{ID: 3, Command: "move-to-values"},
+		{ID: 4, Command: InitCommand},
+		{ID: 5, Command: "move-to-values"},
+	}
+
+	// Act & Assert
+	require.Equal(t, []*Entry{entries[1], entries[2]}, PreviousGeneration(entries))
+	require.Equal(t, []*Entry{entries[4]}, LastGeneration(entries))
+	require.Nil(t, PreviousGeneration(entries[:3]), "the first generation has no previous one")
+}
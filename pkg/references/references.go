@@ -0,0 +1,246 @@
+package references
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// fieldRef describes a field of a resource holding the name of another resource in the same namespace.
+type fieldRef struct {
+	// path to the field; "*" matches every item of a list.
+	path []string
+	// kind is the kind of the referenced resource.
+	kind string
+	// kindField is the name of a sibling field holding the kind of the referenced resource; used when kind is empty.
+	kindField string
+	// namespaceField is the name of a sibling field holding the namespace of the referenced resource; references
+	// to other namespaces are left untouched.
+	namespaceField string
+}
+
+func ref(kind string, path ...string) fieldRef {
+	return fieldRef{path: path, kind: kind}
+}
+
+func kindRef(kindField string, path ...string) fieldRef {
+	return fieldRef{path: path, kindField: kindField}
+}
+
+// podSpecRefs are references found in a pod spec, relative to the pod spec.
+var podSpecRefs = []fieldRef{
+	ref("ConfigMap", "volumes", "*", "configMap", "name"),
+	ref("Secret", "volumes", "*", "secret", "secretName"),
+	ref("PersistentVolumeClaim", "volumes", "*", "persistentVolumeClaim", "claimName"),
+	ref("ConfigMap", "volumes", "*", "projected", "sources", "*", "configMap", "name"),
+	ref("Secret", "volumes", "*", "projected", "sources", "*", "secret", "name"),
+	ref("ConfigMap", "containers", "*", "envFrom", "*", "configMapRef", "name"),
+	ref("Secret", "containers", "*", "envFrom", "*", "secretRef", "name"),
+	ref("ConfigMap", "containers", "*", "env", "*", "valueFrom", "configMapKeyRef", "name"),
+	ref("Secret", "containers", "*", "env", "*", "valueFrom", "secretKeyRef", "name"),
+	ref("ConfigMap", "initContainers", "*", "envFrom", "*", "configMapRef", "name"),
+	ref("Secret", "initContainers", "*", "envFrom", "*", "secretRef", "name"),
+	ref("ConfigMap", "initContainers", "*", "env", "*", "valueFrom", "configMapKeyRef", "name"),
+	ref("Secret", "initContainers", "*", "env", "*", "valueFrom", "secretKeyRef", "name"),
+	ref("ServiceAccount", "serviceAccountName"),
+	ref("ServiceAccount", "serviceAccount"),
+	ref("Secret", "imagePullSecrets", "*", "name"),
+}
+
+// podSpecPaths are the paths of pod specs in kinds embedding them.
+var podSpecPaths = map[string][]string{
+	"Pod":                   {"spec"},
+	"Deployment":            {"spec", "template", "spec"},
+	"StatefulSet":           {"spec", "template", "spec"},
+	"DaemonSet":             {"spec", "template", "spec"},
+	"ReplicaSet":            {"spec", "template", "spec"},
+	"ReplicationController": {"spec", "template", "spec"},
+	"Job":                   {"spec", "template", "spec"},
+	"CronJob":               {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// kindRefs are references specific to a kind.
+var kindRefs = map[string][]fieldRef{
+	"StatefulSet": {
+		ref("Service", "spec", "serviceName"),
+	},
+	"Ingress": {
+		ref("Service", "spec", "defaultBackend", "service", "name"),
+		ref("Service", "spec", "rules", "*", "http", "paths", "*", "backend", "service", "name"),
+		// extensions/v1beta1 and networking.k8s.io/v1beta1
+		ref("Service", "spec", "backend", "serviceName"),
+		ref("Service", "spec", "rules", "*", "http", "paths", "*", "backend", "serviceName"),
+		ref("Secret", "spec", "tls", "*", "secretName"),
+	},
+	"RoleBinding": {
+		kindRef("kind", "roleRef", "name"),
+		{path: []string{"subjects", "*", "name"}, kindField: "kind", namespaceField: "namespace"},
+	},
+	"HorizontalPodAutoscaler": {
+		kindRef("kind", "spec", "scaleTargetRef", "name"),
+	},
+	"ServiceAccount": {
+		ref("Secret", "secrets", "*", "name"),
+		ref("Secret", "imagePullSecrets", "*", "name"),
+	},
+	"Route": {
+		kindRef("kind", "spec", "to", "name"),
+		kindRef("kind", "spec", "alternateBackends", "*", "name"),
+	},
+}
+
+// RenameFunc returns the new name for a resource of the given kind.
+type RenameFunc func(kind string, name string) string
+
+// Rewriter rewrites references to resources which are part of a chart, so they're consistent with the resources'
+// new names.
+type Rewriter struct {
+	// NamespaceTemplate, if set, replaces the namespace in references to members, such as RoleBinding subjects and
+	// service hosts like "nginx.default.svc.cluster.local".
+	NamespaceTemplate string
+	// members are the names of the resources in the chart indexed by kind.
+	members map[string]map[string]bool
+	rename  RenameFunc
+}
+
+func NewRewriter(rename RenameFunc) *Rewriter {
+	return &Rewriter{
+		members: make(map[string]map[string]bool),
+		rename:  rename,
+	}
+}
+
+// AddMember registers a resource as part of the chart; only references to members are rewritten.
+func (r *Rewriter) AddMember(kind string, name string) {
+	if _, ok := r.members[kind]; !ok {
+		r.members[kind] = make(map[string]bool)
+	}
+	r.members[kind][name] = true
+}
+
+// IsMember returns whether the resource is part of the chart.
+func (r *Rewriter) IsMember(kind string, name string) bool {
+	return r.members[kind][name]
+}
+
+// refsFor returns the references known for the given kind.
+func refsFor(kind string) []fieldRef {
+	var refs []fieldRef
+	if podSpecPath, ok := podSpecPaths[kind]; ok {
+		for _, podSpecRef := range podSpecRefs {
+			path := append(append([]string{}, podSpecPath...), podSpecRef.path...)
+			refs = append(refs, fieldRef{path: path, kind: podSpecRef.kind})
+		}
+	}
+	return append(refs, kindRefs[kind]...)
+}
+
+// Rewrite rewrites in place the references obj holds to members of the chart; namespace is the namespace obj was
+// collected from, used to ignore references to resources in other namespaces.
+func (r *Rewriter) Rewrite(obj *unstructured.Unstructured, namespace string) {
+	for _, fr := range refsFor(obj.GetKind()) {
+		visit(obj.Object, fr.path, func(parent map[string]interface{}, key string) {
+			name, ok := parent[key].(string)
+			if !ok {
+				return
+			}
+			kind := fr.kind
+			if kind == "" {
+				kind, _ = parent[fr.kindField].(string)
+			}
+			if fr.namespaceField != "" {
+				if ns, _ := parent[fr.namespaceField].(string); ns != "" && ns != namespace {
+					return
+				}
+			}
+			if !r.IsMember(kind, name) {
+				return
+			}
+			parent[key] = r.rename(kind, name)
+			if fr.namespaceField != "" && r.NamespaceTemplate != "" {
+				if ns, _ := parent[fr.namespaceField].(string); ns == namespace {
+					parent[fr.namespaceField] = r.NamespaceTemplate
+				}
+			}
+		})
+	}
+
+	for field, value := range obj.Object {
+		if field == "apiVersion" || field == "kind" || field == "metadata" {
+			continue
+		}
+		obj.Object[field] = r.rewriteServiceHosts(value, namespace)
+	}
+}
+
+// serviceHostPattern matches hosts of services in a namespace, such as "nginx.default.svc" or
+// "nginx.default.svc.cluster.local"; the namespace is matched by a placeholder replaced by serviceHostRegexpFor.
+const serviceHostPattern = `(^|[^-a-z0-9.])([a-z0-9]([-a-z0-9]*[a-z0-9])?)\.%s\.svc(\.cluster\.local)?`
+
+func serviceHostRegexpFor(namespace string) *regexp.Regexp {
+	return regexp.MustCompile(fmt.Sprintf(serviceHostPattern, regexp.QuoteMeta(namespace)))
+}
+
+// rewriteServiceHosts rewrites hosts of services which are members of the chart in every string held by value.
+func (r *Rewriter) rewriteServiceHosts(value interface{}, namespace string) interface{} {
+	switch v := value.(type) {
+	case string:
+		if namespace == "" || !strings.Contains(v, "."+namespace+".svc") {
+			return v
+		}
+		re := serviceHostRegexpFor(namespace)
+		return re.ReplaceAllStringFunc(v, func(match string) string {
+			groups := re.FindStringSubmatch(match)
+			prefix, name, suffix := groups[1], groups[2], groups[4]
+			if !r.IsMember("Service", name) {
+				return match
+			}
+			ns := namespace
+			if r.NamespaceTemplate != "" {
+				ns = r.NamespaceTemplate
+			}
+			return fmt.Sprintf("%s%s.%s.svc%s", prefix, r.rename("Service", name), ns, suffix)
+		})
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = r.rewriteServiceHosts(item, namespace)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = r.rewriteServiceHosts(item, namespace)
+		}
+	}
+	return value
+}
+
+// visit calls fn for every field matching path, with the map holding the field and the field's key.
+func visit(obj interface{}, path []string, fn func(parent map[string]interface{}, key string)) {
+	if len(path) == 0 {
+		return
+	}
+
+	if path[0] == "*" {
+		items, ok := obj.([]interface{})
+		if !ok {
+			return
+		}
+		for _, item := range items {
+			visit(item, path[1:], fn)
+		}
+		return
+	}
+
+	m, ok := obj.(map[string]interface{})
+	if !ok {
+		return
+	}
+	if len(path) == 1 {
+		if _, ok := m[path[0]]; ok {
+			fn(m, path[0])
+		}
+		return
+	}
+	visit(m[path[0]], path[1:], fn)
+}
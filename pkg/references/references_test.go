@@ -0,0 +1,127 @@
+package references
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/redhat-developer/helm-dump/pkg/test"
+)
+
+func newTestRewriter() *Rewriter {
+	r := NewRewriter(func(kind string, name string) string {
+		return fmt.Sprintf("%s-{{ .Release.Name }}", name)
+	})
+	r.AddMember("ConfigMap", "app-config")
+	r.AddMember("Secret", "app-secret")
+	r.AddMember("Secret", "nginx-tls")
+	r.AddMember("PersistentVolumeClaim", "app-data")
+	r.AddMember("ServiceAccount", "nginx")
+	r.AddMember("Role", "nginx")
+	r.AddMember("Service", "nginx")
+	r.AddMember("Deployment", "nginx-deployment")
+	return r
+}
+
+func requireNestedString(t *testing.T, obj *unstructured.Unstructured, expected string, fields ...interface{}) {
+	var current interface{} = obj.Object
+	for _, field := range fields {
+		switch f := field.(type) {
+		case string:
+			current = current.(map[string]interface{})[f]
+		case int:
+			current = current.([]interface{})[f]
+		}
+	}
+	require.Equal(t, expected, current, "field %v", fields)
+}
+
+func TestRewriter_Rewrite(t *testing.T) {
+	t.Run("deployment", func(t *testing.T) {
+		// Arrange
+		obj := test.LoadYamlFixture(t, "test/test_rewrite/nginx-deployment.yaml")
+		podSpec := []interface{}{"spec", "template", "spec"}
+		container := append(append([]interface{}{}, podSpec...), "containers", 0)
+
+		// Act
+		newTestRewriter().Rewrite(obj, "default")
+
+		// Assert
+		requireNestedString(t, obj, "nginx-{{ .Release.Name }}", append(podSpec, "serviceAccountName")...)
+		requireNestedString(t, obj, "app-config-{{ .Release.Name }}", append(container, "envFrom", 0, "configMapRef", "name")...)
+		requireNestedString(t, obj, "app-secret-{{ .Release.Name }}", append(container, "env", 0, "valueFrom", "secretKeyRef", "name")...)
+		requireNestedString(t, obj, "external-secret", append(container, "env", 1, "valueFrom", "secretKeyRef", "name")...)
+		requireNestedString(t, obj, "app-config-{{ .Release.Name }}", append(podSpec, "volumes", 0, "configMap", "name")...)
+		requireNestedString(t, obj, "app-data-{{ .Release.Name }}", append(podSpec, "volumes", 1, "persistentVolumeClaim", "claimName")...)
+	})
+
+	t.Run("rolebinding", func(t *testing.T) {
+		// Arrange
+		obj := test.LoadYamlFixture(t, "test/test_rewrite/nginx-rolebinding.yaml")
+
+		// Act
+		newTestRewriter().Rewrite(obj, "default")
+
+		// Assert
+		requireNestedString(t, obj, "nginx-{{ .Release.Name }}", "roleRef", "name")
+		requireNestedString(t, obj, "nginx-{{ .Release.Name }}", "subjects", 0, "name")
+		requireNestedString(t, obj, "nginx", "subjects", 1, "name")
+	})
+
+	t.Run("rolebinding-with-namespace-template", func(t *testing.T) {
+		// Arrange
+		obj := test.LoadYamlFixture(t, "test/test_rewrite/nginx-rolebinding.yaml")
+		r := newTestRewriter()
+		r.NamespaceTemplate = "{{ .Release.Namespace }}"
+
+		// Act
+		r.Rewrite(obj, "default")
+
+		// Assert
+		requireNestedString(t, obj, "nginx-{{ .Release.Name }}", "subjects", 0, "name")
+		requireNestedString(t, obj, "{{ .Release.Namespace }}", "subjects", 0, "namespace")
+		requireNestedString(t, obj, "other", "subjects", 1, "namespace")
+	})
+
+	t.Run("service-hosts", func(t *testing.T) {
+		// Arrange
+		obj := test.LoadYamlFixture(t, "test/test_rewrite/app-configmap.yaml")
+		r := newTestRewriter()
+		r.NamespaceTemplate = "{{ .Release.Namespace }}"
+
+		// Act
+		r.Rewrite(obj, "default")
+
+		// Assert
+		requireNestedString(t, obj, "http://nginx-{{ .Release.Name }}.{{ .Release.Namespace }}.svc.cluster.local:80/api", "data", "BACKEND_URL")
+		requireNestedString(t, obj, "nginx-{{ .Release.Name }}.{{ .Release.Namespace }}.svc", "data", "SHORT_HOST")
+		requireNestedString(t, obj, "redis.default.svc", "data", "CACHE_HOST")
+		requireNestedString(t, obj, "nginx.other.svc.cluster.local", "data", "PROXY_HOST")
+		requireNestedString(t, obj, "app-config", "metadata", "name")
+	})
+
+	t.Run("hpa", func(t *testing.T) {
+		// Arrange
+		obj := test.LoadYamlFixture(t, "test/test_rewrite/nginx-hpa.yaml")
+
+		// Act
+		newTestRewriter().Rewrite(obj, "default")
+
+		// Assert
+		requireNestedString(t, obj, "nginx-deployment-{{ .Release.Name }}", "spec", "scaleTargetRef", "name")
+	})
+
+	t.Run("ingress", func(t *testing.T) {
+		// Arrange
+		obj := test.LoadYamlFixture(t, "test/test_rewrite/nginx-ingress.yaml")
+
+		// Act
+		newTestRewriter().Rewrite(obj, "default")
+
+		// Assert
+		requireNestedString(t, obj, "nginx-tls-{{ .Release.Name }}", "spec", "tls", 0, "secretName")
+		requireNestedString(t, obj, "nginx-{{ .Release.Name }}", "spec", "rules", 0, "http", "paths", 0, "backend", "service", "name")
+	})
+}
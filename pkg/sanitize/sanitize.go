@@ -0,0 +1,270 @@
+package sanitize
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// NodePortPolicy determines how the fixed node ports of Services collected by init are included in the chart.
+type NodePortPolicy string
+
+const (
+	// RemoveNodePorts drops the node ports, so the cluster assigns new ones when the chart is installed.
+	RemoveNodePorts NodePortPolicy = "remove"
+	// ValuesNodePorts moves the node ports into values.yaml, defaulting to the collected ports.
+	ValuesNodePorts NodePortPolicy = "values"
+	// KeepNodePorts includes the node ports verbatim; installing the chart fails while the ports are taken.
+	KeepNodePorts NodePortPolicy = "keep"
+)
+
+// DefaultNodePortPolicy is the policy used when none is informed.
+const DefaultNodePortPolicy = RemoveNodePorts
+
+// NodePortPolicies are all known node port policies.
+var NodePortPolicies = []NodePortPolicy{RemoveNodePorts, ValuesNodePorts, KeepNodePorts}
+
+// ParseNodePortPolicy returns the policy for s, or DefaultNodePortPolicy if s is empty.
+func ParseNodePortPolicy(s string) (NodePortPolicy, error) {
+	if s == "" {
+		return DefaultNodePortPolicy, nil
+	}
+	for _, policy := range NodePortPolicies {
+		if string(policy) == s {
+			return policy, nil
+		}
+	}
+	return "", fmt.Errorf("unknown node ports policy %q; available policies are %v", s, NodePortPolicies)
+}
+
+const (
+	// NodePortsValuesKey is the values.yaml key holding the node ports moved by the values policy.
+	NodePortsValuesKey = "nodePorts"
+
+	// valueMarker prefixes the values.yaml path of a field to be templated; a non-string value can't be expressed
+	// as a template in a field, so the marker is replaced by the action by RenderValues once the resource is
+	// serialized.
+	valueMarker = "helm-dump/value="
+)
+
+var (
+	serviceGK        = schema.GroupKind{Group: "", Kind: "Service"}
+	pvcGK            = schema.GroupKind{Group: "", Kind: "PersistentVolumeClaim"}
+	podGK            = schema.GroupKind{Group: "", Kind: "Pod"}
+	jobGK            = schema.GroupKind{Group: "batch", Kind: "Job"}
+	cronJobGK        = schema.GroupKind{Group: "batch", Kind: "CronJob"}
+	controllerLabels = []string{
+		"controller-uid",
+		"batch.kubernetes.io/controller-uid",
+		"job-name",
+		"batch.kubernetes.io/job-name",
+	}
+)
+
+// pvcBindingAnnotations are set by the cluster when a PersistentVolumeClaim is bound or provisioned.
+var pvcBindingAnnotations = []string{
+	"pv.kubernetes.io/bind-completed",
+	"pv.kubernetes.io/bound-by-controller",
+	"volume.beta.kubernetes.io/storage-provisioner",
+	"volume.kubernetes.io/storage-provisioner",
+	"volume.kubernetes.io/selected-node",
+}
+
+// Report describes the fields of a resource removed or templated by the sanitizer.
+type Report struct {
+	Kind   string
+	Name   string
+	Fields []string
+}
+
+// Sanitizer removes or templates the fields assigned by the cluster, which make installing the chart fail or
+// conflict with the collected resources.
+type Sanitizer struct {
+	NodePorts NodePortPolicy
+	// Values are the values required by the templates produced by the sanitizer.
+	Values map[string]interface{}
+	// Reports describe all resources with sanitized fields.
+	Reports []Report
+}
+
+func NewSanitizer(nodePorts NodePortPolicy) *Sanitizer {
+	return &Sanitizer{
+		NodePorts: nodePorts,
+		Values:    make(map[string]interface{}),
+	}
+}
+
+// Sanitize sanitizes obj in place, which is the transformed version of the resource named originalName.
+func (s *Sanitizer) Sanitize(obj *unstructured.Unstructured, originalName string) {
+	var fields []string
+	switch obj.GroupVersionKind().GroupKind() {
+	case serviceGK:
+		fields = s.sanitizeService(obj, originalName)
+	case pvcGK:
+		fields = sanitizePVC(obj)
+	case podGK:
+		fields = removeControllerLabels(obj.Object, "metadata", "labels")
+	case jobGK:
+		fields = sanitizeJob(obj.Object)
+	case cronJobGK:
+		fields = sanitizeJob(obj.Object, "spec", "jobTemplate")
+	}
+	if len(fields) > 0 {
+		s.Reports = append(s.Reports, Report{Kind: obj.GetKind(), Name: originalName, Fields: fields})
+	}
+}
+
+func (s *Sanitizer) sanitizeService(obj *unstructured.Unstructured, name string) []string {
+	var fields []string
+	if clusterIP, found, _ := unstructured.NestedString(obj.Object, "spec", "clusterIP"); found && clusterIP != "None" {
+		unstructured.RemoveNestedField(obj.Object, "spec", "clusterIP")
+		fields = append(fields, "spec.clusterIP removed")
+	}
+	if clusterIPs, found, _ := unstructured.NestedStringSlice(obj.Object, "spec", "clusterIPs"); found {
+		if len(clusterIPs) == 0 || clusterIPs[0] != "None" {
+			unstructured.RemoveNestedField(obj.Object, "spec", "clusterIPs")
+			fields = append(fields, "spec.clusterIPs removed")
+		}
+	}
+	if _, found, _ := unstructured.NestedFieldNoCopy(obj.Object, "spec", "healthCheckNodePort"); found {
+		unstructured.RemoveNestedField(obj.Object, "spec", "healthCheckNodePort")
+		fields = append(fields, "spec.healthCheckNodePort removed")
+	}
+	if s.NodePorts == KeepNodePorts {
+		return fields
+	}
+
+	ports, _, _ := unstructured.NestedSlice(obj.Object, "spec", "ports")
+	serviceValues := make(map[string]interface{})
+	for i, p := range ports {
+		port, ok := p.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		nodePort, ok := port["nodePort"]
+		if !ok {
+			continue
+		}
+		if s.NodePorts == RemoveNodePorts {
+			delete(port, "nodePort")
+			fields = append(fields, fmt.Sprintf("spec.ports[%d].nodePort removed", i))
+			continue
+		}
+		key := portKey(port, i)
+		serviceValues[key] = nodePort
+		port["nodePort"] = valueMarker + strings.Join([]string{NodePortsValuesKey, name, key}, ".")
+		fields = append(fields, fmt.Sprintf("spec.ports[%d].nodePort moved to values.yaml under %s.%s.%s", i, NodePortsValuesKey, name, key))
+	}
+	if len(serviceValues) > 0 {
+		nodePortsValues, _ := s.Values[NodePortsValuesKey].(map[string]interface{})
+		if nodePortsValues == nil {
+			nodePortsValues = make(map[string]interface{})
+			s.Values[NodePortsValuesKey] = nodePortsValues
+		}
+		nodePortsValues[name] = serviceValues
+	}
+	if len(ports) > 0 {
+		_ = unstructured.SetNestedSlice(obj.Object, ports, "spec", "ports")
+	}
+	return fields
+}
+
+// portKey returns the key of a Service's port in values.yaml: its name or, for unnamed ports, its number.
+func portKey(port map[string]interface{}, i int) string {
+	if name, ok := port["name"].(string); ok && name != "" {
+		return name
+	}
+	switch p := port["port"].(type) {
+	case int64:
+		return strconv.FormatInt(p, 10)
+	case float64:
+		return strconv.FormatInt(int64(p), 10)
+	}
+	return strconv.Itoa(i)
+}
+
+func sanitizePVC(obj *unstructured.Unstructured) []string {
+	var fields []string
+	if _, found, _ := unstructured.NestedString(obj.Object, "spec", "volumeName"); found {
+		unstructured.RemoveNestedField(obj.Object, "spec", "volumeName")
+		fields = append(fields, "spec.volumeName removed")
+	}
+	annotations := obj.GetAnnotations()
+	for _, k := range pvcBindingAnnotations {
+		if _, ok := annotations[k]; ok {
+			delete(annotations, k)
+			fields = append(fields, fmt.Sprintf("annotation %s removed", k))
+		}
+	}
+	if len(annotations) == 0 {
+		unstructured.RemoveNestedField(obj.Object, "metadata", "annotations")
+	} else {
+		obj.SetAnnotations(annotations)
+	}
+	return fields
+}
+
+// sanitizeJob removes the labels and selector generated for the Job found at path, which is empty for Jobs and
+// spec.jobTemplate for CronJobs.
+func sanitizeJob(obj map[string]interface{}, path ...string) []string {
+	at := func(fields ...string) []string {
+		return append(append([]string{}, path...), fields...)
+	}
+
+	fields := removeControllerLabels(obj, at("metadata", "labels")...)
+	manualSelector, _, _ := unstructured.NestedBool(obj, at("spec", "manualSelector")...)
+	if _, found, _ := unstructured.NestedMap(obj, at("spec", "selector")...); found {
+		if manualSelector {
+			fields = append(fields, removeControllerLabels(obj, at("spec", "selector", "matchLabels")...)...)
+		} else {
+			// the selector is generated from the Job's uid unless it's manually informed.
+			unstructured.RemoveNestedField(obj, at("spec", "selector")...)
+			fields = append(fields, strings.Join(at("spec", "selector"), ".")+" removed")
+		}
+	}
+	return append(fields, removeControllerLabels(obj, at("spec", "template", "metadata", "labels")...)...)
+}
+
+// removeControllerLabels removes the labels set by the Job controller from the map at path.
+func removeControllerLabels(obj map[string]interface{}, path ...string) []string {
+	labels, found, _ := unstructured.NestedMap(obj, path...)
+	if !found {
+		return nil
+	}
+	var fields []string
+	for _, k := range controllerLabels {
+		if _, ok := labels[k]; ok {
+			delete(labels, k)
+			fields = append(fields, fmt.Sprintf("%s[%s] removed", strings.Join(path, "."), k))
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	if len(labels) == 0 {
+		unstructured.RemoveNestedField(obj, path...)
+	} else {
+		_ = unstructured.SetNestedMap(obj, labels, path...)
+	}
+	return fields
+}
+
+var valueMarkerRegexp = regexp.MustCompile(`(?m)^( *-? ?\w+): ` + regexp.QuoteMeta(valueMarker) + `([\w.-]+)$`)
+
+// RenderValues replaces the value markers in a serialized resource with the actions referencing values.yaml, for
+// example "{{ index .Values "nodePorts" "nginx" "http" }}".
+func RenderValues(data []byte) []byte {
+	return valueMarkerRegexp.ReplaceAllFunc(data, func(match []byte) []byte {
+		groups := valueMarkerRegexp.FindSubmatch(match)
+		field, valuesPath := groups[1], strings.Split(string(groups[2]), ".")
+		keys := make([]string, len(valuesPath))
+		for i, k := range valuesPath {
+			keys[i] = strconv.Quote(k)
+		}
+		return []byte(fmt.Sprintf("%s: {{ index .Values %s }}", field, strings.Join(keys, " ")))
+	})
+}
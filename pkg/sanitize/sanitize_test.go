@@ -0,0 +1,140 @@
+package sanitize
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/redhat-developer/helm-dump/pkg/test"
+)
+
+func TestSanitizer_Sanitize(t *testing.T) {
+	t.Run("service-removing-node-ports", func(t *testing.T) {
+		// Arrange
+		s := NewSanitizer(RemoveNodePorts)
+		obj := test.LoadYamlFixture(t, "test/test_sanitize/nodeport-service.yaml")
+
+		// Act
+		s.Sanitize(obj, "nginx")
+
+		// Assert
+		_, found, _ := unstructured.NestedString(obj.Object, "spec", "clusterIP")
+		require.False(t, found, "clusterIP should be removed")
+		_, found, _ = unstructured.NestedStringSlice(obj.Object, "spec", "clusterIPs")
+		require.False(t, found, "clusterIPs should be removed")
+		ports, _, _ := unstructured.NestedSlice(obj.Object, "spec", "ports")
+		require.NotContains(t, ports[0], "nodePort")
+		require.Empty(t, s.Values)
+		require.Equal(t, []Report{{
+			Kind:   "Service",
+			Name:   "nginx",
+			Fields: []string{"spec.clusterIP removed", "spec.clusterIPs removed", "spec.ports[0].nodePort removed"},
+		}}, s.Reports)
+	})
+
+	t.Run("service-moving-node-ports-to-values", func(t *testing.T) {
+		// Arrange
+		s := NewSanitizer(ValuesNodePorts)
+		obj := test.LoadYamlFixture(t, "test/test_sanitize/nodeport-service.yaml")
+
+		// Act
+		s.Sanitize(obj, "nginx")
+
+		// Assert
+		require.Equal(t, map[string]interface{}{
+			NodePortsValuesKey: map[string]interface{}{
+				"nginx": map[string]interface{}{"http": int64(30080)},
+			},
+		}, s.Values)
+		ports, _, _ := unstructured.NestedSlice(obj.Object, "spec", "ports")
+		require.Equal(t, "helm-dump/value=nodePorts.nginx.http", ports[0].(map[string]interface{})["nodePort"])
+	})
+
+	t.Run("service-keeping-node-ports", func(t *testing.T) {
+		// Arrange
+		s := NewSanitizer(KeepNodePorts)
+		obj := test.LoadYamlFixture(t, "test/test_sanitize/nodeport-service.yaml")
+
+		// Act
+		s.Sanitize(obj, "nginx")
+
+		// Assert
+		ports, _, _ := unstructured.NestedSlice(obj.Object, "spec", "ports")
+		require.Equal(t, int64(30080), ports[0].(map[string]interface{})["nodePort"])
+	})
+
+	t.Run("headless-service", func(t *testing.T) {
+		// Arrange
+		s := NewSanitizer(DefaultNodePortPolicy)
+		obj := test.LoadYamlFixture(t, "test/test_sanitize/headless-service.yaml")
+
+		// Act
+		s.Sanitize(obj, "db")
+
+		// Assert
+		clusterIP, _, _ := unstructured.NestedString(obj.Object, "spec", "clusterIP")
+		require.Equal(t, "None", clusterIP, "headless services must be kept headless")
+		require.Empty(t, s.Reports)
+	})
+
+	t.Run("persistent-volume-claim", func(t *testing.T) {
+		// Arrange
+		s := NewSanitizer(DefaultNodePortPolicy)
+		obj := test.LoadYamlFixture(t, "test/test_sanitize/bound-pvc.yaml")
+
+		// Act
+		s.Sanitize(obj, "data")
+
+		// Assert
+		_, found, _ := unstructured.NestedString(obj.Object, "spec", "volumeName")
+		require.False(t, found, "volumeName should be removed")
+		require.Empty(t, obj.GetAnnotations())
+		require.Len(t, s.Reports[0].Fields, 4)
+	})
+
+	t.Run("job", func(t *testing.T) {
+		// Arrange
+		s := NewSanitizer(DefaultNodePortPolicy)
+		obj := test.LoadYamlFixture(t, "test/test_sanitize/job.yaml")
+
+		// Act
+		s.Sanitize(obj, "migrate")
+
+		// Assert
+		require.Equal(t, map[string]string{"app": "migrate"}, obj.GetLabels())
+		_, found, _ := unstructured.NestedMap(obj.Object, "spec", "selector")
+		require.False(t, found, "the generated selector should be removed")
+		labels, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "template", "metadata", "labels")
+		require.Equal(t, map[string]string{"app": "migrate"}, labels)
+	})
+
+	t.Run("cronjob-with-manual-selector", func(t *testing.T) {
+		// Arrange
+		s := NewSanitizer(DefaultNodePortPolicy)
+		obj := test.LoadYamlFixture(t, "test/test_sanitize/manual-selector-cronjob.yaml")
+
+		// Act
+		s.Sanitize(obj, "backup")
+
+		// Assert
+		matchLabels, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "jobTemplate", "spec", "selector", "matchLabels")
+		require.Equal(t, map[string]string{"app": "backup"}, matchLabels, "manual selectors should be kept")
+		labels, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "jobTemplate", "spec", "template", "metadata", "labels")
+		require.Equal(t, map[string]string{"app": "backup"}, labels)
+	})
+}
+
+func TestRenderValues(t *testing.T) {
+	// Arrange
+	data := []byte("spec:\n  ports:\n  - name: http\n    nodePort: helm-dump/value=nodePorts.my-svc.http\n  - nodePort: helm-dump/value=nodePorts.my-svc.8080\n")
+
+	// Act
+	actual := RenderValues(data)
+
+	// Assert
+	require.Equal(t,
+		"spec:\n  ports:\n  - name: http\n    nodePort: {{ index .Values \"nodePorts\" \"my-svc\" \"http\" }}\n"+
+			"  - nodePort: {{ index .Values \"nodePorts\" \"my-svc\" \"8080\" }}\n",
+		string(actual))
+}
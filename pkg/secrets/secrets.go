@@ -0,0 +1,250 @@
+package secrets
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Policy determines how Secrets collected by init are included in the chart.
+type Policy string
+
+const (
+	// Skip drops Secrets from the chart.
+	Skip Policy = "skip"
+	// Redact replaces the Secrets' data with placeholders.
+	Redact Policy = "redact"
+	// Values moves the Secrets' data into values.yaml as required values.
+	Values Policy = "values"
+	// External replaces Secrets with ExternalSecret resources referencing the Secrets' keys in an external store.
+	External Policy = "external"
+	// Keep includes Secrets verbatim, credentials included.
+	Keep Policy = "keep"
+)
+
+// DefaultPolicy is the policy used when none is informed.
+const DefaultPolicy = Redact
+
+// Policies are all known policies.
+var Policies = []Policy{Skip, Redact, Values, External, Keep}
+
+// ParsePolicy returns the policy for s, or DefaultPolicy if s is empty.
+func ParsePolicy(s string) (Policy, error) {
+	if s == "" {
+		return DefaultPolicy, nil
+	}
+	for _, policy := range Policies {
+		if string(policy) == s {
+			return policy, nil
+		}
+	}
+	return "", fmt.Errorf("unknown secrets policy %q; available policies are %v", s, Policies)
+}
+
+const (
+	// RedactedValue is the placeholder replacing Secrets' data by the redact policy.
+	RedactedValue = "<redacted>"
+	// ValuesKey is the values.yaml key holding Secrets' data moved by the values policy.
+	ValuesKey = "secrets"
+	// ExternalSecretsValuesKey is the values.yaml key holding the configuration of the ExternalSecret resources
+	// created by the external policy.
+	ExternalSecretsValuesKey = "externalSecrets"
+)
+
+var secretGK = schema.GroupKind{Group: "", Kind: "Secret"}
+
+// generatedTypes are types of Secrets created by the cluster or by Helm, which are never included in a chart.
+var generatedTypes = map[string]bool{
+	"kubernetes.io/service-account-token": true,
+	"helm.sh/release.v1":                  true,
+}
+
+// IsSecret returns whether obj is a Secret.
+func IsSecret(obj *unstructured.Unstructured) bool {
+	return obj.GroupVersionKind().GroupKind() == secretGK
+}
+
+// Report describes a Secret found by init and how it was handled.
+type Report struct {
+	Name   string
+	Type   string
+	Keys   []string
+	Action string
+}
+
+// Handler applies a policy to Secrets collected by init.
+type Handler struct {
+	Policy Policy
+	// Values are the values required by the templates produced by the handler.
+	Values map[string]interface{}
+	// Reports describe all Secrets handled.
+	Reports []Report
+}
+
+func NewHandler(policy Policy) *Handler {
+	return &Handler{
+		Policy: policy,
+		Values: make(map[string]interface{}),
+	}
+}
+
+// Handle applies the policy to obj, which is the transformed version of the Secret named originalName; the object
+// to include in the chart is returned, or nil if the Secret should be dropped.
+func (h *Handler) Handle(obj *unstructured.Unstructured, originalName string) (*unstructured.Unstructured, error) {
+	secretType, _, _ := unstructured.NestedString(obj.Object, "type")
+	keys := dataKeys(obj)
+	report := Report{Name: originalName, Type: secretType, Keys: keys}
+	defer func() {
+		h.Reports = append(h.Reports, report)
+	}()
+
+	if generatedTypes[secretType] {
+		report.Action = "skipped, generated by the cluster or Helm"
+		return nil, nil
+	}
+
+	switch h.Policy {
+	case Skip:
+		report.Action = "skipped"
+		return nil, nil
+	case Keep:
+		report.Action = "kept verbatim, credentials are included in the chart"
+		return obj, nil
+	case Values:
+		report.Action = fmt.Sprintf("moved to values.yaml under %s.%s", ValuesKey, originalName)
+		return h.toValues(obj, originalName, keys)
+	case External:
+		report.Action = "replaced by an ExternalSecret"
+		return h.toExternalSecret(obj, originalName, secretType, keys)
+	default:
+		report.Action = "redacted"
+		return redact(obj, keys)
+	}
+}
+
+// dataKeys returns the sorted keys of the Secret's data and stringData.
+func dataKeys(obj *unstructured.Unstructured) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, field := range []string{"data", "stringData"} {
+		m, _, _ := unstructured.NestedMap(obj.Object, field)
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func redact(obj *unstructured.Unstructured, keys []string) (*unstructured.Unstructured, error) {
+	unstructured.RemoveNestedField(obj.Object, "data")
+	if len(keys) == 0 {
+		unstructured.RemoveNestedField(obj.Object, "stringData")
+		return obj, nil
+	}
+	stringData := make(map[string]interface{}, len(keys))
+	for _, k := range keys {
+		stringData[k] = RedactedValue
+	}
+	err := unstructured.SetNestedMap(obj.Object, stringData, "stringData")
+	return obj, err
+}
+
+func (h *Handler) toValues(obj *unstructured.Unstructured, name string, keys []string) (*unstructured.Unstructured, error) {
+	unstructured.RemoveNestedField(obj.Object, "stringData")
+	if len(keys) == 0 {
+		unstructured.RemoveNestedField(obj.Object, "data")
+		return obj, nil
+	}
+
+	secretValues := make(map[string]interface{}, len(keys))
+	data := make(map[string]interface{}, len(keys))
+	for _, k := range keys {
+		secretValues[k] = ""
+		data[k] = fmt.Sprintf(
+			`{{ required "%s.%s.%s is required" (index .Values.%s %q %q) | b64enc }}`,
+			ValuesKey, name, k, ValuesKey, name, k)
+	}
+
+	secretsValues, _ := h.Values[ValuesKey].(map[string]interface{})
+	if secretsValues == nil {
+		secretsValues = make(map[string]interface{})
+		h.Values[ValuesKey] = secretsValues
+	}
+	secretsValues[name] = secretValues
+
+	err := unstructured.SetNestedMap(obj.Object, data, "data")
+	return obj, err
+}
+
+func (h *Handler) toExternalSecret(obj *unstructured.Unstructured, name string, secretType string, keys []string) (*unstructured.Unstructured, error) {
+	if _, ok := h.Values[ExternalSecretsValuesKey]; !ok {
+		h.Values[ExternalSecretsValuesKey] = map[string]interface{}{
+			"refreshInterval": "1h",
+			"secretStoreRef": map[string]interface{}{
+				"name": "",
+				"kind": "SecretStore",
+			},
+		}
+	}
+
+	data := make([]interface{}, 0, len(keys))
+	for _, k := range keys {
+		data = append(data, map[string]interface{}{
+			"secretKey": k,
+			"remoteRef": map[string]interface{}{
+				"key":      name,
+				"property": k,
+			},
+		})
+	}
+
+	target := map[string]interface{}{
+		"name":           obj.GetName(),
+		"creationPolicy": "Owner",
+	}
+	if secretType != "" {
+		target["template"] = map[string]interface{}{"type": secretType}
+	}
+
+	metadata := map[string]interface{}{
+		"name": obj.GetName(),
+	}
+	if labels := obj.GetLabels(); len(labels) > 0 {
+		metadata["labels"] = toInterfaceMap(labels)
+	}
+	if anns := obj.GetAnnotations(); len(anns) > 0 {
+		metadata["annotations"] = toInterfaceMap(anns)
+	}
+	if ns := obj.GetNamespace(); ns != "" {
+		metadata["namespace"] = ns
+	}
+
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "external-secrets.io/v1beta1",
+		"kind":       "ExternalSecret",
+		"metadata":   metadata,
+		"spec": map[string]interface{}{
+			"refreshInterval": fmt.Sprintf("{{ .Values.%s.refreshInterval }}", ExternalSecretsValuesKey),
+			"secretStoreRef": map[string]interface{}{
+				"name": fmt.Sprintf(`{{ required "%s.secretStoreRef.name is required" .Values.%s.secretStoreRef.name }}`, ExternalSecretsValuesKey, ExternalSecretsValuesKey),
+				"kind": fmt.Sprintf("{{ .Values.%s.secretStoreRef.kind }}", ExternalSecretsValuesKey),
+			},
+			"target": target,
+			"data":   data,
+		},
+	}}, nil
+}
+
+func toInterfaceMap(m map[string]string) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
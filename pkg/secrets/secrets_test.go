@@ -0,0 +1,99 @@
+package secrets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/redhat-developer/helm-dump/pkg/test"
+)
+
+func TestHandler_Handle(t *testing.T) {
+	t.Run("skip", func(t *testing.T) {
+		// Arrange
+		h := NewHandler(Skip)
+		obj := test.LoadYamlFixture(t, "test/test_handle/app-secret.yaml")
+
+		// Act
+		actual, err := h.Handle(obj, "app-secret")
+
+		// Assert
+		require.NoError(t, err)
+		require.Nil(t, actual)
+		require.Len(t, h.Reports, 1)
+		require.Equal(t, []string{"password"}, h.Reports[0].Keys)
+	})
+
+	t.Run("redact", func(t *testing.T) {
+		// Arrange
+		h := NewHandler(Redact)
+		obj := test.LoadYamlFixture(t, "test/test_handle/app-secret.yaml")
+
+		// Act
+		actual, err := h.Handle(obj, "app-secret")
+
+		// Assert
+		require.NoError(t, err)
+		_, found, _ := unstructured.NestedMap(actual.Object, "data")
+		require.False(t, found, "data should be removed")
+		stringData, _, _ := unstructured.NestedStringMap(actual.Object, "stringData")
+		require.Equal(t, map[string]string{"password": RedactedValue}, stringData)
+	})
+
+	t.Run("values", func(t *testing.T) {
+		// Arrange
+		h := NewHandler(Values)
+		obj := test.LoadYamlFixture(t, "test/test_handle/app-secret.yaml")
+
+		// Act
+		actual, err := h.Handle(obj, "app-secret")
+
+		// Assert
+		require.NoError(t, err)
+		data, _, _ := unstructured.NestedStringMap(actual.Object, "data")
+		require.Equal(t,
+			`{{ required "secrets.app-secret.password is required" (index .Values.secrets "app-secret" "password") | b64enc }}`,
+			data["password"])
+		require.Equal(t, map[string]interface{}{
+			ValuesKey: map[string]interface{}{
+				"app-secret": map[string]interface{}{"password": ""},
+			},
+		}, h.Values)
+	})
+
+	t.Run("external", func(t *testing.T) {
+		// Arrange
+		h := NewHandler(External)
+		obj := test.LoadYamlFixture(t, "test/test_handle/app-secret.yaml")
+
+		// Act
+		actual, err := h.Handle(obj, "app-secret")
+
+		// Assert
+		require.NoError(t, err)
+		require.Equal(t, "ExternalSecret", actual.GetKind())
+		require.Equal(t, "app-secret", actual.GetName())
+		data, _, _ := unstructured.NestedSlice(actual.Object, "spec", "data")
+		require.Equal(t, []interface{}{
+			map[string]interface{}{
+				"secretKey": "password",
+				"remoteRef": map[string]interface{}{"key": "app-secret", "property": "password"},
+			},
+		}, data)
+		require.Contains(t, h.Values, ExternalSecretsValuesKey)
+	})
+
+	t.Run("generated", func(t *testing.T) {
+		// Arrange
+		h := NewHandler(Keep)
+		obj := test.LoadYamlFixture(t, "test/test_handle/default-token.yaml")
+
+		// Act
+		actual, err := h.Handle(obj, "default-token-abcde")
+
+		// Assert
+		require.NoError(t, err)
+		require.Nil(t, actual, "generated secrets should always be skipped")
+	})
+}
@@ -9,6 +9,8 @@ import (
 	"github.com/stretchr/testify/require"
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
 )
 
 // RequireChartFileExists ...
@@ -28,3 +30,23 @@ func RequireChart(t *testing.T, baseDir, chartName, chartVersion string) *chart.
 	require.NoError(t, err, "%q should be a chart", expectedChartPath)
 	return chrt
 }
+
+// RequireRenderedTemplates renders the chart's templates with default values for the given release name.
+func RequireRenderedTemplates(t *testing.T, chrt *chart.Chart, releaseName string) map[string]string {
+	return RequireRenderedTemplatesInNamespace(t, chrt, releaseName, "default")
+}
+
+// RequireRenderedTemplatesInNamespace renders the chart's templates with default values for the given release name
+// and namespace.
+func RequireRenderedTemplatesInNamespace(t *testing.T, chrt *chart.Chart, releaseName, namespace string) map[string]string {
+	values, err := chartutil.ToRenderValues(
+		chrt,
+		map[string]interface{}{},
+		chartutil.ReleaseOptions{Name: releaseName, Namespace: namespace, IsInstall: true},
+		chartutil.DefaultCapabilities,
+	)
+	require.NoError(t, err)
+	rendered, err := engine.Render(chrt, values)
+	require.NoError(t, err, "chart should render")
+	return rendered
+}
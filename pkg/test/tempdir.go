@@ -1,8 +1,10 @@
 package test
 
 import (
+	"io/fs"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -13,3 +15,28 @@ func TempDir(t *testing.T) string {
 	require.NoError(t, err)
 	return outDir
 }
+
+// CopyDir copies the fixture directory src into a temporary directory, so tests can modify it; the copy's path is
+// returned.
+func CopyDir(t *testing.T, src string) string {
+	dst := filepath.Join(TempDir(t), filepath.Base(src))
+	err := filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), os.ModePerm)
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dst, rel), data, 0644)
+	})
+	require.NoError(t, err)
+	return dst
+}
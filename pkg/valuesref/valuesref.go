@@ -0,0 +1,252 @@
+package valuesref
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template/parse"
+)
+
+// Reference is a reference to values in a template, such as .Values.nginx.replicas or $.Values.nginx.replicas.
+type Reference struct {
+	// Key is the path of the referenced value below .Values; it's empty when .Values itself is referenced.
+	Key []string
+	// Line is the 1-based line of the reference in the template.
+	Line int
+	// Begin and End span the reference's text, such as .Values.nginx.replicas.
+	Begin int
+	End   int
+	// Standalone is whether the reference is the only content of an action, such as {{ .Values.nginx.replicas }},
+	// which is spanned by ActionBegin and ActionEnd.
+	Standalone  bool
+	ActionBegin int
+	ActionEnd   int
+	// TrimLeft and TrimRight are whether the standalone action trims the spaces around it, as in
+	// {{- .Values.nginx.replicas -}}.
+	TrimLeft  bool
+	TrimRight bool
+	// Pipe is the pipeline a standalone action passes the reference to, as written by move-to-values to keep the
+	// style of quoted and block scalars: "quote", "toYaml | nindent N", or "" if there's none.
+	Pipe string
+}
+
+// KeyString returns the reference's key as written after .Values, such as nginx.replicas.
+func (r Reference) KeyString() string {
+	return strings.Join(r.Key, ".")
+}
+
+// HasPrefix returns whether the reference's key is prefix or below it.
+func (r Reference) HasPrefix(prefix []string) bool {
+	if len(r.Key) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if r.Key[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Overlaps returns whether the reference's key is key, is below it, or holds it.
+func (r Reference) Overlaps(key []string) bool {
+	n := len(key)
+	if len(r.Key) < n {
+		n = len(r.Key)
+	}
+	for i := 0; i < n; i++ {
+		if r.Key[i] != key[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Find returns the references to values in the template name, whose contents are data, in the order they appear.
+func Find(name string, data []byte) ([]Reference, error) {
+	tree := parse.New(name)
+	tree.Mode = parse.SkipFuncCheck
+	trees := make(map[string]*parse.Tree)
+	if _, err := tree.Parse(string(data), "", "", trees); err != nil {
+		return nil, fmt.Errorf("error parsing template %s: %w", name, err)
+	}
+
+	f := &finder{data: data}
+	for _, t := range trees {
+		if t.Root != nil {
+			f.walk(t.Root)
+		}
+	}
+	if f.err != nil {
+		return nil, fmt.Errorf("error parsing template %s: %w", name, f.err)
+	}
+
+	// trees are visited in no particular order.
+	sort.Slice(f.refs, func(i, j int) bool {
+		return f.refs[i].Begin < f.refs[j].Begin
+	})
+	return f.refs, nil
+}
+
+type finder struct {
+	data []byte
+	refs []Reference
+	err  error
+}
+
+func (f *finder) walk(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			f.walk(child)
+		}
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) == 0 && len(n.Pipe.Cmds) > 0 && len(n.Pipe.Cmds[0].Args) == 1 {
+			ref, isRef := f.reference(n.Pipe.Cmds[0].Args[0])
+			pipe, end, isStylePipe := stylePipe(n.Pipe.Cmds[1:])
+			if isRef && isStylePipe {
+				if pipe == "" {
+					end = ref.End
+				}
+				ref.Pipe = pipe
+				f.standalone(&ref, end)
+				f.add(ref)
+				return
+			}
+		}
+		f.walk(n.Pipe)
+	case *parse.IfNode:
+		f.walkBranch(&n.BranchNode)
+	case *parse.RangeNode:
+		f.walkBranch(&n.BranchNode)
+	case *parse.WithNode:
+		f.walkBranch(&n.BranchNode)
+	case *parse.TemplateNode:
+		f.walk(n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			f.walk(cmd)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			f.walk(arg)
+		}
+	case *parse.ChainNode:
+		f.walk(n.Node)
+	default:
+		if ref, ok := f.reference(node); ok {
+			f.add(ref)
+		}
+	}
+}
+
+func (f *finder) walkBranch(n *parse.BranchNode) {
+	f.walk(n.Pipe)
+	f.walk(n.List)
+	f.walk(n.ElseList)
+}
+
+func (f *finder) add(ref Reference) {
+	ref.Line = 1 + bytes.Count(f.data[:ref.Begin], []byte("\n"))
+	f.refs = append(f.refs, ref)
+}
+
+// reference returns the reference node is, if it's a field or variable starting with .Values or $.Values.
+func (f *finder) reference(node parse.Node) (Reference, bool) {
+	var idents []string
+	var first string
+	switch n := node.(type) {
+	case *parse.FieldNode:
+		if len(n.Ident) == 0 || n.Ident[0] != "Values" {
+			return Reference{}, false
+		}
+		idents, first = n.Ident[1:], "."+n.Ident[0]
+	case *parse.VariableNode:
+		if len(n.Ident) < 2 || n.Ident[0] != "$" || n.Ident[1] != "Values" {
+			return Reference{}, false
+		}
+		idents, first = n.Ident[2:], n.Ident[0]
+	default:
+		return Reference{}, false
+	}
+
+	// the position of a chain of fields is the one of its second field.
+	text := node.String()
+	begin := int(node.Position())
+	if strings.Contains(text[1:], ".") {
+		begin -= len(first)
+	}
+	if begin < 0 || begin+len(text) > len(f.data) || string(f.data[begin:begin+len(text)]) != text {
+		if f.err == nil {
+			f.err = fmt.Errorf("can't locate %s at offset %d", text, node.Position())
+		}
+		return Reference{}, false
+	}
+	return Reference{Key: append([]string(nil), idents...), Begin: begin, End: begin + len(text)}, true
+}
+
+// stylePipe returns the pipeline cmds, following a reference, are if it's one of the ones keeping the style of a
+// scalar, along with the offset of its end; it's "" if there are no cmds.
+func stylePipe(cmds []*parse.CommandNode) (string, int, bool) {
+	identifier := func(node parse.Node, name string) bool {
+		ident, ok := node.(*parse.IdentifierNode)
+		return ok && ident.Ident == name
+	}
+	switch {
+	case len(cmds) == 0:
+		return "", 0, true
+	case len(cmds) == 1 && len(cmds[0].Args) == 1 && identifier(cmds[0].Args[0], "quote"):
+		return "quote", int(cmds[0].Args[0].Position()) + len("quote"), true
+	case len(cmds) == 2 && len(cmds[0].Args) == 1 && identifier(cmds[0].Args[0], "toYaml") &&
+		len(cmds[1].Args) == 2 && identifier(cmds[1].Args[0], "nindent"):
+		n, ok := cmds[1].Args[1].(*parse.NumberNode)
+		if !ok || !n.IsInt {
+			return "", 0, false
+		}
+		return fmt.Sprintf("toYaml | nindent %d", n.Int64), int(n.Position()) + len(n.Text), true
+	}
+	return "", 0, false
+}
+
+// standalone sets the span of the action holding only ref, and the pipeline ending at end, including its delimiters.
+func (f *finder) standalone(ref *Reference, end int) {
+	begin := ref.Begin
+	for begin > 0 && isSpace(f.data[begin-1]) {
+		begin--
+	}
+	// a trim marker is separated from the action's content by spaces.
+	if begin < ref.Begin && begin > 0 && f.data[begin-1] == '-' {
+		begin--
+		ref.TrimLeft = true
+	}
+	if begin < 2 || string(f.data[begin-2:begin]) != "{{" {
+		ref.TrimLeft = false
+		return
+	}
+	contentEnd := end
+	for end < len(f.data) && isSpace(f.data[end]) {
+		end++
+	}
+	if end < len(f.data) && f.data[end] == '-' && end > contentEnd {
+		end++
+		ref.TrimRight = true
+	}
+	if end+2 > len(f.data) || string(f.data[end:end+2]) != "}}" {
+		ref.TrimLeft, ref.TrimRight = false, false
+		return
+	}
+	ref.Standalone = true
+	ref.ActionBegin = begin - 2
+	ref.ActionEnd = end + 2
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
@@ -0,0 +1,84 @@
+package valuesref
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected []Reference
+	}{
+		{
+			name: "standalone",
+			data: "spec:\n  replicas: {{ .Values.nginx.replicas }}\n",
+			expected: []Reference{
+				{Key: []string{"nginx", "replicas"}, Line: 2, Begin: 21, End: 43, Standalone: true, ActionBegin: 18, ActionEnd: 46},
+			},
+		},
+		{
+			name: "trim-markers",
+			data: "a: {{- .Values.a -}}\n",
+			expected: []Reference{
+				{Key: []string{"a"}, Line: 1, Begin: 7, End: 16, Standalone: true, ActionBegin: 3, ActionEnd: 20, TrimLeft: true, TrimRight: true},
+			},
+		},
+		{
+			name: "root-variable",
+			data: "{{ $.Values.a }}",
+			expected: []Reference{
+				{Key: []string{"a"}, Line: 1, Begin: 3, End: 13, Standalone: true, ActionBegin: 0, ActionEnd: 16},
+			},
+		},
+		{
+			name: "style-pipes",
+			data: "a: {{ .Values.a | quote }}\nb: {{- .Values.b | toYaml | nindent 2 -}}\n",
+			expected: []Reference{
+				{Key: []string{"a"}, Line: 1, Begin: 6, End: 15, Standalone: true, ActionBegin: 3, ActionEnd: 26, Pipe: "quote"},
+				{Key: []string{"b"}, Line: 2, Begin: 34, End: 43, Standalone: true, ActionBegin: 30, ActionEnd: 68, TrimLeft: true, TrimRight: true, Pipe: "toYaml | nindent 2"},
+			},
+		},
+		{
+			name: "pipelines-conditions-and-defines",
+			data: "{{ define \"x\" }}{{ .Values.a | upper }}{{ end }}\n{{ if .Values.b }}{{ default 1 .Values.c.d }}{{ end }}{{ toYaml .Values }}",
+			expected: []Reference{
+				{Key: []string{"a"}, Line: 1, Begin: 19, End: 28},
+				{Key: []string{"b"}, Line: 2, Begin: 55, End: 64},
+				{Key: []string{"c", "d"}, Line: 2, Begin: 80, End: 91},
+				{Line: 2, Begin: 113, End: 120},
+			},
+		},
+		{
+			name: "other-fields",
+			data: "{{ .Release.Name }}{{ .Chart.Values }}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			actual, err := Find("templates/test.yaml", []byte(tt.data))
+
+			// Assert
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+			for _, ref := range actual {
+				require.Contains(t, tt.data[ref.Begin:ref.End], "Values", "the reference should span its text")
+			}
+		})
+	}
+}
+
+func TestReferenceOverlaps(t *testing.T) {
+	ref := Reference{Key: []string{"nginx", "replicas"}}
+
+	require.True(t, ref.Overlaps([]string{"nginx", "replicas"}))
+	require.True(t, ref.Overlaps([]string{"nginx"}), "keys holding the reference's key overlap it")
+	require.True(t, ref.Overlaps([]string{"nginx", "replicas", "x"}), "keys below the reference's key overlap it")
+	require.False(t, ref.Overlaps([]string{"web"}))
+	require.True(t, ref.HasPrefix([]string{"nginx"}))
+	require.False(t, ref.HasPrefix([]string{"nginx", "replicas", "x"}))
+}
@@ -2,12 +2,17 @@ package visitor
 
 type Collector struct {
 	Patches []Patch
+	Errors  []error
 }
 
 func NewCollector() *Collector {
 	return &Collector{}
 }
 
-func (c *Collector) AddPatch(path string, beginOffset int, endOffset int) {
-	c.Patches = append(c.Patches, Patch{Path: path, BeginOffset: beginOffset, EndOffset: endOffset})
+func (c *Collector) AddPatch(path string, s span, indent int) {
+	c.Patches = append(c.Patches, Patch{Path: path, BeginOffset: s.begin, EndOffset: s.end, Style: s.style, Indent: indent, Comment: s.comment})
+}
+
+func (c *Collector) AddError(err error) {
+	c.Errors = append(c.Errors, err)
 }
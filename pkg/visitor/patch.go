@@ -3,26 +3,104 @@ package visitor
 import (
 	"bytes"
 	"fmt"
+	"sort"
 )
 
+// Patch replaces the scalar value found at Path, spanning the bytes [BeginOffset, EndOffset) of a template, with a
+// reference to ValuesKey in values.yaml, which renders the value in the same Style; Indent is the indentation of the
+// value's key. Comment is the comment following the header of a block scalar, kept along with the reference.
 type Patch struct {
 	Path        string
+	ValuesKey   string
 	BeginOffset int
 	EndOffset   int
+	Style       Style
+	Indent      int
+	Comment     string
 }
 
-func (p Patch) Apply(valuesKey string, data []byte) []byte {
-	fst := data[0 : p.BeginOffset+1]
-	snd := data[p.EndOffset:]
+func (p Patch) replacement(valuesKey string) []byte {
+	switch p.Style {
+	case QuotedStyle:
+		// the quotes keep strings such as "8080" from being read as numbers.
+		return []byte(p.withComment(fmt.Sprintf("{{ .Values.%s | quote }}", valuesKey)))
+	case BlockStyle:
+		// the value is rendered in the lines following its key, so the comment precedes it.
+		reference := fmt.Sprintf("{{ .Values.%s | toYaml | nindent %d }}", valuesKey, p.Indent+2)
+		if p.Comment != "" {
+			return []byte(p.Comment + " " + reference)
+		}
+		return []byte(reference)
+	default:
+		return []byte(p.withComment(fmt.Sprintf("{{ .Values.%s }}", valuesKey)))
+	}
+}
+
+func (p Patch) withComment(reference string) string {
+	if p.Comment != "" {
+		return reference + " " + p.Comment
+	}
+	return reference
+}
 
+// Apply replaces the value p spans in data with a reference to valuesKey.
+func (p Patch) Apply(valuesKey string, data []byte) []byte {
 	templateNewData := bytes.Join(
 		[][]byte{
-			fst,
-			[]byte(fmt.Sprintf("{{ .Values.%s }}\n", valuesKey)),
-			snd,
+			data[:p.BeginOffset],
+			p.replacement(valuesKey),
+			data[p.EndOffset:],
 		},
 		[]byte{})
 
 	return templateNewData
+}
+
+// Conflict reports a patch spanning bytes already replaced by another patch, such as two actions targeting the same
+// node.
+type Conflict struct {
+	Kept    Patch
+	Ignored Patch
+}
+
+func (c Conflict) Error() string {
+	return fmt.Sprintf("%s, moved to %s, overlaps %s, moved to %s", c.Ignored.Path, c.Ignored.ValuesKey, c.Kept.Path, c.Kept.ValuesKey)
+}
+
+// SortPatches returns patches sorted by offset, without the ones overlapping a preceding patch, which are returned
+// as conflicts; of patches starting at the same offset, the first one in patches is kept.
+func SortPatches(patches []Patch) ([]Patch, []Conflict) {
+	sorted := make([]Patch, len(patches))
+	copy(sorted, patches)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].BeginOffset < sorted[j].BeginOffset
+	})
+
+	var kept []Patch
+	var conflicts []Conflict
+	for _, p := range sorted {
+		if len(kept) > 0 && p.BeginOffset < kept[len(kept)-1].EndOffset {
+			conflicts = append(conflicts, Conflict{Kept: kept[len(kept)-1], Ignored: p})
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return kept, conflicts
+}
 
+// ApplyPatches applies patches, sorted by offset and not overlapping as returned by SortPatches, to data in a single
+// pass, replacing each value with a reference to the patch's ValuesKey.
+func ApplyPatches(data []byte, patches []Patch) ([]byte, error) {
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	offset := 0
+	for _, p := range patches {
+		if p.BeginOffset < offset || p.EndOffset < p.BeginOffset || p.EndOffset > len(data) {
+			return nil, fmt.Errorf("patch of %s spanning [%d, %d) is out of order or overlaps the previous one", p.Path, p.BeginOffset, p.EndOffset)
+		}
+		out.Write(data[offset:p.BeginOffset])
+		out.Write(p.replacement(p.ValuesKey))
+		offset = p.EndOffset
+	}
+	out.Write(data[offset:])
+	return out.Bytes(), nil
 }
@@ -0,0 +1,253 @@
+package visitor
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart"
+	"k8s.io/client-go/util/jsonpath"
+	"sigs.k8s.io/yaml"
+
+	hdtesting "github.com/redhat-developer/helm-dump/pkg/test"
+)
+
+func patch(t *testing.T, path string, data string) (string, error) {
+	file, err := parser.ParseBytes([]byte(data), 0)
+	require.NoError(t, err)
+	collector := NewCollector()
+	ast.Walk(NewMappingNodeVisitor(path, []byte(data), collector), file.Docs[0])
+	if len(collector.Errors) > 0 {
+		return "", collector.Errors[0]
+	}
+	require.Len(t, collector.Patches, 1)
+	return string(collector.Patches[0].Apply("key", []byte(data))), nil
+}
+
+// render renders tmpl as a chart's template, with the value found at path in data as the value of key, as moved to
+// values.yaml by move-to-values, and returns the rendered document and data both parsed.
+func render(t *testing.T, path string, data string, tmpl string) (interface{}, interface{}) {
+	var original map[string]interface{}
+	require.NoError(t, yaml.Unmarshal([]byte(data), &original))
+	j := jsonpath.New("")
+	require.NoError(t, j.Parse(fmt.Sprintf("{%s}", path)))
+	value := new(bytes.Buffer)
+	require.NoError(t, j.Execute(value, original))
+
+	chrt := &chart.Chart{
+		Metadata:  &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "test", Version: "0.1.0"},
+		Templates: []*chart.File{{Name: "templates/test.yaml", Data: []byte(tmpl)}},
+		Values:    map[string]interface{}{"key": value.String()},
+	}
+	rendered := hdtesting.RequireRenderedTemplates(t, chrt, "test")["test/templates/test.yaml"]
+	var actual map[string]interface{}
+	require.NoError(t, yaml.Unmarshal([]byte(rendered), &actual), "rendered template should be valid:\n%s", rendered)
+	return original, actual
+}
+
+func TestPatchApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		data     string
+		expected string
+	}{
+		{
+			name:     "plain",
+			path:     ".spec.replicas",
+			data:     "spec:\n  replicas: 1\n  paused: false\n",
+			expected: "spec:\n  replicas: {{ .Values.key }}\n  paused: false\n",
+		},
+		{
+			name:     "last-line-without-newline",
+			path:     ".spec.replicas",
+			data:     "spec:\n  replicas: 1",
+			expected: "spec:\n  replicas: {{ .Values.key }}",
+		},
+		{
+			name:     "trailing-comment",
+			path:     ".spec.replicas",
+			data:     "spec:\n  replicas: 1 # scaled by the HPA\n",
+			expected: "spec:\n  replicas: {{ .Values.key }} # scaled by the HPA\n",
+		},
+		{
+			name:     "double-quoted-with-colon",
+			path:     ".data.url",
+			data:     "data:\n  url: \"http://nginx:80\" # internal\n  b: c\n",
+			expected: "data:\n  url: {{ .Values.key | quote }} # internal\n  b: c\n",
+		},
+		{
+			name:     "double-quoted-with-escaped-quote",
+			path:     ".data.a",
+			data:     "data:\n  a: \"say \\\"hi\\\"\"\n",
+			expected: "data:\n  a: {{ .Values.key | quote }}\n",
+		},
+		{
+			name:     "single-quoted-with-escaped-quote",
+			path:     ".data.a",
+			data:     "data:\n  a: 'it''s: here'\n",
+			expected: "data:\n  a: {{ .Values.key | quote }}\n",
+		},
+		{
+			name:     "quoted-number",
+			path:     ".data.port",
+			data:     "data:\n  port: \"8080\"\n",
+			expected: "data:\n  port: {{ .Values.key | quote }}\n",
+		},
+		{
+			name:     "flow-mapping",
+			path:     ".spec.selector.app",
+			data:     "spec:\n  selector: {app: nginx, tier: web}\n",
+			expected: "spec:\n  selector: {app: {{ .Values.key }}, tier: web}\n",
+		},
+		{
+			name:     "literal",
+			path:     ".data.script",
+			data:     "data:\n  script: |\n    echo a\n\n    echo b\n  other: x\n",
+			expected: "data:\n  script: {{ .Values.key | toYaml | nindent 4 }}\n  other: x\n",
+		},
+		{
+			name:     "folded-with-header-comment",
+			path:     ".data.text",
+			data:     "data:\n  text: >- # folded\n    a\n    b\n",
+			expected: "data:\n  text: # folded {{ .Values.key | toYaml | nindent 4 }}\n",
+		},
+		{
+			name:     "multi-line-plain",
+			path:     ".data.text",
+			data:     "data:\n  text: a\n    b\n  other: x\n",
+			expected: "data:\n  text: {{ .Values.key }}\n  other: x\n",
+		},
+		{
+			name:     "tagged",
+			path:     ".data.port",
+			data:     "data:\n  port: !!str 80\n",
+			expected: "data:\n  port: {{ .Values.key | quote }}\n",
+		},
+		{
+			name:     "multi-byte-characters",
+			path:     ".data.b",
+			data:     "data:\n  a: {é: 1}\n  b: ünïcode\n",
+			expected: "data:\n  a: {é: 1}\n  b: {{ .Values.key }}\n",
+		},
+		{
+			name:     "sequence-item",
+			path:     ".spec.containers[0].image",
+			data:     "spec:\n  containers:\n  - name: nginx\n    image: nginx:1.21 # pinned\n",
+			expected: "spec:\n  containers:\n  - name: nginx\n    image: {{ .Values.key }} # pinned\n",
+		},
+		{
+			name:     "literal-in-sequence-item",
+			path:     ".spec.containers[0].script",
+			data:     "spec:\n  containers:\n  - name: x\n    script: |-\n      set -e\n      echo \"$A\"\n",
+			expected: "spec:\n  containers:\n  - name: x\n    script: {{ .Values.key | toYaml | nindent 6 }}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			actual, err := patch(t, tt.path, tt.data)
+
+			// Assert
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+			original, rendered := render(t, tt.path, tt.data, actual)
+			require.Equal(t, original, rendered, "the template should render the original value")
+		})
+	}
+}
+
+func TestPatchNonScalar(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		data string
+	}{
+		{name: "mapping", path: ".spec", data: "spec:\n  replicas: 1\n"},
+		{name: "empty", path: ".spec", data: "spec:\nother: 1\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			_, err := patch(t, tt.path, tt.data)
+
+			// Assert
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestSortPatches(t *testing.T) {
+	tests := []struct {
+		name      string
+		patches   []Patch
+		expected  []Patch
+		conflicts []Conflict
+	}{
+		{
+			name:     "sorted-by-offset",
+			patches:  []Patch{{Path: ".b", BeginOffset: 10, EndOffset: 12}, {Path: ".a", BeginOffset: 2, EndOffset: 4}},
+			expected: []Patch{{Path: ".a", BeginOffset: 2, EndOffset: 4}, {Path: ".b", BeginOffset: 10, EndOffset: 12}},
+		},
+		{
+			name:      "same-node",
+			patches:   []Patch{{Path: ".a", ValuesKey: "first", BeginOffset: 2, EndOffset: 4}, {Path: ".a", ValuesKey: "second", BeginOffset: 2, EndOffset: 4}},
+			expected:  []Patch{{Path: ".a", ValuesKey: "first", BeginOffset: 2, EndOffset: 4}},
+			conflicts: []Conflict{{Kept: Patch{Path: ".a", ValuesKey: "first", BeginOffset: 2, EndOffset: 4}, Ignored: Patch{Path: ".a", ValuesKey: "second", BeginOffset: 2, EndOffset: 4}}},
+		},
+		{
+			name:      "overlapping",
+			patches:   []Patch{{Path: ".a", BeginOffset: 2, EndOffset: 8}, {Path: ".b", BeginOffset: 6, EndOffset: 10}},
+			expected:  []Patch{{Path: ".a", BeginOffset: 2, EndOffset: 8}},
+			conflicts: []Conflict{{Kept: Patch{Path: ".a", BeginOffset: 2, EndOffset: 8}, Ignored: Patch{Path: ".b", BeginOffset: 6, EndOffset: 10}}},
+		},
+		{
+			name:     "adjacent",
+			patches:  []Patch{{Path: ".b", BeginOffset: 4, EndOffset: 6}, {Path: ".a", BeginOffset: 2, EndOffset: 4}},
+			expected: []Patch{{Path: ".a", BeginOffset: 2, EndOffset: 4}, {Path: ".b", BeginOffset: 4, EndOffset: 6}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			actual, conflicts := SortPatches(tt.patches)
+
+			// Assert
+			require.Equal(t, tt.expected, actual)
+			require.Equal(t, tt.conflicts, conflicts)
+		})
+	}
+}
+
+func TestApplyPatches(t *testing.T) {
+	t.Run("single-pass", func(t *testing.T) {
+		// Arrange
+		data := []byte("spec:\n  replicas: 1 # scaled\n  image: \"nginx:1.21\"\n")
+		patches, conflicts := SortPatches([]Patch{
+			{ValuesKey: "image", BeginOffset: 38, EndOffset: 50},
+			{ValuesKey: "replicas", BeginOffset: 18, EndOffset: 19},
+		})
+		require.Empty(t, conflicts)
+
+		// Act
+		actual, err := ApplyPatches(data, patches)
+
+		// Assert
+		require.NoError(t, err)
+		require.Equal(t, "spec:\n  replicas: {{ .Values.replicas }} # scaled\n  image: {{ .Values.image }}\n", string(actual))
+	})
+
+	t.Run("unsorted-patches", func(t *testing.T) {
+		// Act
+		_, err := ApplyPatches([]byte("a: 1\nb: 2\n"), []Patch{{BeginOffset: 8, EndOffset: 9}, {BeginOffset: 3, EndOffset: 4}})
+
+		// Assert
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,193 @@
+package visitor
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/goccy/go-yaml/ast"
+)
+
+// Style is how a scalar value is written, which the reference replacing it keeps so it renders to the same type.
+type Style int
+
+const (
+	// PlainStyle is an unquoted scalar, such as 3 or nginx.
+	PlainStyle Style = iota
+	// QuotedStyle is a single or double quoted scalar, or one tagged as a string, which holds a string even if it
+	// reads as another type, such as "8080".
+	QuotedStyle
+	// BlockStyle is a literal or folded scalar, such as a script following |, which spans several lines.
+	BlockStyle
+)
+
+// span is the range [begin, end) of the bytes of a scalar value in a YAML document, written in style. comment is the
+// comment following the header of a block scalar, which lies within the range but isn't part of the value.
+type span struct {
+	begin   int
+	end     int
+	style   Style
+	comment string
+}
+
+// offsetOf returns the offset in data of the 1-based line and column, counted in runes, of a token.
+func offsetOf(data []byte, line int, column int) (int, error) {
+	offset := 0
+	for l := 1; l < line; l++ {
+		i := bytes.IndexByte(data[offset:], '\n')
+		if i < 0 {
+			return 0, fmt.Errorf("line %d is out of range", line)
+		}
+		offset += i + 1
+	}
+	for c := 1; c < column; c++ {
+		if offset >= len(data) || data[offset] == '\n' {
+			return 0, fmt.Errorf("column %d of line %d is out of range", column, line)
+		}
+		_, size := utf8.DecodeRune(data[offset:])
+		offset += size
+	}
+	return offset, nil
+}
+
+// lineEnd returns the offset of the end of the line holding offset, excluding the line break.
+func lineEnd(data []byte, offset int) int {
+	i := bytes.IndexByte(data[offset:], '\n')
+	if i < 0 {
+		return len(data)
+	}
+	return offset + i
+}
+
+// indentation returns the number of spaces starting the line which starts at offset.
+func indentation(data []byte, offset int) int {
+	n := 0
+	for offset+n < len(data) && data[offset+n] == ' ' {
+		n++
+	}
+	return n
+}
+
+// trimSpaceRight returns end moved before the spaces preceding it, but not before begin.
+func trimSpaceRight(data []byte, begin int, end int) int {
+	for end > begin && (data[end-1] == ' ' || data[end-1] == '\t' || data[end-1] == '\r') {
+		end--
+	}
+	return end
+}
+
+// commentStart returns the offset of the comment in data[begin:end], or end if there's none; a comment starts with a
+// # preceded by a space.
+func commentStart(data []byte, begin int, end int) int {
+	for i := begin; i < end; i++ {
+		if data[i] == '#' && i > begin && (data[i-1] == ' ' || data[i-1] == '\t') {
+			return i
+		}
+	}
+	return end
+}
+
+// continuationEnd returns the end of the lines following offset which are indented more than indent, continuing a
+// multi-line scalar; blank lines are included only if followed by such lines, and comments end plain scalars.
+func continuationEnd(data []byte, offset int, indent int, plain bool) int {
+	end := offset
+	for lineStop := offset; lineStop < len(data); {
+		lineStart := lineStop + 1
+		lineStop = lineEnd(data, lineStart)
+		content := bytes.TrimRight(data[lineStart:lineStop], " \t\r")
+		if len(bytes.TrimSpace(content)) == 0 {
+			continue
+		}
+		if indentation(data, lineStart) <= indent || (plain && bytes.HasPrefix(bytes.TrimSpace(content), []byte("#"))) {
+			break
+		}
+		end = lineStart + len(content)
+	}
+	return end
+}
+
+// quotedEnd returns the offset following the closing quote of the quoted scalar starting at begin.
+func quotedEnd(data []byte, begin int) (int, error) {
+	quote := data[begin]
+	for i := begin + 1; i < len(data); i++ {
+		switch {
+		case quote == '"' && data[i] == '\\':
+			i++
+		case data[i] == quote && quote == '\'' && i+1 < len(data) && data[i+1] == '\'':
+			i++
+		case data[i] == quote:
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated quoted scalar at offset %d", begin)
+}
+
+// valueSpan returns the span of the scalar value of a mapping entry whose key is indented by indent spaces.
+func valueSpan(data []byte, value ast.Node, indent int) (span, error) {
+	tk := value.GetToken()
+	begin, err := offsetOf(data, tk.Position.Line, tk.Position.Column)
+	if err != nil {
+		return span{}, err
+	}
+
+	switch n := value.(type) {
+	case *ast.TagNode, *ast.AnchorNode:
+		// the tag or anchor is replaced along with the value it applies to.
+		var inner ast.Node
+		if tag, ok := n.(*ast.TagNode); ok {
+			inner = tag.Value
+		} else {
+			inner = n.(*ast.AnchorNode).Value
+		}
+		s, err := valueSpan(data, inner, indent)
+		if err != nil {
+			return span{}, err
+		}
+		s.begin = begin
+		if tag, ok := n.(*ast.TagNode); ok && tag.Start.Value == "!!str" && s.style == PlainStyle {
+			s.style = QuotedStyle
+		}
+		return s, nil
+	case *ast.MappingNode, *ast.MappingValueNode, *ast.SequenceNode:
+		return span{}, fmt.Errorf("value at line %d isn't a scalar", tk.Position.Line)
+	case *ast.LiteralNode:
+		// the header, such as |- or >, ends the line; the content is in the following lines.
+		headerEnd := lineEnd(data, begin)
+		comment := commentStart(data, begin, headerEnd)
+		s := span{begin: begin, end: continuationEnd(data, headerEnd, indent, false), style: BlockStyle}
+		if comment < headerEnd {
+			s.comment = string(bytes.TrimRight(data[comment:headerEnd], " \t\r"))
+		}
+		if s.end <= headerEnd {
+			s.end = trimSpaceRight(data, begin, comment)
+		}
+		return s, nil
+	}
+
+	if begin < len(data) && (data[begin] == '"' || data[begin] == '\'') {
+		end, err := quotedEnd(data, begin)
+		if err != nil {
+			return span{}, err
+		}
+		return span{begin: begin, end: end, style: QuotedStyle}, nil
+	}
+
+	// plain scalars are written as parsed, unless they span several lines.
+	if bytes.HasPrefix(data[begin:], []byte(tk.Value)) && tk.Value != "" {
+		return span{begin: begin, end: begin + len(tk.Value)}, nil
+	}
+	if _, ok := value.(*ast.NullNode); ok {
+		return span{}, fmt.Errorf("entry at line %d has no value", tk.Position.Line)
+	}
+	firstLineEnd := lineEnd(data, begin)
+	comment := commentStart(data, begin, firstLineEnd)
+	if comment < firstLineEnd {
+		// a comment ends a plain scalar.
+		return span{begin: begin, end: trimSpaceRight(data, begin, comment)}, nil
+	}
+	end := continuationEnd(data, firstLineEnd, indent, true)
+	if end <= firstLineEnd {
+		end = trimSpaceRight(data, begin, firstLineEnd)
+	}
+	return span{begin: begin, end: end}, nil
+}
@@ -5,19 +5,20 @@ import (
 	"github.com/goccy/go-yaml/ast"
 )
 
+// MappingNodeVisitor collects patches for the values found at path in data, the document being visited.
 type MappingNodeVisitor struct {
-	Collector   *Collector
-	YamlPath    string
-	path        string
-	BeginOffset int
-	EndOffset   int
+	Collector *Collector
+	YamlPath  string
+	path      string
+	data      []byte
 }
 
-func NewMappingNodeVisitor(path string, collector *Collector) *MappingNodeVisitor {
+func NewMappingNodeVisitor(path string, data []byte, collector *Collector) *MappingNodeVisitor {
 	return &MappingNodeVisitor{
 		Collector: collector,
 		YamlPath:  fmt.Sprintf("$%s", path),
 		path:      path,
+		data:      data,
 	}
 }
 
@@ -28,7 +29,14 @@ func (v *MappingNodeVisitor) Visit(node ast.Node) ast.Visitor {
 
 	switch n := node.(type) {
 	case *ast.MappingValueNode:
-		v.Collector.AddPatch(v.path, n.GetToken().Position.Offset, n.GetToken().Next.Position.Offset)
+		// the value's continuation lines are indented more than its key.
+		indent := n.Key.GetToken().Position.Column - 1
+		s, err := valueSpan(v.data, n.Value, indent)
+		if err != nil {
+			v.Collector.AddError(fmt.Errorf("error patching %s: %w", v.path, err))
+			return v
+		}
+		v.Collector.AddPatch(v.path, s, indent)
 		return v
 	default:
 		return v